@@ -0,0 +1,229 @@
+package barcode
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// modules as a string of 0 (space) and 1 (bar)
+func pattern(bars []bool) string {
+	var b strings.Builder
+	for _, bar := range bars {
+		if bar {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+
+	return b.String()
+}
+
+func TestISBN13(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+		err  error
+	}{
+		{"978-0-451-52493-5", "9780451524935", nil},
+		{"978 0 451 52493 5", "9780451524935", nil},
+		{"0-451-52493-4", "9780451524935", nil},
+		{"0-8044-2957-X", "9780804429573", nil},
+		{"0-8044-2957-x", "9780804429573", nil},
+		{"979-10-90636-07-1", "9791090636071", nil},
+		{"978-0-451-52493-4", "", ErrISBN},
+		{"0-451-52493-5", "", ErrISBN},
+		{"978-0-451-52493-X", "", ErrISBN},
+		{"X-451-52493-4", "", ErrISBN},
+		{"978-0-451-5249", "", ErrISBN},
+		{"", "", ErrISBN},
+	}
+
+	for _, tt := range tests {
+		got, err := ISBN13(tt.isbn)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("ISBN13(%q) = %q, %v, want %q, %v", tt.isbn, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	for twelve, want := range map[string]byte{
+		"978045152493": '5',
+		"978080442957": '3',
+		"400638133393": '1',
+		"590123412345": '7',
+		"000000000000": '0',
+	} {
+		if got := checkDigit(twelve); got != want {
+			t.Errorf("checkDigit(%s) = %c, want %c", twelve, got, want)
+		}
+	}
+}
+
+func TestEAN13(t *testing.T) {
+	tests := []struct {
+		ean string
+		// left digits in the L and G sets of the first digit, right digits in R
+		left, right string
+	}{
+		{
+			// 9 is LGGLGL: 7L 8G 0G 4L 5G 1L | 5 2 4 9 3 5
+			"9780451524935",
+			"0111011" + "0001001" + "0100111" + "0100011" + "0111001" + "0011001",
+			"1001110" + "1101100" + "1011100" + "1110100" + "1000010" + "1001110",
+		},
+		{
+			// 4 is LGLLGG: 0L 0G 6L 3L 8G 1G | 3 3 3 9 3 1
+			"4006381333931",
+			"0001101" + "0100111" + "0101111" + "0111101" + "0001001" + "0110011",
+			"1000010" + "1000010" + "1000010" + "1110100" + "1000010" + "1100110",
+		},
+	}
+
+	for _, tt := range tests {
+		want := "101" + tt.left + "01010" + tt.right + "101"
+		got := pattern(EAN13(tt.ean))
+		if len(got) != 95 || got != want {
+			t.Errorf("EAN13(%s) =\n%s\nwant\n%s", tt.ean, got, want)
+		}
+	}
+}
+
+func TestEAN5(t *testing.T) {
+	tests := []struct {
+		addon string
+		want  string
+		err   error
+	}{
+		// checksum 1 is GLGLL
+		{"52495", "1011" + "0111001" + "01" + "0010011" + "01" + "0011101" + "01" + "0001011" + "01" + "0110001", nil},
+		// checksum 7 is LGLGL
+		{"90000", "1011" + "0001011" + "01" + "0100111" + "01" + "0001101" + "01" + "0100111" + "01" + "0001101", nil},
+		{"5099", "", ErrAddon},
+		{"5o999", "", ErrAddon},
+		{"509990", "", ErrAddon},
+	}
+
+	for _, tt := range tests {
+		bars, err := EAN5(tt.addon)
+		if got := pattern(bars); got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("EAN5(%q) = %s, %v, want %s, %v", tt.addon, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestPriceAddon(t *testing.T) {
+	for price, want := range map[float32]string{
+		9.99:  "50999",
+		19.99: "51999",
+		99.99: "59999",
+		0.1:   "50010",
+		0:     "90000",
+		-5:    "90000",
+		100:   "90000",
+	} {
+		if got := PriceAddon(price); got != want {
+			t.Errorf("PriceAddon(%g) = %s, want %s", price, got, want)
+		}
+	}
+}
+
+func TestISBNSymbol(t *testing.T) {
+	tests := []struct {
+		name  string
+		addon string
+		width int
+		texts []string
+		err   error
+	}{
+		{"without add-on", "", 113, []string{"9", "780451", "524935"}, nil},
+		{"with add-on", "50999", 167, []string{"9", "780451", "524935", "50999"}, nil},
+		{"bad add-on", "5099", 0, nil, ErrAddon},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ISBNSymbol("978-0-451-52493-5", tt.addon)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ISBNSymbol = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if s.Width != tt.width || s.Height != height {
+				t.Errorf("size = %dx%d, want %dx%d", s.Width, s.Height, tt.width, height)
+			}
+
+			var svg bytes.Buffer
+			if err := s.SVG(&svg, 2); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(svg.String(), `width="`+strconv.Itoa(tt.width*2)+`"`) {
+				t.Errorf("SVG = %s, want it %d pixels wide", svg.String(), tt.width*2)
+			}
+			for _, text := range tt.texts {
+				if !strings.Contains(svg.String(), ">"+text+"</text>") {
+					t.Errorf("SVG = %s, want the text %s", svg.String(), text)
+				}
+			}
+		})
+	}
+
+	if _, err := ISBNSymbol("978-0-451-52493-4", ""); !errors.Is(err, ErrISBN) {
+		t.Errorf("ISBNSymbol of a bad check digit = %v, want ErrISBN", err)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	s, err := ISBNSymbol("9780451524935", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.PNG(&buf, 3); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 113*3 || size.Y != height*3 {
+		t.Fatalf("PNG is %v, want %dx%d", size, 113*3, height*3)
+	}
+
+	// the start guard 101 after the quiet zone, from the top to the guard height
+	dark := func(x, y int) bool {
+		r, _, _, _ := img.At(x, y).RGBA()
+		return r == 0
+	}
+	for _, px := range []struct {
+		x, y int
+		dark bool
+	}{
+		{quietLeft*3 - 1, 0, false},
+		{quietLeft * 3, 0, true},
+		{quietLeft*3 + 3, 0, false},
+		{quietLeft*3 + 6, guardHeight*3 - 1, true},
+		{quietLeft*3 + 6, guardHeight * 3, false},
+	} {
+		if dark(px.x, px.y) != px.dark {
+			t.Errorf("pixel %d,%d dark = %v, want %v", px.x, px.y, !px.dark, px.dark)
+		}
+	}
+}
+
+func TestQRSymbol(t *testing.T) {
+	s, err := QRSymbol("https://books.example.com/book/9780451524935")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Width != s.Height || s.Width < 21 || len(s.bars) == 0 {
+		t.Errorf("QR symbol is %dx%d with %d modules", s.Width, s.Height, len(s.bars))
+	}
+}
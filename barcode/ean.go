@@ -0,0 +1,172 @@
+// Package barcode draws the EAN-13 barcode of a book (the "Bookland" 978/979
+// code printed on the back cover), with an optional EAN-5 price add-on, and
+// QR codes. Everything is drawn in Go, as SVG or PNG.
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrISBN  = errors.New("barcode: not a valid ISBN-10 or ISBN-13")
+	ErrAddon = errors.New("barcode: add-on should be 5 digits")
+)
+
+// left (odd parity) codes, G codes are L reversed and inverted, R codes are L inverted
+var lCodes = [10]string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+
+// parity of the left six digits, chosen by the first digit
+var ean13Parity = [10]string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+
+// parity of the five add-on digits, chosen by the add-on checksum
+var ean5Parity = [10]string{"GGLLL", "GLGLL", "GLLGL", "GLLLG", "LGGLL", "LLGGL", "LLLGG", "LGLGL", "LGLLG", "LLGLG"}
+
+func code(digit byte, set byte) string {
+	l := lCodes[digit-'0']
+	var b strings.Builder
+	switch set {
+	case 'L':
+		return l
+	case 'R':
+		for i := 0; i < len(l); i++ {
+			b.WriteByte('0' + '1' - l[i])
+		}
+	case 'G':
+		for i := len(l) - 1; i >= 0; i-- {
+			b.WriteByte('0' + '1' - l[i])
+		}
+	}
+
+	return b.String()
+}
+
+// ISBN13 strips hyphens and spaces, turns an ISBN-10 into its 978 ISBN-13 and
+// checks the check digit
+func ISBN13(isbn string) (string, error) {
+	clean := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(isbn))
+
+	switch len(clean) {
+	case 10:
+		if !digits(clean[:9]) || !(digits(clean[9:]) || clean[9] == 'X') {
+			return "", ErrISBN
+		}
+		sum := 0
+		for i := 0; i < 10; i++ {
+			d := 10
+			if clean[i] != 'X' {
+				d = int(clean[i] - '0')
+			}
+			sum += d * (10 - i)
+		}
+		if sum%11 != 0 {
+			return "", ErrISBN
+		}
+		body := "978" + clean[:9]
+		return body + string(checkDigit(body)), nil
+	case 13:
+		if !digits(clean) || checkDigit(clean[:12]) != clean[12] {
+			return "", ErrISBN
+		}
+		return clean, nil
+	}
+
+	return "", ErrISBN
+}
+
+// EAN-13 check digit of the first twelve digits
+func checkDigit(twelve string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(twelve[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// EAN13 returns the 95 modules (true is a bar) of a checked 13 digit code
+func EAN13(ean string) []bool {
+	var b strings.Builder
+	b.WriteString("101")
+	parity := ean13Parity[ean[0]-'0']
+	for i := 1; i <= 6; i++ {
+		b.WriteString(code(ean[i], parity[i-1]))
+	}
+	b.WriteString("01010")
+	for i := 7; i <= 12; i++ {
+		b.WriteString(code(ean[i], 'R'))
+	}
+	b.WriteString("101")
+
+	return modules(b.String())
+}
+
+// EAN5 returns the 47 modules of a 5 digit add-on, like 51999 for $19.99
+func EAN5(addon string) ([]bool, error) {
+	if len(addon) != 5 || !digits(addon) {
+		return nil, ErrAddon
+	}
+
+	d := func(i int) int { return int(addon[i] - '0') }
+	parity := ean5Parity[(3*(d(0)+d(2)+d(4))+9*(d(1)+d(3)))%10]
+
+	var b strings.Builder
+	b.WriteString("1011")
+	for i := 0; i < 5; i++ {
+		if i > 0 {
+			b.WriteString("01")
+		}
+		b.WriteString(code(addon[i], parity[i]))
+	}
+
+	return modules(b.String()), nil
+}
+
+// PriceAddon is the EAN-5 for a price in US dollars (leading 5), 90000 when
+// there is no price or it does not fit in four digits
+func PriceAddon(price float32) string {
+	cents := int(price*100 + 0.5)
+	if cents <= 0 || cents > 9999 {
+		return "90000"
+	}
+
+	return "5" + leftPad(cents)
+}
+
+func leftPad(n int) string {
+	s := []byte("0000")
+	for i := 3; i >= 0 && n > 0; i-- {
+		s[i] = byte('0' + n%10)
+		n /= 10
+	}
+
+	return string(s)
+}
+
+func modules(pattern string) []bool {
+	out := make([]bool, len(pattern))
+	for i := range pattern {
+		out[i] = pattern[i] == '1'
+	}
+
+	return out
+}
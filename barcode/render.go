@@ -0,0 +1,140 @@
+package barcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// layout of an EAN-13 symbol, in modules
+const (
+	quietLeft   = 11
+	quietRight  = 7
+	addonGap    = 9
+	barHeight   = 60
+	guardHeight = 65
+	textSize    = 9
+	height      = guardHeight + textSize + 2
+	// add-on bars start below their digits
+	addonTop = textSize + 2
+)
+
+type rect struct {
+	x, y, w, h int
+}
+
+type text struct {
+	x, y  float64
+	value string
+}
+
+// Symbol is a laid out drawing, units are modules
+type Symbol struct {
+	Width  int
+	Height int
+	bars   []rect
+	texts  []text
+}
+
+// ISBNSymbol lays out the EAN-13 of the isbn, with the add-on when it is not empty
+func ISBNSymbol(isbn, addon string) (*Symbol, error) {
+	ean, err := ISBN13(isbn)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Symbol{Height: height}
+	x := quietLeft
+	for i, bar := range EAN13(ean) {
+		if !bar {
+			continue
+		}
+		h := barHeight
+		// start, centre and end guards are longer
+		if i < 3 || (i >= 45 && i < 50) || i >= 92 {
+			h = guardHeight
+		}
+		s.bars = append(s.bars, rect{x: x + i, w: 1, h: h})
+	}
+
+	baseline := float64(guardHeight + textSize)
+	s.texts = append(s.texts, text{x: float64(quietLeft) - 4, y: baseline, value: ean[:1]})
+	s.texts = append(s.texts, text{x: float64(quietLeft + 3 + 21), y: baseline, value: ean[1:7]})
+	s.texts = append(s.texts, text{x: float64(quietLeft + 50 + 21), y: baseline, value: ean[7:]})
+	x += 95 + quietRight
+
+	if addon != "" {
+		x = x - quietRight + addonGap
+		bars, err := EAN5(addon)
+		if err != nil {
+			return nil, err
+		}
+		for i, bar := range bars {
+			if bar {
+				s.bars = append(s.bars, rect{x: x + i, y: addonTop, w: 1, h: guardHeight - addonTop})
+			}
+		}
+		s.texts = append(s.texts, text{x: float64(x) + 23.5, y: textSize, value: addon})
+		x += len(bars) + 5
+	}
+
+	s.Width = x
+	return s, nil
+}
+
+// QRSymbol lays out a QR code of the content, with its quiet zone
+func QRSymbol(content string) (*Symbol, error) {
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+
+	bitmap := qr.Bitmap()
+	s := &Symbol{Width: len(bitmap), Height: len(bitmap)}
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				s.bars = append(s.bars, rect{x: x, y: y, w: 1, h: 1})
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// SVG draws the symbol, scale is the size of a module in pixels
+func (s *Symbol) SVG(w io.Writer, scale int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, s.Width*scale, s.Height*scale, s.Width, s.Height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, s.Width, s.Height)
+	for _, r := range s.bars {
+		fmt.Fprintf(&b, "M%d %dh%dv%dh-%dz", r.x, r.y, r.w, r.h, r.w)
+	}
+	b.WriteString(`"/>`)
+	for _, t := range s.texts {
+		fmt.Fprintf(&b, `<text x="%g" y="%g" font-family="monospace" font-size="%d" text-anchor="middle">%s</text>`, t.x, t.y, textSize, t.value)
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// PNG draws the bars only, scale is the size of a module in pixels
+func (s *Symbol) PNG(w io.Writer, scale int) error {
+	img := image.NewPaletted(image.Rect(0, 0, s.Width*scale, s.Height*scale), color.Palette{color.White, color.Black})
+	for _, r := range s.bars {
+		for y := r.y * scale; y < (r.y+r.h)*scale; y++ {
+			for x := r.x * scale; x < (r.x+r.w)*scale; x++ {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
@@ -0,0 +1,150 @@
+// Package cache keeps computed values for a while, in Redis to share them
+// between servers or in an in-process LRU for a single server without Redis.
+// Entries are filed under tags and a write drops every entry of its tags.
+// Loader reads through a Cache: one load per key however many requests miss
+// at once, misses of the database cached as well, and the database answers
+// when the cache is down.
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrMiss is returned by Get for keys that are not (or no longer) cached
+var ErrMiss = errors.New("cache: miss")
+
+type Cache interface {
+	// Get returns ErrMiss when the key is not cached
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set keeps the value for ttl, filed under the tags
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+	Delete(ctx context.Context, keys ...string) error
+	// Invalidate drops every entry filed under one of the tags
+	Invalidate(ctx context.Context, tags ...string) error
+	// Flush drops every entry
+	Flush(ctx context.Context) error
+}
+
+// first byte of the entries written by Loader
+const (
+	found    = 'v'
+	notFound = 'n'
+)
+
+type Loader struct {
+	Cache Cache
+	// load errors matching NotFound (errors.Is) are cached for NegativeTTL,
+	// a burst of requests for an unknown isbn hits the database once
+	NotFound    error
+	NegativeTTL time.Duration
+	// failures of the cache are logged here, nil drops them
+	ErrorLog *log.Logger
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// one load of a key, the requests missing the key at the same time wait for it
+type call struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// Fetch returns the cached value of key, or loads it, caches it for ttl under
+// the tags and returns it. The value is shared, callers must not change it.
+func (l *Loader) Fetch(ctx context.Context, key string, ttl time.Duration, tags []string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if l == nil || l.Cache == nil {
+		return load(ctx)
+	}
+
+	entry, err := l.Cache.Get(ctx, key)
+	if err == nil && len(entry) > 0 {
+		return l.decode(entry)
+	}
+	if err != nil && !errors.Is(err, ErrMiss) {
+		// down or slow, the database answers and Set below may still work
+		l.logf("cache get %s: %v", key, err)
+	}
+
+	return l.once(ctx, key, func() ([]byte, error) {
+		// the load is shared, it must not end with the request that started it
+		value, err := load(context.WithoutCancel(ctx))
+		switch {
+		case err == nil:
+			l.set(ctx, key, append([]byte{found}, value...), ttl, tags)
+		case l.NotFound != nil && errors.Is(err, l.NotFound) && l.NegativeTTL > 0:
+			l.set(ctx, key, []byte{notFound}, l.NegativeTTL, tags)
+		}
+
+		return value, err
+	})
+}
+
+// Invalidate drops every entry of the tags, a failure is logged, the entries
+// expire with their ttl anyway
+func (l *Loader) Invalidate(ctx context.Context, tags ...string) {
+	if l == nil || l.Cache == nil {
+		return
+	}
+
+	if err := l.Cache.Invalidate(ctx, tags...); err != nil {
+		l.logf("cache invalidate %v: %v", tags, err)
+	}
+}
+
+func (l *Loader) decode(entry []byte) ([]byte, error) {
+	if entry[0] == notFound {
+		return nil, l.NotFound
+	}
+
+	return entry[1:], nil
+}
+
+func (l *Loader) set(ctx context.Context, key string, entry []byte, ttl time.Duration, tags []string) {
+	if err := l.Cache.Set(context.WithoutCancel(ctx), key, entry, ttl, tags...); err != nil {
+		l.logf("cache set %s: %v", key, err)
+	}
+}
+
+// run fn once for every key at a time, a waiting request gives up when its
+// ctx is done, the load goes on for the others
+func (l *Loader) once(ctx context.Context, key string, fn func() ([]byte, error)) ([]byte, error) {
+	l.mu.Lock()
+	if c, ok := l.calls[key]; ok {
+		l.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.value, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c := &call{done: make(chan struct{})}
+	if l.calls == nil {
+		l.calls = map[string]*call{}
+	}
+	l.calls[key] = c
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		delete(l.calls, key)
+		l.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.value, c.err = fn()
+	return c.value, c.err
+}
+
+func (l *Loader) logf(format string, args ...any) {
+	if l.ErrorLog != nil {
+		l.ErrorLog.Printf(format, args...)
+	}
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisKey = "cache:"
+	// cache:tag:books is the set of the keys filed under books
+	redisTagKey = "cache:tag:"
+	// tag sets live at least this long, longer than the entries they list
+	tagTTL = 24 * time.Hour
+)
+
+// Redis keeps the entries in Redis, shared by every server using it
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := c.client.Get(ctx, redisKey+key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+
+	return value, err
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, redisKey+key, value, ttl)
+		for _, tag := range tags {
+			pipe.SAdd(ctx, redisTagKey+tag, key)
+			pipe.Expire(ctx, redisTagKey+tag, max(ttl, tagTTL))
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	full := make([]string, len(keys))
+	for i, key := range keys {
+		full[i] = redisKey + key
+	}
+
+	return c.client.Del(ctx, full...).Err()
+}
+
+// deletes the entries listed in the tag sets KEYS, ARGV[1] is the prefix of
+// the entries, and the sets. As a script it runs at once, an entry Set while
+// it runs is either deleted or filed in a set that is new.
+var invalidateScript = redis.NewScript(`
+local deleted = 0
+for _, tag in ipairs(KEYS) do
+  local members = redis.call('SMEMBERS', tag)
+  for i = 1, #members, 500 do
+    local batch = {}
+    for j = i, math.min(i + 499, #members) do
+      batch[#batch + 1] = ARGV[1] .. members[j]
+    end
+    deleted = deleted + redis.call('DEL', unpack(batch))
+  end
+  redis.call('DEL', tag)
+end
+return deleted
+`)
+
+func (c *Redis) Invalidate(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = redisTagKey + tag
+	}
+
+	return invalidateScript.Run(ctx, c.client, keys, redisKey).Err()
+}
+
+// Flush deletes the entries and tag sets under cache:, the other keys of the
+// Redis database (the view counts) stay
+func (c *Redis) Flush(ctx context.Context) error {
+	iter := c.client.Scan(ctx, 0, redisKey+"*", 500).Iterator()
+	keys := []string{}
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			if err := c.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return c.client.Del(ctx, keys...).Err()
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// a Redis of REDIS_TEST_ADDR, its database 15 is written to
+func testRedis(t *testing.T) *Redis {
+	t.Helper()
+	addr := os.Getenv("REDIS_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_TEST_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewRedis(client)
+}
+
+func TestRedisInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := testRedis(t)
+	prefix := "test:" + strconv.FormatInt(time.Now().UnixNano(), 10) + ":"
+	books, reviews := prefix+"books", prefix+"reviews"
+
+	// more entries than the script deletes at once
+	for i := 0; i < 1200; i++ {
+		if err := c.Set(ctx, prefix+"book:"+strconv.Itoa(i), []byte("x"), time.Minute, books); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Set(ctx, prefix+"review", []byte("x"), time.Minute, reviews); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, prefix+"both", []byte("x"), time.Minute, books, reviews); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Invalidate(ctx, books); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{prefix + "book:0", prefix + "book:1199", prefix + "both"} {
+		if _, err := c.Get(ctx, key); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(%s) after Invalidate = %v, want ErrMiss", key, err)
+		}
+	}
+	if _, err := c.Get(ctx, prefix+"review"); err != nil {
+		t.Errorf("Get of an entry of another tag = %v, want it kept", err)
+	}
+	if n, err := c.client.Exists(ctx, redisTagKey+books).Result(); err != nil || n != 0 {
+		t.Errorf("tag set after Invalidate = %d, %v, want it deleted", n, err)
+	}
+
+	if err := c.Invalidate(ctx, reviews, prefix+"unknown"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, prefix+"review"); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get(review) after Invalidate = %v, want ErrMiss", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package citation writes books as BibTeX, RIS or CSL-JSON so they can be
+// imported in reference managers like Zotero, Mendeley or JabRef.
+package citation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"test.iamgak.net/models"
+)
+
+const (
+	BibTeX  = "bibtex"
+	RIS     = "ris"
+	CSLJSON = "csl"
+)
+
+// content type of every format
+var ContentTypes = map[string]string{
+	BibTeX:  "application/x-bibtex; charset=utf-8",
+	RIS:     "application/x-research-info-systems; charset=utf-8",
+	CSLJSON: "application/vnd.citationstyles.csl+json",
+}
+
+// file extension of every format, used for downloads and the format suffix
+var Extensions = map[string]string{
+	BibTeX:  ".bib",
+	RIS:     ".ris",
+	CSLJSON: ".csl.json",
+}
+
+// Write the books in the format, one entry per book with the names of its
+// Contributors (Book.Author is split when they are not loaded)
+func Write(w io.Writer, format string, books []*models.Book) error {
+	switch format {
+	case BibTeX:
+		return writeBibTeX(w, books)
+	case RIS:
+		return writeRIS(w, books)
+	case CSLJSON:
+		return writeCSLJSON(w, books)
+	}
+
+	return fmt.Errorf("citation: unknown format %q", format)
+}
+
+// "George Orwell" -> "Orwell", "George"
+func splitName(name string) (family, given string) {
+	fields := strings.Fields(name)
+	if len(fields) < 2 {
+		return name, ""
+	}
+
+	return fields[len(fields)-1], strings.Join(fields[:len(fields)-1], " ")
+}
+
+// orwell9780451524935, stable and unique enough for a bibliography
+func citeKey(book *models.Book) string {
+	var b strings.Builder
+	if names := book.CreditedAs(models.RoleAuthor); len(names) > 0 {
+		family, _ := splitName(names[0])
+		for _, r := range strings.ToLower(family) {
+			if unicode.IsLetter(r) {
+				b.WriteRune(r)
+			}
+		}
+	}
+
+	for _, r := range book.ISBN {
+		if unicode.IsDigit(r) || r == 'X' || r == 'x' {
+			b.WriteRune(r)
+		}
+	}
+
+	if b.Len() == 0 {
+		return "book"
+	}
+
+	return b.String()
+}
+
+var bibtexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"&", `\&`,
+	"%", `\%`,
+	"$", `\$`,
+	"#", `\#`,
+	"_", `\_`,
+	"~", `\textasciitilde{}`,
+	"^", `\textasciicircum{}`,
+)
+
+func writeBibTeX(w io.Writer, books []*models.Book) error {
+	for _, book := range books {
+		fields := [][2]string{
+			{"title", book.Title},
+			{"author", strings.Join(book.CreditedAs(models.RoleAuthor), " and ")},
+			{"editor", strings.Join(book.CreditedAs(models.RoleEditor), " and ")},
+			{"translator", strings.Join(book.CreditedAs(models.RoleTranslator), " and ")},
+			{"isbn", book.ISBN},
+			{"abstract", book.Descriptions},
+			{"keywords", book.Genre},
+		}
+
+		if _, err := fmt.Fprintf(w, "@book{%s,\n", citeKey(book)); err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if f[1] == "" {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "  %s = {%s},\n", f[0], bibtexEscaper.Replace(f[1])); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, "}\n\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RIS tag of every role, illustrators have none
+var risCredits = [][2]string{
+	{"AU", models.RoleAuthor},
+	{"ED", models.RoleEditor},
+	{"A4", models.RoleTranslator},
+}
+
+// RIS lines are "TAG  - value", the value can not span lines
+func writeRIS(w io.Writer, books []*models.Book) error {
+	line := func(tag, value string) error {
+		value = strings.Join(strings.Fields(value), " ")
+		if value == "" {
+			return nil
+		}
+		_, err := fmt.Fprintf(w, "%s  - %s\r\n", tag, value)
+		return err
+	}
+
+	for _, book := range books {
+		if err := line("TY", "BOOK"); err != nil {
+			return err
+		}
+		if err := line("TI", book.Title); err != nil {
+			return err
+		}
+		for _, credit := range risCredits {
+			for _, name := range book.CreditedAs(credit[1]) {
+				family, given := splitName(name)
+				if given != "" {
+					name = family + ", " + given
+				}
+				if err := line(credit[0], name); err != nil {
+					return err
+				}
+			}
+		}
+		for _, f := range [][2]string{{"SN", book.ISBN}, {"AB", book.Descriptions}, {"KW", book.Genre}} {
+			if err := line(f[0], f[1]); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, "ER  - \r\n\r\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type cslItem struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Title       string    `json:"title"`
+	Author      []cslName `json:"author,omitempty"`
+	Editor      []cslName `json:"editor,omitempty"`
+	Translator  []cslName `json:"translator,omitempty"`
+	Illustrator []cslName `json:"illustrator,omitempty"`
+	ISBN        string    `json:"ISBN,omitempty"`
+	Abstract    string    `json:"abstract,omitempty"`
+	Genre       string    `json:"genre,omitempty"`
+}
+
+type cslName struct {
+	Family string `json:"family,omitempty"`
+	Given  string `json:"given,omitempty"`
+}
+
+func cslNames(names []string) []cslName {
+	var list []cslName
+	for _, name := range names {
+		family, given := splitName(name)
+		list = append(list, cslName{Family: family, Given: given})
+	}
+
+	return list
+}
+
+func writeCSLJSON(w io.Writer, books []*models.Book) error {
+	items := []cslItem{}
+	for _, book := range books {
+		item := cslItem{
+			ID:       citeKey(book),
+			Type:     "book",
+			Title:    book.Title,
+			ISBN:     book.ISBN,
+			Abstract: book.Descriptions,
+			Genre:    book.Genre,
+		}
+		item.Author = cslNames(book.CreditedAs(models.RoleAuthor))
+		item.Editor = cslNames(book.CreditedAs(models.RoleEditor))
+		item.Translator = cslNames(book.CreditedAs(models.RoleTranslator))
+		item.Illustrator = cslNames(book.CreditedAs(models.RoleIllustrator))
+		items = append(items, item)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
@@ -0,0 +1,195 @@
+package citation
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+func testBook() *models.Book {
+	return &models.Book{
+		ISBN:         "978-0-451-52493-5",
+		Title:        "Animal Farm",
+		Author:       "George Orwell",
+		Descriptions: "All animals are equal,\nbut some are more equal",
+		Genre:        "Satire",
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		name, family, given string
+	}{
+		{"George Orwell", "Orwell", "George"},
+		{"Ursula K. Le Guin", "Guin", "Ursula K. Le"},
+		{"Homer", "Homer", ""},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		family, given := splitName(tt.name)
+		if family != tt.family || given != tt.given {
+			t.Errorf("splitName(%q) = %q, %q, want %q, %q", tt.name, family, given, tt.family, tt.given)
+		}
+	}
+}
+
+func TestCiteKey(t *testing.T) {
+	tests := []struct {
+		name string
+		book *models.Book
+		want string
+	}{
+		{"author and isbn", testBook(), "orwell9780451524935"},
+		{"letters of the family name only", &models.Book{Author: "Flann O'Brien", ISBN: "0-14-118268-X"}, "obrien014118268X"},
+		{"first author", &models.Book{Author: "Terry Pratchett, Neil Gaiman", ISBN: "1"}, "pratchett1"},
+		{"first credited author", &models.Book{Contributors: []*models.Contributor{{Name: "Ana Ruiz", Role: models.RoleEditor}, {Name: "Jorge Luis Borges", Role: models.RoleAuthor}}}, "borges"},
+		{"nothing to go by", &models.Book{Title: "Untitled"}, "book"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := citeKey(tt.book); got != tt.want {
+				t.Errorf("citeKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBibTeX(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(b *models.Book)
+		want []string
+	}{
+		{"fields", func(b *models.Book) {}, []string{
+			"@book{orwell9780451524935,\n",
+			"  title = {Animal Farm},\n",
+			"  author = {George Orwell},\n",
+			"  isbn = {978-0-451-52493-5},\n",
+			"  keywords = {Satire},\n",
+			"}\n\n",
+		}},
+		{"escaped", func(b *models.Book) { b.Title = `100% {Pure} R&D: $5 #1 my_book ~x^2 C:\dir` }, []string{
+			`  title = {100\% \{Pure\} R\&D: \$5 \#1 my\_book \textasciitilde{}x\textasciicircum{}2 C:\textbackslash{}dir},`,
+		}},
+		{"credits", func(b *models.Book) {
+			b.Contributors = []*models.Contributor{
+				{Name: "Terry Pratchett", Role: models.RoleAuthor},
+				{Name: "Neil Gaiman", Role: models.RoleAuthor},
+				{Name: "Ana Ruiz", Role: models.RoleTranslator},
+			}
+		}, []string{
+			"  author = {Terry Pratchett and Neil Gaiman},\n",
+			"  translator = {Ana Ruiz},\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := testBook()
+			tt.edit(book)
+
+			var buf bytes.Buffer
+			if err := Write(&buf, BibTeX, []*models.Book{book}); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("BibTeX = %s\nwant %q", buf.String(), want)
+				}
+			}
+			if strings.Contains(buf.String(), "editor =") {
+				t.Errorf("BibTeX = %s, want no empty fields", buf.String())
+			}
+		})
+	}
+}
+
+func TestRIS(t *testing.T) {
+	book := testBook()
+	book.Contributors = []*models.Contributor{
+		{Name: "George Orwell", Role: models.RoleAuthor},
+		{Name: "Homer", Role: models.RoleEditor},
+		{Name: "Ana Ruiz", Role: models.RoleTranslator},
+		{Name: "Quentin Blake", Role: models.RoleIllustrator},
+	}
+	second := &models.Book{ISBN: "1", Title: "  Second\tbook  ", Author: "Anonymous"}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, RIS, []*models.Book{book, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	// one "TAG  - value" line per value, a value never spans lines
+	want := "TY  - BOOK\r\n" +
+		"TI  - Animal Farm\r\n" +
+		"AU  - Orwell, George\r\n" +
+		"ED  - Homer\r\n" +
+		"A4  - Ruiz, Ana\r\n" +
+		"SN  - 978-0-451-52493-5\r\n" +
+		"AB  - All animals are equal, but some are more equal\r\n" +
+		"KW  - Satire\r\n" +
+		"ER  - \r\n\r\n" +
+		"TY  - BOOK\r\n" +
+		"TI  - Second book\r\n" +
+		"AU  - Anonymous\r\n" +
+		"SN  - 1\r\n" +
+		"ER  - \r\n\r\n"
+	if buf.String() != want {
+		t.Errorf("RIS =\n%q\nwant\n%q", buf.String(), want)
+	}
+}
+
+func TestCSLJSON(t *testing.T) {
+	book := testBook()
+	book.Contributors = []*models.Contributor{
+		{Name: "George Orwell", Role: models.RoleAuthor},
+		{Name: "Quentin Blake", Role: models.RoleIllustrator},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, CSLJSON, []*models.Book{book}); err != nil {
+		t.Fatal(err)
+	}
+
+	var items []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("CSL-JSON = %s, want one item", buf.String())
+	}
+
+	item := items[0]
+	for key, want := range map[string]any{"id": "orwell9780451524935", "type": "book", "title": "Animal Farm", "ISBN": "978-0-451-52493-5", "genre": "Satire"} {
+		if item[key] != want {
+			t.Errorf("%s = %v, want %v", key, item[key], want)
+		}
+	}
+
+	author, _ := json.Marshal(item["author"])
+	illustrator, _ := json.Marshal(item["illustrator"])
+	if string(author) != `[{"family":"Orwell","given":"George"}]` || string(illustrator) != `[{"family":"Blake","given":"Quentin"}]` {
+		t.Errorf("author = %s, illustrator = %s", author, illustrator)
+	}
+	if _, ok := item["editor"]; ok {
+		t.Errorf("editor = %v, want none", item["editor"])
+	}
+
+	// an empty list is still an array
+	buf.Reset()
+	if err := Write(&buf, CSLJSON, nil); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("CSL-JSON of no books = %q, %v, want []", buf.String(), err)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "endnote", []*models.Book{testBook()}); err == nil || buf.Len() != 0 {
+		t.Errorf("Write of an unknown format = %v, %q, want an error and nothing written", err, buf.String())
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"test.iamgak.net/config"
+	"test.iamgak.net/marc"
+	"test.iamgak.net/models"
+	"test.iamgak.net/validator"
+)
+
+var (
+	errUserUsage  = errors.New("user: use create, activate, set-role or reset-password with -email")
+	errBookUsage  = errors.New("book: use import or export")
+	errCacheUsage = errors.New("cache: use flush")
+	errTokenUsage = errors.New("token: use purge")
+)
+
+// the messages of v in one error, like the json the handlers answer with
+func validationError(v *validator.Validator) error {
+	fields := make([]string, 0, len(v.Errors))
+	for field, message := range v.Errors {
+		fields = append(fields, field+": "+message)
+	}
+	sort.Strings(fields)
+
+	return errors.New(strings.Join(fields, ", "))
+}
+
+// a password ValidPassword accepts, printed for the operator to hand over
+func randomPassword() string {
+	const chars = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, 16)
+	rand.Read(b)
+	for i := range b {
+		b[i] = chars[int(b[i])%len(chars)]
+	}
+
+	return string(b)
+}
+
+// go run ./cmd/cli user create|activate|set-role|reset-password -email user@example.com [-password ..] [-role admin]
+func (app *application) runUser(args []string, cfg *config.Config) error {
+	if len(args) == 0 {
+		return errUserUsage
+	}
+
+	action := args[0]
+	fs := flag.NewFlagSet("user "+action, flag.ExitOnError)
+	email := fs.String("email", "", "email of the user")
+	password := fs.String("password", "", "new password for create and reset-password, a random one is printed when empty")
+	role := fs.String("role", "user", "role for create and set-role, "+strings.Join(models.Roles, " or "))
+	active := fs.Bool("active", true, "create the user activated, otherwise the activation link is printed")
+	dsnFlag := fs.String("dsn", cfg.DSN(), "data source name of the database.driver")
+	fs.Parse(args[1:])
+
+	if !slices.Contains([]string{"create", "activate", "set-role", "reset-password"}, action) || *email == "" {
+		fs.Usage()
+		return errUserUsage
+	}
+
+	v := &validator.Validator{Errors: make(map[string]string)}
+	v.CheckField(v.ValidEmail(*email), "email", "Invalid Email Format")
+	if action == "create" || action == "set-role" {
+		v.CheckField(slices.Contains(models.Roles, *role), "role", "Please, use a role of "+strings.Join(models.Roles, ", "))
+	}
+
+	generated := *password == ""
+	if generated {
+		*password = randomPassword()
+	}
+	v.ValidPassword(*password)
+
+	if !v.Valid() {
+		return validationError(v)
+	}
+
+	if err := app.openModels(*dsnFlag, cfg); err != nil {
+		return err
+	}
+	defer app.models.Close()
+
+	ctx := context.Background()
+	users := app.models.Users
+	uid, err := users.EmailExist(ctx, *email)
+	if err != nil {
+		return err
+	}
+	if action == "create" {
+		if uid != 0 {
+			return errors.New("email: Email already registered")
+		}
+		return app.createUser(ctx, *email, *password, *role, *active, generated)
+	}
+
+	if uid == 0 {
+		return models.ErrUserNotFound
+	}
+
+	switch action {
+	case "activate":
+		if err := users.Activate(ctx, uid); err != nil {
+			return err
+		}
+		fmt.Printf("activated user %d %s\n", uid, *email)
+	case "set-role":
+		if err := users.SetRole(ctx, uid, *role); err != nil {
+			return err
+		}
+		fmt.Printf("user %d %s is %s\n", uid, *email, *role)
+	case "reset-password":
+		if err := users.NewPassword(ctx, *password, uid); err != nil {
+			return err
+		}
+		fmt.Printf("changed the password of user %d %s\n", uid, *email)
+		if generated {
+			fmt.Printf("password: %s\n", *password)
+		}
+	}
+
+	return nil
+}
+
+func (app *application) createUser(ctx context.Context, email, password, role string, active, generated bool) error {
+	users := app.models.Users
+	uri := app.generateHash(email, strconv.FormatInt(time.Now().UnixNano(), 10))
+	uid, err := users.InsertUser(ctx, email, password, uri)
+	if err != nil {
+		return err
+	}
+
+	if active {
+		err = users.Activate(ctx, uid)
+	}
+	if err == nil && role != "user" {
+		err = users.SetRole(ctx, uid, role)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := users.ActivityLog(ctx, "Account Created", uid); err != nil {
+		return err
+	}
+	fmt.Printf("created user %d %s\n", uid, email)
+	if generated {
+		fmt.Printf("password: %s\n", password)
+	}
+	if !active {
+		fmt.Printf("activation: /user/activation/%s\n", uri)
+	}
+
+	return nil
+}
+
+// go run ./cmd/cli book import -file books.csv | book export [-file books.csv] [-format csv|json|jsonl|marc|marcxml]
+func (app *application) runBook(args []string, cfg *config.Config) error {
+	if len(args) == 0 {
+		return errBookUsage
+	}
+
+	switch args[0] {
+	case "import":
+		return app.runImport(args[1:], cfg)
+	case "export":
+		return app.runExport(args[1:], cfg)
+	}
+
+	return errBookUsage
+}
+
+func (app *application) runExport(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("book export", flag.ExitOnError)
+	file := fs.String("file", "", "file to write, standard output when empty")
+	format := fs.String("format", "", "csv, json, jsonl, marc or marcxml, guessed from the file extension, csv when empty")
+	dsnFlag := fs.String("dsn", cfg.DSN(), "data source name of the database.driver")
+	fs.Parse(args)
+
+	kind := "csv"
+	if *format != "" || *file != "" {
+		var err error
+		if kind, err = importFormat(*format, *file, ""); err != nil {
+			return err
+		}
+	}
+
+	if err := app.openModels(*dsnFlag, cfg); err != nil {
+		return err
+	}
+	defer app.models.Close()
+
+	books, err := app.models.Books.BooksListing(context.Background())
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := writeBooks(w, kind, books); err != nil {
+		return err
+	}
+
+	if *file != "" {
+		fmt.Printf("exported %d books to %s\n", len(books), *file)
+	}
+	return nil
+}
+
+// in the formats of import, a csv, json or jsonl export imports again as it is
+func writeBooks(w io.Writer, format string, books []*models.Book) error {
+	switch format {
+	case "csv":
+		out := csv.NewWriter(w)
+		out.Write([]string{"isbn", "title", "author", "price", "descriptions", "genre", "format", "language"})
+		for _, b := range books {
+			price := strconv.FormatFloat(float64(b.Price), 'f', 2, 32)
+			out.Write([]string{b.ISBN, b.Title, b.Author, price, b.Descriptions, b.Genre, b.Format, b.Language})
+		}
+		out.Flush()
+		return out.Error()
+	case "json":
+		return json.NewEncoder(w).Encode(books)
+	case "jsonl":
+		out := json.NewEncoder(w)
+		for _, b := range books {
+			if err := out.Encode(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	records := make([]*marc.Record, len(books))
+	for i, b := range books {
+		records[i] = marc.FromBook(b)
+	}
+
+	if format == "marcxml" {
+		return marc.WriteXML(w, records)
+	}
+	return marc.WriteBinary(w, records)
+}
+
+// go run ./cmd/cli cache flush [-tags books,reviews]
+func (app *application) runCache(args []string, cfg *config.Config) error {
+	if len(args) == 0 || args[0] != "flush" {
+		return errCacheUsage
+	}
+
+	fs := flag.NewFlagSet("cache flush", flag.ExitOnError)
+	tags := fs.String("tags", "", "drop the entries of these comma separated tags only, "+models.TagBooks+" or "+models.TagReviews)
+	fs.Parse(args[1:])
+
+	// without redis every server caches in its own memory, out of reach
+	client, c := openCache(cfg.Redis, cfg.Cache.Size, app.errorLog)
+	if client == nil {
+		return errors.New("cache: no redis at redis.addr, the servers cache in their own memory, restart them instead")
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if *tags != "" {
+		if err := c.Invalidate(ctx, strings.Split(*tags, ",")...); err != nil {
+			return err
+		}
+		fmt.Printf("dropped the cached %s\n", *tags)
+		return nil
+	}
+
+	if err := c.Flush(ctx); err != nil {
+		return err
+	}
+	fmt.Println("flushed the cache")
+	return nil
+}
+
+// go run ./cmd/cli token purge [-older-than 24h] [-logins]
+func (app *application) runToken(args []string, cfg *config.Config) error {
+	if len(args) == 0 || args[0] != "purge" {
+		return errTokenUsage
+	}
+
+	fs := flag.NewFlagSet("token purge", flag.ExitOnError)
+	olderThan := fs.Duration("older-than", 24*time.Hour, "reset links older than this are deleted, superseded ones always")
+	logins := fs.Bool("logins", false, "drop every login token as well, every user has to log in again")
+	dsnFlag := fs.String("dsn", cfg.DSN(), "data source name of the database.driver")
+	fs.Parse(args[1:])
+
+	if err := app.openModels(*dsnFlag, cfg); err != nil {
+		return err
+	}
+	defer app.models.Close()
+
+	n, err := app.models.Users.PurgeTokens(context.Background(), time.Now().Add(-*olderThan), *logins)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("purged %d tokens\n", n)
+	return nil
+}
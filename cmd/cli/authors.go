@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"test.iamgak.net/models"
+	"test.iamgak.net/validator"
+)
+
+// author with bio, aliases and every credited book
+func (app *application) AuthorInfo(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	author, err := app.models.Authors.GetAuthor(r.Context(), id)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	author.Books, err = app.models.Books.AuthorBooks(r.Context(), id)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendJSONResponse(w, 200, author)
+}
+
+// change name, bio and aliases of an author, admin only
+func (app *application) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	var author *models.Author
+	err := json.NewDecoder(r.Body).Decode(&author)
+	if err != nil || author == nil {
+		app.CustomError(w, "Unsupported or empty fields", 400)
+		return
+	}
+
+	author.ID = id
+	author.Name = strings.Join(strings.Fields(author.Name), " ")
+	validator := &validator.Validator{
+		Errors: make(map[string]string),
+	}
+
+	validator.CheckField(validator.NotBlank(author.Name), "name", "Please, fill the name field")
+	validator.CheckField(validator.MaxChars(author.Name, 255), "name", "Please, fill the NAME shorter than 255")
+	aliases := []string{}
+	for _, alias := range author.Aliases {
+		alias = strings.Join(strings.Fields(alias), " ")
+		validator.CheckField(validator.NotBlank(alias), "aliases", "Please, remove the empty aliases")
+		validator.CheckField(!strings.EqualFold(alias, author.Name), "aliases", "Please, do not repeat the name as alias")
+		aliases = append(aliases, alias)
+	}
+	author.Aliases = aliases
+
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return
+	}
+
+	err = app.models.Authors.UpdateAuthor(r.Context(), author)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Author Updated", userID(r.Context()))
+	resp := app.sendMessage(true, "Author Record Updated, Sucessfully")
+	app.sendJSONResponse(w, 200, resp)
+}
+
+// fold duplicate authors, like "G. Orwell", into the author of the url, admin only
+func (app *application) MergeAuthors(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	var input struct {
+		Authors []int64 `json:"authors"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil || len(input.Authors) == 0 {
+		app.CustomError(w, "authors should list the ids to merge", 400)
+		return
+	}
+
+	err = app.models.Authors.MergeAuthors(r.Context(), id, input.Authors)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Authors Merged", userID(r.Context()))
+	resp := app.sendMessage(true, "Authors Merged, Sucessfully")
+	app.sendJSONResponse(w, 200, resp)
+}
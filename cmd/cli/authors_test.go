@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+func TestAuthorRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	_, reader := ts.login("reader@example.com", "user")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/book/create", `{"isbn":"978-0-00-000002-8","title":"Children of Dune","author":"F. Herbert","price":8.99,"descriptions":"The sequel","genre":"Science Fiction"}`, admin), http.StatusOK)
+
+	herbert := ts.book("978-0-00-000001-1").Contributors[0].AuthorID
+	duplicate := ts.book("978-0-00-000002-8").Contributors[0].AuthorID
+	if herbert == 0 || duplicate == 0 || herbert == duplicate {
+		t.Fatalf("author ids = %d and %d, want two authors", herbert, duplicate)
+	}
+	info := "/author/" + strconv.FormatInt(herbert, 10)
+
+	author := decode[*models.Author](t, ts.do(http.MethodGet, info, "", ""))
+	if author.Name != "Frank Herbert" || len(author.Books) != 1 || author.Books[0].Title != "Dune" {
+		t.Errorf("author = %+v, want Frank Herbert with Dune", author)
+	}
+	ts.expect(ts.do(http.MethodGet, "/author/999", "", ""), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodGet, "/author/abc", "", ""), http.StatusNotFound)
+
+	update := "/admin/author/update/" + strconv.FormatInt(herbert, 10)
+	ts.expect(ts.do(http.MethodPost, update, `{"name":"Frank Herbert","bio":"Born in Tacoma"}`, reader), http.StatusForbidden)
+	ts.expect(ts.do(http.MethodPost, update, `{"name":" ","aliases":[]}`, admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, update, `{"name":"Frank Herbert","aliases":["frank herbert"]}`, admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/admin/author/update/999", `{"name":"Nobody"}`, admin), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodPost, update, `{"name":"Frank  Herbert","bio":"Born in Tacoma","aliases":["Franklin Herbert"]}`, admin), http.StatusOK)
+
+	author = decode[*models.Author](t, ts.do(http.MethodGet, info, "", ""))
+	if author.Name != "Frank Herbert" || author.Bio != "Born in Tacoma" || len(author.Aliases) != 1 {
+		t.Errorf("updated author = %+v", author)
+	}
+
+	merge := "/admin/author/merge/" + strconv.FormatInt(herbert, 10)
+	ts.expect(ts.do(http.MethodPost, merge, `{"authors":[]}`, admin), http.StatusBadRequest)
+	ts.expect(ts.do(http.MethodPost, merge, `{"authors":[`+strconv.FormatInt(duplicate, 10)+`]}`, admin), http.StatusOK)
+
+	author = decode[*models.Author](t, ts.do(http.MethodGet, info, "", ""))
+	if len(author.Books) != 2 {
+		t.Errorf("books after the merge = %v, want both", author.Books)
+	}
+	ts.expect(ts.do(http.MethodGet, "/author/"+strconv.FormatInt(duplicate, 10), "", ""), http.StatusNotFound)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"test.iamgak.net/barcode"
+	"test.iamgak.net/models"
+)
+
+const (
+	// barcodes only change with the price add-on, keep them a day
+	barcodeCacheTTL = 24 * time.Hour
+	// pixels per module
+	barcodeScale = 3
+	qrScale      = 8
+)
+
+var imageTypes = map[string]string{
+	".svg": "image/svg+xml",
+	".png": "image/png",
+}
+
+func (app *application) symbolBook(w http.ResponseWriter, r *http.Request) (*models.Book, string, bool) {
+	ext := path.Ext(r.URL.Path)
+	if _, ok := imageTypes[ext]; !ok {
+		app.notFound(w)
+		return nil, "", false
+	}
+
+	isbn := httprouter.ParamsFromContext(r.Context()).ByName("isbn")
+	book, err := app.models.Books.FindBook(r.Context(), isbn)
+	if err != nil {
+		app.modelError(w, err)
+		return nil, "", false
+	}
+
+	return book, ext, true
+}
+
+func drawSymbol(symbol *barcode.Symbol, ext string, scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if ext == ".png" {
+		err = symbol.PNG(&buf, scale)
+	} else {
+		err = symbol.SVG(&buf, scale)
+	}
+
+	return buf.Bytes(), err
+}
+
+// EAN-13 of the isbn as /barcode.svg or /barcode.png, ?addon=51999 adds an EAN-5
+// price code, ?addon=price builds it from the book price
+func (app *application) BookBarcode(w http.ResponseWriter, r *http.Request) {
+	book, ext, ok := app.symbolBook(w, r)
+	if !ok {
+		return
+	}
+
+	addon := r.URL.Query().Get("addon")
+	if addon == "price" {
+		addon = barcode.PriceAddon(book.Price)
+	}
+
+	symbol, err := barcode.ISBNSymbol(book.ISBN, addon)
+	if err != nil {
+		app.CustomError(w, strings.TrimPrefix(err.Error(), "barcode: "), 400)
+		return
+	}
+
+	key := "barcode:" + book.ISBN + ":" + addon + ext
+	app.serveCached(w, r, key, imageTypes[ext], barcodeCacheTTL, nil, func() ([]byte, error) {
+		return drawSymbol(symbol, ext, barcodeScale)
+	})
+}
+
+// QR code linking to the book page, as /qr.svg or /qr.png
+func (app *application) BookQR(w http.ResponseWriter, r *http.Request) {
+	book, ext, ok := app.symbolBook(w, r)
+	if !ok {
+		return
+	}
+
+	link := app.baseURL + "/book/" + url.PathEscape(book.ISBN)
+	key := "qr:" + link + ext
+	app.serveCached(w, r, key, imageTypes[ext], barcodeCacheTTL, nil, func() ([]byte, error) {
+		symbol, err := barcode.QRSymbol(link)
+		if err != nil {
+			return nil, err
+		}
+
+		return drawSymbol(symbol, ext, qrScale)
+	})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBarcodeRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	ts.expect(ts.do(http.MethodPost, "/book/create", `{"isbn":"978-0-451-52493-5","title":"Animal Farm","author":"George Orwell","price":9.99,"descriptions":"A farm","genre":"Satire"}`, admin), http.StatusOK)
+	// the check digit of the test book is not the one of its first twelve digits
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	for _, path := range []string{"barcode.svg", "barcode.svg?addon=price", "barcode.svg?addon=90000", "qr.svg"} {
+		w := ts.do(http.MethodGet, "/book/978-0-451-52493-5/"+path, "", "")
+		ts.expect(w, http.StatusOK)
+		if w.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(w.Body.String(), "<svg") {
+			t.Errorf("%s = %s, want an svg", path, w.Body.String())
+		}
+	}
+
+	for _, path := range []string{"barcode.png", "qr.png"} {
+		w := ts.do(http.MethodGet, "/book/978-0-451-52493-5/"+path, "", "")
+		ts.expect(w, http.StatusOK)
+		if _, err := png.Decode(bytes.NewReader(w.Body.Bytes())); err != nil || w.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("%s is %s: %v, want a png", path, w.Header().Get("Content-Type"), err)
+		}
+	}
+
+	// the price add-on of $9.99 is 50999
+	plain := ts.do(http.MethodGet, "/book/978-0-451-52493-5/barcode.svg", "", "").Body.String()
+	priced := ts.do(http.MethodGet, "/book/978-0-451-52493-5/barcode.svg?addon=price", "", "").Body.String()
+	explicit := ts.do(http.MethodGet, "/book/978-0-451-52493-5/barcode.svg?addon=50999", "", "").Body.String()
+	if priced == plain || priced != explicit {
+		t.Error("barcode with ?addon=price is not the one of the add-on 50999")
+	}
+
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-451-52493-5/barcode.svg?addon=12", "", ""), http.StatusBadRequest)
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-00-000001-1/barcode.svg", "", ""), http.StatusBadRequest)
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-00-000009-7/barcode.svg", "", ""), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-00-000009-7/qr.png", "", ""), http.StatusNotFound)
+
+	// the qr code of any stored book, whatever its isbn
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-00-000001-1/qr.svg", "", ""), http.StatusOK)
+}
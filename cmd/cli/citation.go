@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"test.iamgak.net/citation"
+	"test.iamgak.net/models"
+)
+
+// accepted names of the ?format= parameter
+var citationFormats = map[string]string{
+	"bibtex":   citation.BibTeX,
+	"bib":      citation.BibTeX,
+	"ris":      citation.RIS,
+	"csl":      citation.CSLJSON,
+	"csl-json": citation.CSLJSON,
+	"csljson":  citation.CSLJSON,
+}
+
+// citation format asked by a suffix on the isbn (978-0451524935.bib), the
+// format parameter or the Accept header, empty for the plain json api.
+// The isbn is returned without the suffix.
+func citationFormat(r *http.Request, isbn string) (string, string) {
+	for format, ext := range citation.Extensions {
+		if strings.HasSuffix(isbn, ext) {
+			return strings.TrimSuffix(isbn, ext), format
+		}
+	}
+
+	if format, ok := citationFormats[strings.ToLower(r.URL.Query().Get("format"))]; ok {
+		return isbn, format
+	}
+
+	accept := r.Header.Get("Accept")
+	for format, contentType := range citation.ContentTypes {
+		mime, _, _ := strings.Cut(contentType, ";")
+		if strings.Contains(accept, mime) {
+			return isbn, format
+		}
+	}
+
+	return isbn, ""
+}
+
+func (app *application) sendCitation(w http.ResponseWriter, format, filename string, books []*models.Book) {
+	w.Header().Set("Content-Type", citation.ContentTypes[format])
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+citation.Extensions[format]+`"`)
+	err := citation.Write(w, format, books)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// books reviewed by the logged in user, ?format=bibtex|ris|csl
+func (app *application) MyReviewCitations(w http.ResponseWriter, r *http.Request) {
+	_, format := citationFormat(r, "")
+	if format == "" {
+		app.CustomError(w, "format should be bibtex, ris or csl", 400)
+		return
+	}
+
+	books, err := app.models.Books.ReviewedBooks(r.Context(), userID(r.Context()))
+	if err == nil {
+		err = app.loadContributors(r.Context(), books)
+	}
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendCitation(w, format, "my-reviewed-books", books)
+}
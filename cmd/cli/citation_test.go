@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"test.iamgak.net/citation"
+)
+
+func TestCitationRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	_, reader := ts.login("reader@example.com", "user")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	tests := []struct {
+		path   string
+		format string
+		want   string
+	}{
+		{"/book/search/978-0-00-000001-1.bib/", citation.BibTeX, "@book{"},
+		{"/book/search/978-0-00-000001-1.ris/", citation.RIS, "TY  - BOOK"},
+		{"/book/search/978-0-00-000001-1.csl.json/", citation.CSLJSON, `"type": "book"`},
+		{"/book/search/978-0-00-000001-1/?format=bib", citation.BibTeX, "@book{"},
+		{"/book/search/978-0-00-000001-1/?format=RIS", citation.RIS, "TY  - BOOK"},
+	}
+
+	for _, tt := range tests {
+		w := ts.do(http.MethodGet, tt.path, "", "")
+		ts.expect(w, http.StatusOK)
+		if got := w.Header().Get("Content-Type"); got != citation.ContentTypes[tt.format] {
+			t.Errorf("%s is %s, want %s", tt.path, got, citation.ContentTypes[tt.format])
+		}
+		if disposition := w.Header().Get("Content-Disposition"); !strings.Contains(disposition, "978-0-00-000001-1"+citation.Extensions[tt.format]) {
+			t.Errorf("%s is the attachment %s", tt.path, disposition)
+		}
+		if body := w.Body.String(); !strings.Contains(body, tt.want) || !strings.Contains(body, "Herbert") {
+			t.Errorf("%s = %s, want a citation of Dune by Herbert", tt.path, body)
+		}
+	}
+	ts.expect(ts.do(http.MethodGet, "/book/search/978-0-00-000009-7.bib/", "", ""), http.StatusNotFound)
+
+	// every contributor is credited in their role
+	ts.expect(ts.do(http.MethodPost, "/book/create", creditedBook, admin), http.StatusOK)
+	credits := map[string][]string{
+		".bib":      {"author = {Frank Herbert and Brian Herbert}", "translator = {Ana Ruiz}"},
+		".ris":      {"AU  - Herbert, Frank", "AU  - Herbert, Brian", "A4  - Ruiz, Ana"},
+		".csl.json": {`"translator": [`, `"family": "Ruiz"`},
+	}
+	for ext, want := range credits {
+		body := ts.do(http.MethodGet, "/book/search/978-0-00-000002-2"+ext+"/", "", "").Body.String()
+		for _, line := range want {
+			if !strings.Contains(body, line) {
+				t.Errorf("%s citation = %s, want %q", ext, body, line)
+			}
+		}
+	}
+
+	ts.expect(ts.do(http.MethodGet, "/myreview/export?format=bibtex", "", ""), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodGet, "/myreview/export", "", reader), http.StatusBadRequest)
+	if body := ts.do(http.MethodGet, "/myreview/export?format=ris", "", reader).Body.String(); body != "" {
+		t.Errorf("export without reviews = %q, want nothing", body)
+	}
+
+	ts.expect(ts.do(http.MethodPost, "/review/create", `{"isbn":"978-0-00-000001-1","title":"A classic","rating":5,"price":9.99,"descriptions":"Spice"}`, reader), http.StatusOK)
+	w := ts.do(http.MethodGet, "/myreview/export?format=ris", "", reader)
+	ts.expect(w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "TI  - Dune") || !strings.Contains(w.Header().Get("Content-Disposition"), "my-reviewed-books.ris") {
+		t.Errorf("export = %s, want Dune as RIS", w.Body.String())
+	}
+}
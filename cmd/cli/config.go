@@ -1,36 +1,72 @@
 package main
 
 import (
-	"database/sql" // db
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+	"test.iamgak.net/cache"
+	"test.iamgak.net/config"
+	"test.iamgak.net/models"
+	"test.iamgak.net/storage"
 )
 
-// for a given DSN
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		return nil, err
+// for a given DSN of the database.driver
+func openDB(driver, dsn string) (*models.DB, error) {
+	return models.Open(models.Dialect(driver), dsn)
+}
+
+// redis for the cache and the view counts, without redis.addr or when redis
+// does not answer the cache is an in-process LRU of size entries
+func openCache(cfg config.Redis, size int, errorLog *log.Logger) (*redis.Client, cache.Cache) {
+	if cfg.Addr == "" {
+		return nil, cache.NewLRU(size)
 	}
-	if err = db.Ping(); err != nil {
-		return nil, err
+
+	client := redis.NewClient(&redis.Options{
+		Addr:        cfg.Addr,
+		Password:    cfg.Password,
+		DB:          cfg.DB,
+		DialTimeout: cfg.DialTimeout,
+	})
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		errorLog.Printf("redis at %s: %v, caching in process instead", cfg.Addr, err)
+		client.Close()
+		return nil, cache.NewLRU(size)
 	}
-	return db, nil
+
+	return client, cache.NewRedis(client)
 }
 
-// func Init() error {
-// 	return createAccountTable()
-// }
-
-// func createAccountTable(s *sql.DB) error {
-// 	query := `create table if not exists bookreviewss (
-// 		id INT PRIMARY KEY AUTOINCREMENT,
-// 		isbn VARCHAR(50) NOT NULL,
-// 		title VARCHAR(100) NOT NULL,
-// 		author VARCHAR(50) NOT NULL,
-// 		descriptions TEXT NOT NULL,
-// 		uid INT NOT NULL,
-// 		created_at DATETIME DEFAULT CURRENTTIMESTAMP
-// 	);`
-
-// 	_, err := s.db.Exec(query)
-// 	return err
-// }
+// db and models for the cli subcommands, with the redis of the server so
+// their writes drop its cached books
+func (app *application) openModels(dsn string, cfg *config.Config) error {
+	db, err := openDB(cfg.Database.Driver, dsn)
+	if err != nil {
+		return err
+	}
+
+	client, c := openCache(cfg.Redis, cfg.Cache.Size, app.errorLog)
+	app.db = db
+	app.models = models.Constructor(db, client, c)
+	app.models.Cache.ErrorLog = app.errorLog
+	return nil
+}
+
+// local keeps the covers in covers.dir and serves them under /covers/, s3 puts
+// them in an S3 compatible bucket
+func coverStorage(cfg config.Covers) (storage.Storage, error) {
+	if cfg.Storage == "s3" {
+		return &storage.S3{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PublicURL: cfg.S3PublicURL,
+		}, nil
+	}
+
+	return storage.NewLocal(cfg.Dir, "/covers/")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"test.iamgak.net/covers"
+	"test.iamgak.net/models"
+	"test.iamgak.net/storage"
+)
+
+// a multipart book is the cover plus a little json
+const maxBookForm = 6 << 20
+
+// book fields as a json body, or a multipart form with the json in the field
+// book and the image in the field cover, the cover is nil when not uploaded
+func (app *application) decodeBook(w http.ResponseWriter, r *http.Request) (*models.Book, multipart.File, bool) {
+	var book *models.Book
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err := json.NewDecoder(r.Body).Decode(&book)
+		if err != nil || book == nil {
+			app.errorLog.Print(err)
+			app.CustomError(w, "Unsupported or empty fields", 400)
+			return nil, nil, false
+		}
+		return book, nil, true
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBookForm)
+	if err := r.ParseMultipartForm(maxBookForm); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			app.CustomError(w, "upload is too large", 413)
+		} else {
+			app.CustomError(w, "Unsupported or empty fields", 400)
+		}
+		return nil, nil, false
+	}
+
+	err := json.Unmarshal([]byte(r.FormValue("book")), &book)
+	if err != nil || book == nil {
+		app.CustomError(w, "the book fields should be json in the form field book", 400)
+		return nil, nil, false
+	}
+
+	file, _, err := r.FormFile("cover")
+	if errors.Is(err, http.ErrMissingFile) {
+		return book, nil, true
+	}
+	if err != nil {
+		app.CustomError(w, "Unsupported cover upload", 400)
+		return nil, nil, false
+	}
+
+	return book, file, true
+}
+
+// store the uploaded cover with its thumbnails, returns the storage key
+func (app *application) saveCover(w http.ResponseWriter, r *http.Request, isbn string, file multipart.File) (string, bool) {
+	defer file.Close()
+	key, err := app.covers.Save(r.Context(), isbn, file)
+	switch {
+	case errors.Is(err, covers.ErrTooLarge):
+		app.CustomError(w, strings.TrimPrefix(err.Error(), "covers: "), 413)
+		return "", false
+	case errors.Is(err, covers.ErrUnsupported):
+		app.CustomError(w, strings.TrimPrefix(err.Error(), "covers: "), 415)
+		return "", false
+	case err != nil:
+		app.serverError(w, err)
+		return "", false
+	}
+
+	return key, true
+}
+
+// remove a stored cover nothing points at, a failure only leaves files behind
+func (app *application) deleteCover(ctx context.Context, key string) {
+	if err := app.covers.Delete(ctx, key); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// the local storage is served by us, a bucket serves its own files
+func (app *application) coverFiles() http.Handler {
+	local, ok := app.covers.Store.(*storage.Local)
+	if !ok {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			app.notFound(w)
+		})
+	}
+
+	return http.StripPrefix("/covers/", http.FileServer(http.Dir(local.Root)))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"test.iamgak.net/storage"
+)
+
+// a png of width x height
+func testImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	img.Set(0, 0, color.Black)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// a multipart book with the json in the field book and cover as the field cover
+func (ts *testServer) bookForm(path, book string, cover []byte, login string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	err := form.WriteField("book", book)
+	if err == nil && cover != nil {
+		var part io.Writer
+		part, err = form.CreateFormFile("cover", "cover.png")
+		if err == nil {
+			_, err = part.Write(cover)
+		}
+	}
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, path, body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	r.AddCookie(&http.Cookie{Name: "ldata", Value: login})
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestCoverRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+
+	ts.expect(ts.bookForm("/book/create", testBook, []byte("not an image"), admin), http.StatusUnsupportedMediaType)
+	ts.expect(ts.bookForm("/book/create", `{"isbn":`, nil, admin), http.StatusBadRequest)
+	ts.expect(ts.bookForm("/book/create", testBook, testImage(t, 800, 1200), admin), http.StatusOK)
+
+	covers := ts.book("978-0-00-000001-1").Covers
+	if len(covers) != 4 {
+		t.Fatalf("covers = %v, want the original and three thumbnails", covers)
+	}
+
+	widths := map[string]int{"original": 800, "small": 120, "medium": 300, "large": 600}
+	for name, width := range widths {
+		link, err := url.Parse(covers[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := ts.do(http.MethodGet, link.Path, "", "")
+		ts.expect(w, http.StatusOK)
+		decode := jpeg.DecodeConfig
+		if name == "original" {
+			decode = png.DecodeConfig
+		}
+		cfg, err := decode(bytes.NewReader(w.Body.Bytes()))
+		if err != nil || cfg.Width != width || cfg.Height != width*3/2 {
+			t.Errorf("%s cover is %dx%d: %v, want %dx%d", name, cfg.Width, cfg.Height, err, width, width*3/2)
+		}
+	}
+	ts.expect(ts.do(http.MethodGet, "/covers/978-0-00-000009-7/small.jpg", "", ""), http.StatusNotFound)
+
+	// a refused write keeps no files of its cover
+	stored := ts.coverFiles()
+	_, outsider := ts.login("outsider@example.com", "user")
+	ts.expect(ts.bookForm("/book/update/978-0-00-000001-1", testBook, testImage(t, 400, 400), outsider), http.StatusForbidden)
+	if files := ts.coverFiles(); len(files) != len(stored) {
+		t.Errorf("cover files after a refused update = %v, want %v", files, stored)
+	}
+
+	// a new cover replaces the old one
+	old, _ := url.Parse(covers["medium"])
+	ts.expect(ts.bookForm("/book/update/978-0-00-000001-1", testBook, testImage(t, 400, 400), admin), http.StatusOK)
+	link, _ := url.Parse(ts.book("978-0-00-000001-1").Covers["medium"])
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(ts.do(http.MethodGet, link.Path, "", "").Body.Bytes()))
+	if err != nil || cfg.Width != 300 || cfg.Height != 300 {
+		t.Errorf("medium cover after the update is %dx%d: %v, want 300x300", cfg.Width, cfg.Height, err)
+	}
+	ts.expect(ts.do(http.MethodGet, old.Path, "", ""), http.StatusNotFound)
+	if files := ts.coverFiles(); len(files) != len(stored) {
+		t.Errorf("cover files after the update = %v, want only the new cover", files)
+	}
+
+	// an update without a cover keeps it
+	ts.expect(ts.do(http.MethodPost, "/book/update/978-0-00-000001-1", testBook, admin), http.StatusOK)
+	if got := ts.book("978-0-00-000001-1").Covers["medium"]; got != link.String() {
+		t.Errorf("medium cover after an update without one = %q, want %q", got, link)
+	}
+}
+
+// every file in the cover storage
+func (ts *testServer) coverFiles() []string {
+	ts.t.Helper()
+	var files []string
+	root := ts.app.covers.Store.(*storage.Local).Root
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+
+	return files
+}
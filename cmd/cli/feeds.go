@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"test.iamgak.net/feed"
+	"test.iamgak.net/models"
+	"test.iamgak.net/opds"
+)
+
+const (
+	// entries per feed
+	feedLimit = 50
+	// built feeds are kept in the cache for this long
+	feedCacheTTL = 5 * time.Minute
+)
+
+// ?format=rss, Atom otherwise
+func feedFormat(r *http.Request) string {
+	if strings.EqualFold(r.URL.Query().Get("format"), feed.RSS) {
+		return feed.RSS
+	}
+
+	return feed.Atom
+}
+
+// build the feed once per cache period, it is rendered for every request with
+// the url it was asked for as Self
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, build func(base string) (*feed.Feed, error)) {
+	key := "feed:" + app.baseURL + r.URL.Path
+	data, err := app.models.Cache.Fetch(r.Context(), key, feedCacheTTL, []string{models.TagBooks, models.TagReviews}, func(ctx context.Context) ([]byte, error) {
+		f, err := build(app.baseURL)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(f)
+	})
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	f := &feed.Feed{}
+	if err := json.Unmarshal(data, f); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	format := feedFormat(r)
+	f.Self = app.baseURL + r.URL.RequestURI()
+	var buf bytes.Buffer
+	if err := feed.Write(&buf, format, f); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	serveBody(w, r, buf.Bytes(), feed.ContentTypes[format], feedCacheTTL)
+}
+
+// recently added books, overall
+func (app *application) FeedBooks(w http.ResponseWriter, r *http.Request) {
+	app.serveFeed(w, r, func(base string) (*feed.Feed, error) {
+		return app.bookFeed(r.Context(), base, models.BookFilter{}, "urn:bookstore:feed:books", "New books", base+"/opds/books")
+	})
+}
+
+// recently added books of a genre
+func (app *application) FeedGenre(w http.ResponseWriter, r *http.Request) {
+	genre := httprouter.ParamsFromContext(r.Context()).ByName("name")
+	app.serveFeed(w, r, func(base string) (*feed.Feed, error) {
+		link := base + "/opds/genre/" + url.PathEscape(genre)
+		return app.bookFeed(r.Context(), base, models.BookFilter{Genre: genre}, "urn:bookstore:feed:genre:"+genre, "New "+genre+" books", link)
+	})
+}
+
+// recently added books of an author
+func (app *application) FeedAuthor(w http.ResponseWriter, r *http.Request) {
+	author := httprouter.ParamsFromContext(r.Context()).ByName("name")
+	app.serveFeed(w, r, func(base string) (*feed.Feed, error) {
+		link := base + "/opds/author/" + url.PathEscape(author)
+		return app.bookFeed(r.Context(), base, models.BookFilter{Author: author}, "urn:bookstore:feed:author:"+author, "New books by "+author, link)
+	})
+}
+
+func (app *application) bookFeed(ctx context.Context, base string, filter models.BookFilter, id, title, link string) (*feed.Feed, error) {
+	books, err := app.models.Books.RecentBooks(ctx, filter, feedLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &feed.Feed{
+		ID:          id,
+		Title:       title + " | " + opds.StoreName,
+		Link:        link,
+		Description: title + " in the " + opds.StoreName + " catalog",
+		Author:      opds.StoreName,
+	}
+
+	for _, b := range books {
+		b.Contributors, err = app.models.Books.Contributors(ctx, b.ISBN)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &feed.Entry{
+			ID:        "urn:isbn:" + b.ISBN,
+			Title:     b.Title,
+			Link:      base + "/book/" + url.PathEscape(b.ISBN),
+			Authors:   b.CreditedAs(models.RoleAuthor),
+			Summary:   b.Descriptions,
+			Published: b.CreatedAt,
+			Updated:   b.UpdatedAt,
+		}
+		if b.Genre != "" {
+			entry.Categories = []string{b.Genre}
+		}
+		f.Entries = append(f.Entries, entry)
+	}
+
+	f.Updated = feed.LastUpdated(f.Entries, time.Unix(0, 0))
+	return f, nil
+}
+
+// newest reviews of a book
+func (app *application) FeedReviews(w http.ResponseWriter, r *http.Request) {
+	isbn := httprouter.ParamsFromContext(r.Context()).ByName("isbn")
+	app.serveFeed(w, r, func(base string) (*feed.Feed, error) {
+		reviews, err := app.models.Review.RecentReviews(r.Context(), isbn, feedLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		link := base + "/book/" + url.PathEscape(isbn)
+		f := &feed.Feed{
+			ID:          "urn:bookstore:feed:reviews:" + isbn,
+			Title:       "Reviews of " + isbn + " | " + opds.StoreName,
+			Link:        link,
+			Description: "New reviews of the book " + isbn,
+			Author:      opds.StoreName,
+		}
+
+		for _, rv := range reviews {
+			f.Entries = append(f.Entries, &feed.Entry{
+				ID:        "urn:bookstore:review:" + strconv.FormatInt(rv.ID, 10),
+				Title:     fmt.Sprintf("%s (%g/%d)", rv.Title, rv.Rating, bestRating),
+				Link:      link,
+				Authors:   []string{"Reader " + strconv.FormatInt(rv.Uid, 10)},
+				Summary:   rv.Descriptions,
+				Published: rv.CreatedAt,
+				Updated:   rv.CreatedAt,
+			})
+		}
+
+		f.Updated = feed.LastUpdated(f.Entries, time.Unix(0, 0))
+		return f, nil
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"test.iamgak.net/feed"
+)
+
+func TestFeedRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	_, reader := ts.login("reader@example.com", "user")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/review/create", `{"isbn":"978-0-00-000001-1","title":"A classic","rating":5,"price":9.99,"descriptions":"Spice must flow"}`, reader), http.StatusOK)
+
+	tests := []struct {
+		path  string
+		title string
+		// text of the entry of Dune or of its review, "" for a feed without entries
+		entry string
+	}{
+		{"/feed/books", "New books", "urn:isbn:978-0-00-000001-1"},
+		{"/feed/genre/Science%20Fiction", "New Science Fiction books", "urn:isbn:978-0-00-000001-1"},
+		{"/feed/genre/Poetry", "New Poetry books", ""},
+		{"/feed/author/Frank%20Herbert", "New books by Frank Herbert", "urn:isbn:978-0-00-000001-1"},
+		{"/feed/author/Isaac%20Asimov", "New books by Isaac Asimov", ""},
+		{"/feed/reviews/978-0-00-000001-1", "Reviews of 978-0-00-000001-1", "Spice must flow"},
+		{"/feed/reviews/978-0-00-000009-7", "Reviews of 978-0-00-000009-7", ""},
+	}
+
+	for _, format := range []string{feed.Atom, feed.RSS} {
+		for _, tt := range tests {
+			uri := tt.path + "?format=" + format
+			w := ts.do(http.MethodGet, uri, "", "")
+			ts.expect(w, http.StatusOK)
+			if got := w.Header().Get("Content-Type"); got != feed.ContentTypes[format] {
+				t.Errorf("%s is %s, want %s", uri, got, feed.ContentTypes[format])
+			}
+
+			body := w.Body.String()
+			if !strings.Contains(body, tt.title) {
+				t.Errorf("%s = %s, want the title %q", uri, body, tt.title)
+			}
+			if tt.entry != "" && !strings.Contains(body, tt.entry) {
+				t.Errorf("%s = %s, want %q", uri, body, tt.entry)
+			}
+			if tt.entry == "" && (strings.Contains(body, "urn:isbn:") || strings.Contains(body, "urn:bookstore:review:")) {
+				t.Errorf("%s = %s, want no entries", uri, body)
+			}
+		}
+	}
+
+	// every author of a book, by its contributors
+	ts.expect(ts.do(http.MethodPost, "/book/create", creditedBook, admin), http.StatusOK)
+	credits := map[string][]string{
+		feed.Atom: {"<name>Frank Herbert</name>", "<name>Brian Herbert</name>"},
+		feed.RSS:  {"<dc:creator>Frank Herbert</dc:creator>", "<dc:creator>Brian Herbert</dc:creator>"},
+	}
+	for format, want := range credits {
+		body := ts.do(http.MethodGet, "/feed/books?format="+format, "", "").Body.String()
+		for _, text := range want {
+			if !strings.Contains(body, text) {
+				t.Errorf("%s feed of the books = %s, want %q", format, body, text)
+			}
+		}
+	}
+}
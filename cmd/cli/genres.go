@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"test.iamgak.net/models"
+	"test.iamgak.net/validator"
+)
+
+// books per page of a genre
+const genrePerPage = 20
+
+type genrePage struct {
+	Genre *models.Genre `json:"genre"`
+	// from the top of the tree down to the genre
+	Path    []*models.Genre `json:"path"`
+	Books   []*models.Book  `json:"books"`
+	Total   int             `json:"total"`
+	Page    int             `json:"page"`
+	PerPage int             `json:"per_page"`
+}
+
+// the whole genre tree with book counts
+func (app *application) GenreTree(w http.ResponseWriter, r *http.Request) {
+	t, err := app.models.Genres.Taxonomy(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendJSONResponse(w, 200, t.Roots)
+}
+
+// a genre with its sub genres and the books of it and every descendant, ?page=
+func (app *application) GenreInfo(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+	t, err := app.models.Genres.Taxonomy(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	genre := t.Find(slug)
+	if genre == nil {
+		app.notFound(w)
+		return
+	}
+
+	page := pageParam(r)
+	books, total, err := app.models.Books.FilterBooks(r.Context(), models.BookFilter{Genre: slug}, genrePerPage, (page-1)*genrePerPage)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendJSONResponse(w, 200, &genrePage{
+		Genre:   genre,
+		Path:    t.Path(genre),
+		Books:   books,
+		Total:   total,
+		Page:    page,
+		PerPage: genrePerPage,
+	})
+}
+
+// name, slug and parent_id of the request, the slug has to be free
+func (app *application) decodeGenre(w http.ResponseWriter, r *http.Request, id int64) (*models.Genre, bool) {
+	var genre *models.Genre
+	err := json.NewDecoder(r.Body).Decode(&genre)
+	if err != nil || genre == nil {
+		app.CustomError(w, "Unsupported or empty fields", 400)
+		return nil, false
+	}
+
+	genre.ID = id
+	genre.Name = strings.Join(strings.Fields(genre.Name), " ")
+	genre.Slug = strings.TrimSpace(genre.Slug)
+	validator := &validator.Validator{
+		Errors: make(map[string]string),
+	}
+
+	validator.CheckField(validator.NotBlank(genre.Name), "name", "Please, fill the name field")
+	validator.CheckField(validator.MaxChars(genre.Name, 50), "name", "Please, fill the NAME shorter than 50")
+	if genre.Slug != "" {
+		validator.CheckField(genre.Slug == models.Slugify(genre.Slug), "slug", "Please, use lower case letters, digits and dashes in the slug")
+		validator.CheckField(validator.MaxChars(genre.Slug, 100), "slug", "Please, fill the SLUG shorter than 100")
+
+		t, err := app.models.Genres.Taxonomy(r.Context())
+		if err != nil {
+			app.modelError(w, err)
+			return nil, false
+		}
+		if validator.Valid() {
+			if other := t.Find(genre.Slug); other != nil && other.ID != id {
+				app.conflict(w, "slug", "slug already Exist")
+				return nil, false
+			}
+		}
+	}
+
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return nil, false
+	}
+
+	return genre, true
+}
+
+// new genre, at the top or below parent_id, admin only
+func (app *application) CreateGenre(w http.ResponseWriter, r *http.Request) {
+	genre, ok := app.decodeGenre(w, r, 0)
+	if !ok {
+		return
+	}
+
+	err := app.models.Genres.CreateGenre(r.Context(), genre)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Genre Created", userID(r.Context()))
+	app.sendJSONResponse(w, 200, genre)
+}
+
+// rename a genre or move it below parent_id (0 for the top), admin only
+func (app *application) UpdateGenre(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	genre, ok := app.decodeGenre(w, r, id)
+	if !ok {
+		return
+	}
+
+	err := app.models.Genres.UpdateGenre(r.Context(), genre)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Genre Updated", userID(r.Context()))
+	resp := app.sendMessage(true, "Genre Record Updated, Sucessfully")
+	app.sendJSONResponse(w, 200, resp)
+}
+
+// remove a genre, its sub genres and books move up to its parent, admin only
+func (app *application) DeleteGenre(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	err := app.models.Genres.DeleteGenre(r.Context(), id)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Genre Deleted", userID(r.Context()))
+	resp := app.sendMessage(true, "Genre Deleted")
+	app.sendJSONResponse(w, 200, resp)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+func TestGenreRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	_, reader := ts.login("reader@example.com", "user")
+
+	ts.expect(ts.do(http.MethodPost, "/admin/genre/create", `{"name":"Fiction"}`, reader), http.StatusForbidden)
+	ts.expect(ts.do(http.MethodPost, "/admin/genre/create", `{"name":""}`, admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/admin/genre/create", `{"name":"Fiction","slug":"Not A Slug"}`, admin), http.StatusUnprocessableEntity)
+
+	w := ts.do(http.MethodPost, "/admin/genre/create", `{"name":"Fiction"}`, admin)
+	ts.expect(w, http.StatusOK)
+	fiction := decode[*models.Genre](t, w)
+	if fiction.ID == 0 || fiction.Slug != "fiction" {
+		t.Fatalf("created genre = %+v", fiction)
+	}
+	ts.expect(ts.do(http.MethodPost, "/admin/genre/create", `{"name":"Novels","slug":"fiction"}`, admin), http.StatusConflict)
+
+	w = ts.do(http.MethodPost, "/admin/genre/create", `{"name":"Science Fiction","parent_id":`+strconv.FormatInt(fiction.ID, 10)+`}`, admin)
+	ts.expect(w, http.StatusOK)
+	scifi := decode[*models.Genre](t, w)
+
+	// the book is filed under the genre of its name, and so under its parent
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	roots := decode[[]*models.Genre](t, ts.do(http.MethodGet, "/genres", "", ""))
+	if len(roots) != 1 || roots[0].Slug != "fiction" || roots[0].Books != 1 || len(roots[0].Children) != 1 {
+		t.Fatalf("genre tree = %+v, want fiction with science fiction below it", roots)
+	}
+
+	page := decode[genrePage](t, ts.do(http.MethodGet, "/genre/fiction", "", ""))
+	if page.Genre.Slug != "fiction" || page.Total != 1 || len(page.Books) != 1 || page.Books[0].Title != "Dune" {
+		t.Errorf("genre page = %+v, want Dune", page)
+	}
+	page = decode[genrePage](t, ts.do(http.MethodGet, "/genre/science-fiction?page=2", "", ""))
+	if len(page.Path) != 2 || page.Page != 2 || len(page.Books) != 0 || page.Total != 1 {
+		t.Errorf("second page of science fiction = %+v", page)
+	}
+	ts.expect(ts.do(http.MethodGet, "/genre/poetry", "", ""), http.StatusNotFound)
+
+	update := "/admin/genre/update/" + strconv.FormatInt(scifi.ID, 10)
+	ts.expect(ts.do(http.MethodPost, update, `{"name":"SF","slug":"sf"}`, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/admin/genre/update/999", `{"name":"Poetry"}`, admin), http.StatusNotFound)
+	roots = decode[[]*models.Genre](t, ts.do(http.MethodGet, "/genres", "", ""))
+	if len(roots) != 2 || roots[1].Slug != "sf" {
+		t.Errorf("genre tree after moving to the top = %+v, want two roots", roots)
+	}
+
+	ts.expect(ts.do(http.MethodPost, "/admin/genre/delete/"+strconv.FormatInt(fiction.ID, 10), "", admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/admin/genre/delete/"+strconv.FormatInt(fiction.ID, 10), "", admin), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodGet, "/genre/fiction", "", ""), http.StatusNotFound)
+}
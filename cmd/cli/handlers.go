@@ -1,12 +1,17 @@
 package main
 
 import (
+	"crypto/rand"
 	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
+	"test.iamgak.net/marc"
 	"test.iamgak.net/models"
 	"test.iamgak.net/validator"
 	"time"
@@ -17,21 +22,23 @@ type Message struct {
 	Message string `json:"message"`
 }
 
-// home page
+// home page with the trending, bestseller and new and notable books, ?genre= slug
+// for the lists of a genre and its sub genres
 func (app *application) Home(w http.ResponseWriter, r *http.Request) {
-	resp := Message{
-		Status:  true,
-		Message: "Welcome to our Bookstore, Website",
+	home, err := app.homeLists(r.Context(), r.URL.Query().Get("genre"))
+	if err != nil {
+		app.modelError(w, err)
+		return
 	}
 
-	app.sendJSONResponse(w, 200, &resp)
+	app.sendJSONResponse(w, 200, home)
 }
 
 // all the review listing
 func (app *application) ReviewListing(w http.ResponseWriter, r *http.Request) {
-	bks, err := app.models.Review.ReviewListing()
+	bks, err := app.models.Review.ReviewListing(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.modelError(w, err)
 		return
 	}
 
@@ -40,22 +47,28 @@ func (app *application) ReviewListing(w http.ResponseWriter, r *http.Request) {
 
 // logged user review
 func (app *application) MyReview(w http.ResponseWriter, r *http.Request) {
-	bks, err := app.models.Review.MyReview(app.user_id)
+	bks, err := app.models.Review.MyReview(r.Context(), userID(r.Context()))
 	if err != nil {
-		app.serverError(w, err)
+		app.modelError(w, err)
 		return
 	}
 
 	app.sendJSONResponse(w, 200, bks)
 }
 
-// search by isbn only
+// search by isbn only, ?editions=all adds the reviews of the other editions of the work
 func (app *application) ReviewSearch(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
 	isbn := params.ByName("isbn")
-	bks, err := app.models.Review.GetReviewByIsbn(isbn)
+	var bks []*models.Review
+	var err error
+	if r.URL.Query().Get("editions") == "all" {
+		bks, err = app.models.Review.GetWorkReviews(r.Context(), isbn)
+	} else {
+		bks, err = app.models.Review.GetReviewByIsbn(r.Context(), isbn)
+	}
 	if err != nil {
-		app.serverError(w, err)
+		app.modelError(w, err)
 		return
 	}
 
@@ -70,13 +83,13 @@ func (app *application) DeleteReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.models.Review.DeleteReview(review_id, app.user_id)
+	err = app.models.Review.DeleteReview(r.Context(), review_id, userID(r.Context()))
 	if err != nil {
-		app.notFound(w)
+		app.modelError(w, err)
 		return
 	}
 
-	app.models.Users.ActivityLog("review_deleted", app.user_id)
+	app.activityLog(r.Context(), "review_deleted", userID(r.Context()))
 	resp := app.sendMessage(true, "Review Deleted")
 	app.sendJSONResponse(w, 200, resp)
 }
@@ -92,7 +105,7 @@ func (app *application) AddReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	CreateReview.Uid = app.user_id
+	CreateReview.Uid = userID(r.Context())
 	validator := &validator.Validator{
 		Errors: make(map[string]string),
 	}
@@ -113,25 +126,28 @@ func (app *application) AddReview(w http.ResponseWriter, r *http.Request) {
 		validator.CheckField(validator.MaxChars(CreateReview.Title, 50), "title", "Please, fill the TITLE shorter than 50")
 	}
 
-	if validator.Valid() {
-		book_id := app.models.Books.BookExist(CreateReview.Isbn)
-		if book_id {
-			validator.Errors["isbn"] = "isbn already Exist"
-		}
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return
 	}
 
-	if !validator.Valid() {
-		app.sendJSONResponse(w, 200, validator)
+	exist, err := app.models.Books.BookExist(r.Context(), CreateReview.Isbn)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+	if !exist {
+		app.CustomError(w, "no book with this isbn", http.StatusNotFound)
 		return
 	}
 
-	err = app.models.Review.CreateReview(CreateReview)
+	err = app.models.Review.CreateReview(r.Context(), CreateReview)
 	if err != nil {
-		app.CustomError(w, "Server Issue", 500)
+		app.modelError(w, err)
 		return
 	}
 
-	app.models.Users.ActivityLog("review_created", app.user_id)
+	app.activityLog(r.Context(), "review_created", userID(r.Context()))
 	resp := app.sendMessage(true, "Review Saved")
 	app.sendJSONResponse(w, 200, resp)
 }
@@ -139,45 +155,124 @@ func (app *application) AddReview(w http.ResponseWriter, r *http.Request) {
 // bookListing related handlers
 func (app *application) BookListing(w http.ResponseWriter, r *http.Request) {
 
-	bks, err := app.models.Books.BooksListing()
+	bks, err := app.models.Books.BooksListing(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.modelError(w, err)
 		return
 	}
 
 	app.sendJSONResponse(w, 200, bks)
 }
 
-// book info based on isbn
+// book info based on isbn, or its citation (see citationFormat)
 func (app *application) BookInfo(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
-	isbn := params.ByName("isbn")
+	isbn, format := citationFormat(r, params.ByName("isbn"))
 	validator := &validator.Validator{
 		Errors: make(map[string]string),
 	}
 	validator.CheckField(validator.NotBlank(isbn), "ISBN", "Empty, ISBN field")
 
 	if !validator.Valid() {
-		app.sendJSONResponse(w, 200, validator.Errors)
+		app.failedValidation(w, validator)
+		return
+	}
+
+	// BibTeX, RIS or CSL-JSON citation instead of the json api
+	if format != "" {
+		book, err := app.models.Books.FindBook(r.Context(), isbn)
+		if err != nil {
+			app.modelError(w, err)
+			return
+		}
+
+		books := []*models.Book{book}
+		if err := app.loadContributors(r.Context(), books); err != nil {
+			app.modelError(w, err)
+			return
+		}
+
+		app.sendCitation(w, format, isbn, books)
 		return
 	}
 
-	info, err := app.models.Books.GetBookByIsbn(isbn)
+	info, err := app.models.Books.GetBookByIsbn(r.Context(), isbn)
 	if err != nil {
-		app.serverError(w, err)
+		app.modelError(w, err)
 		return
 	}
 
+	for _, book := range info {
+		book.Contributors, err = app.models.Books.Contributors(r.Context(), book.ISBN)
+		if err != nil {
+			app.modelError(w, err)
+			return
+		}
+
+		book.Genres, err = app.models.Books.BookGenres(r.Context(), book.ISBN)
+		if err != nil {
+			app.modelError(w, err)
+			return
+		}
+
+		book.Editions, err = app.models.Books.Editions(r.Context(), book.ISBN)
+		if err != nil {
+			app.modelError(w, err)
+			return
+		}
+
+		book.Series, err = app.models.Books.SeriesPlace(r.Context(), book.ISBN)
+		if err != nil {
+			app.modelError(w, err)
+			return
+		}
+
+		app.countView(r.Context(), book.ISBN)
+	}
+
 	app.sendJSONResponse(w, 200, info)
 }
 
-// add book in db
-func (app *application) AddBook(w http.ResponseWriter, r *http.Request) {
-	var bookRegister *models.Book
-	err := json.NewDecoder(r.Body).Decode(&bookRegister)
+// book record as MARCXML (/book/:isbn.marcxml) or MARC21 binary (/book/:isbn.mrc)
+func (app *application) BookMARC(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	file := params.ByName("isbn")
+	ext := path.Ext(file)
+	if ext != ".marcxml" && ext != ".mrc" {
+		app.notFound(w)
+		return
+	}
+
+	book, err := app.models.Books.FindBook(r.Context(), strings.TrimSuffix(file, ext))
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	book.Contributors, err = app.models.Books.Contributors(r.Context(), book.ISBN)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	records := []*marc.Record{marc.FromBook(book)}
+	if ext == ".mrc" {
+		w.Header().Set("Content-Type", "application/marc")
+		err = marc.WriteBinary(w, records)
+	} else {
+		w.Header().Set("Content-Type", "application/marcxml+xml; charset=utf-8")
+		err = marc.WriteXML(w, records)
+	}
+
 	if err != nil {
 		app.errorLog.Print(err)
-		app.CustomError(w, "Unsupported or empty fields", 400)
+	}
+}
+
+// add book in db, the book of a publisher member is listed under their publisher
+func (app *application) AddBook(w http.ResponseWriter, r *http.Request) {
+	bookRegister, cover, ok := app.decodeBook(w, r)
+	if !ok {
 		return
 	}
 
@@ -185,50 +280,152 @@ func (app *application) AddBook(w http.ResponseWriter, r *http.Request) {
 		Errors: make(map[string]string),
 	}
 
-	validator.CheckField(validator.NotBlank(bookRegister.ISBN), "isbn", "Please, fill the isbn field")
-	validator.CheckField(validator.NotBlank(bookRegister.Genre), "genre", "Please, fill the genre field")
-	validator.CheckField(validator.NotBlank(bookRegister.Descriptions), "descriptions", "Please, fill the descriptions field")
-	validator.CheckField(validator.NotBlank(bookRegister.Author), "author", "Please, fill the author field")
-	validator.CheckField(validator.NotBlank(bookRegister.Title), "title", "Please, fill the title field")
+	validateBook(validator, bookRegister)
+	if err := app.checkReferences(r.Context(), validator, bookRegister); err != nil {
+		app.modelError(w, err)
+		return
+	}
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return
+	}
 
-	if validator.Errors["isbn"] == "" {
-		validator.CheckField(validator.MaxChars(bookRegister.Title, 20), "isbn", "Please, fill the ISBN shorter than 20")
+	exist, err := app.models.Books.BookExist(r.Context(), bookRegister.ISBN)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+	if exist {
+		app.conflict(w, "isbn", "isbn already Exist")
+		return
 	}
 
-	if validator.Errors["descriptions"] == "" {
-		validator.CheckField(validator.MaxChars(bookRegister.Descriptions, 100), "isbn", "Please, fill the ISBN shorter than 100")
+	by, err := app.editor(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return
 	}
 
-	if validator.Errors["author"] == "" {
-		validator.CheckField(validator.MaxChars(bookRegister.Author, 50), "author", "Please, fill the AUTHOR shorter than 50")
+	if cover != nil {
+		if bookRegister.Cover, ok = app.saveCover(w, r, bookRegister.ISBN, cover); !ok {
+			return
+		}
 	}
-	if validator.Errors["title"] == "" {
-		validator.CheckField(validator.MaxChars(bookRegister.Title, 50), "title", "Please, fill the TITLE shorter than 50")
+
+	err = app.models.Books.CreateBook(r.Context(), bookRegister, by)
+	if err != nil {
+		app.deleteCover(r.Context(), bookRegister.Cover)
+		app.modelError(w, err)
+		return
 	}
 
-	if validator.Valid() {
-		book_id := app.models.Books.BookExist(bookRegister.ISBN)
-		if book_id {
-			validator.Errors["isbn"] = "isbn already Exist"
-		}
+	app.activityLog(r.Context(), "Book Listed", userID(r.Context()))
+	resp := app.sendMessage(true, "Book Record Saved, Sucessfully")
+	app.sendJSONResponse(w, 200, resp)
+}
+
+// change the book of given isbn, a new cover replaces the old one, publisher
+// members change the books of their publisher and admins every book
+func (app *application) UpdateBook(w http.ResponseWriter, r *http.Request) {
+	book, cover, ok := app.decodeBook(w, r)
+	if !ok {
+		return
 	}
 
+	params := httprouter.ParamsFromContext(r.Context())
+	book.ISBN = params.ByName("isbn")
+	validator := &validator.Validator{
+		Errors: make(map[string]string),
+	}
+
+	validateBook(validator, book)
+	if err := app.checkReferences(r.Context(), validator, book); err != nil {
+		app.modelError(w, err)
+		return
+	}
 	if !validator.Valid() {
-		app.sendJSONResponse(w, 200, validator)
+		app.failedValidation(w, validator)
 		return
 	}
 
-	err = app.models.Books.CreateBook(bookRegister)
+	by, err := app.editor(r.Context())
 	if err != nil {
-		app.CustomError(w, "Server Issue1", 500)
+		app.modelError(w, err)
 		return
 	}
 
-	app.models.Users.ActivityLog("Book Listed", app.user_id)
-	resp := app.sendMessage(true, "Book Record Saved, Sucessfully")
+	// the new cover is stored first and written with the fields, the old one
+	// is removed once nothing points at it
+	var old string
+	if cover != nil {
+		stored, err := app.models.Books.FindBook(r.Context(), book.ISBN)
+		if err != nil {
+			cover.Close()
+			app.modelError(w, err)
+			return
+		}
+		old = stored.Cover
+
+		if book.Cover, ok = app.saveCover(w, r, book.ISBN, cover); !ok {
+			return
+		}
+	}
+
+	err = app.models.Books.UpdateBook(r.Context(), book, by)
+	if err != nil {
+		app.deleteCover(r.Context(), book.Cover)
+		app.modelError(w, err)
+		return
+	}
+
+	if book.Cover != "" && old != book.Cover {
+		app.deleteCover(r.Context(), old)
+	}
+
+	app.activityLog(r.Context(), "Book Updated", userID(r.Context()))
+	resp := app.sendMessage(true, "Book Record Updated, Sucessfully")
 	app.sendJSONResponse(w, 200, resp)
 }
 
+// bulk import of books from a csv or json lines upload, admin only
+func (app *application) BookImport(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 32<<20)
+	// the whole form is read here, so a body over the limit fails here as well
+	file, header, err := r.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		app.CustomError(w, "upload is too large, the limit is 32 MiB", 413)
+		return
+	}
+	if err != nil {
+		app.errorLog.Print(err)
+		app.CustomError(w, "upload the catalog as multipart form field file", 400)
+		return
+	}
+	defer file.Close()
+
+	format, err := importFormat(r.URL.Query().Get("format"), header.Filename, header.Header.Get("Content-Type"))
+	if err != nil {
+		app.CustomError(w, err.Error(), 400)
+		return
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "1"
+	upsert := r.URL.Query().Get("upsert") == "1"
+	by := &models.Editor{UID: userID(r.Context()), Admin: true}
+	report, err := app.importBooks(r.Context(), file, format, dryRun, upsert, by)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	if !dryRun && report.Inserted+report.Updated > 0 {
+		app.activityLog(r.Context(), "Books Imported", by.UID)
+	}
+
+	app.sendJSONResponse(w, 200, report)
+}
+
 // register user
 func (app *application) UserRegister(w http.ResponseWriter, r *http.Request) {
 
@@ -253,10 +450,6 @@ func (app *application) UserRegister(w http.ResponseWriter, r *http.Request) {
 	if validator.Errors["email"] == "" {
 		validator.CheckField(validator.ValidEmail(creds.Email), "email", "Invalid Email Format")
 	}
-	if app.models.Users.EmailExist(creds.Email) != 0 {
-		validator.CheckField(false, "email", "Email already registered")
-	}
-
 	if validator.Errors["repeatPassword"] == "" && validator.Errors["password"] == "" {
 		if creds.Password != creds.RepeatPassword {
 			validator.Errors["repeatPassword"] = "Password not matched"
@@ -264,18 +457,28 @@ func (app *application) UserRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !validator.Valid() {
-		app.sendJSONResponse(w, 200, validator)
+		app.failedValidation(w, validator)
+		return
+	}
+
+	uid, err := app.models.Users.EmailExist(r.Context(), creds.Email)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+	if uid != 0 {
+		app.conflict(w, "email", "Email already registered")
 		return
 	}
 
 	uri := app.generateHash(r.RemoteAddr, r.URL.Port())
-	uid, err := app.models.Users.InsertUser(creds.Email, creds.Password, uri)
+	uid, err = app.models.Users.InsertUser(r.Context(), creds.Email, creds.Password, uri)
 	if err != nil {
-		app.serverError(w, err)
+		app.modelError(w, err)
 		return
 	}
 
-	app.models.Users.ActivityLog("Account Created", uid)
+	app.activityLog(r.Context(), "Account Created", uid)
 	resp := Message{
 		Status:  true,
 		Message: "Registration Successfull ",
@@ -288,14 +491,19 @@ func (app *application) UserRegister(w http.ResponseWriter, r *http.Request) {
 func (app *application) UserActivation(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
 	uri := params.ByName("uri")
-	if !app.models.Users.ValidURI(uri) {
+	valid, err := app.models.Users.ValidURI(r.Context(), uri)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+	if !valid {
 		app.notFound(w)
 		return
 	}
 
-	err := app.models.Users.AccountActivate(uri)
+	err = app.models.Users.AccountActivate(r.Context(), uri)
 	if err != nil {
-		app.notFound(w)
+		app.modelError(w, err)
 		return
 	}
 
@@ -325,37 +533,34 @@ func (app *application) UserLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !validator.Valid() {
-		app.sendJSONResponse(w, 200, validator)
+		app.failedValidation(w, validator)
 		return
 	}
 
-	uid := app.models.Users.EmailExist(creds.Email)
-	if uid == 0 {
-		resp := app.sendMessage(false, "Incorrect Credentials")
-		app.sendJSONResponse(w, 200, resp)
+	// an unknown or inactive account answers like a wrong password
+	uid, err := app.models.Users.Login(r.Context(), creds)
+	if errors.Is(err, models.ErrUserNotFound) || errors.Is(err, models.ErrIncorrectPassword) {
+		app.CustomError(w, "Incorrect Credentials", http.StatusUnauthorized)
 		return
 	}
-
-	uid, err = app.models.Users.Login(creds)
 	if err != nil {
-		app.CustomError(w, "Internal Server Error", 500)
+		app.serverError(w, err)
 		return
 	}
 
 	if uid == 0 {
-		resp := app.sendMessage(false, "Incorrect Credentials")
-		app.sendJSONResponse(w, 200, resp)
+		app.CustomError(w, "Incorrect Credentials", http.StatusUnauthorized)
 		return
 	}
 
 	hashed := app.generateHash(r.RemoteAddr, r.URL.Port())
-	err = app.models.Users.SetLoginToken(hashed, uid)
+	err = app.models.Users.SetLoginToken(r.Context(), hashed, uid)
 	if err != nil {
-		app.serverError(w, err)
+		app.modelError(w, err)
 		return
 	}
 
-	app.models.Users.ActivityLog("logged_in", app.user_id)
+	app.activityLog(r.Context(), "logged_in", uid)
 	cookie := &http.Cookie{
 		Name:    "ldata",
 		Value:   hashed,
@@ -370,7 +575,13 @@ func (app *application) UserLogin(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) UserLogout(w http.ResponseWriter, r *http.Request) {
-	app.models.Users.ActivityLog("log_out", app.user_id)
+	err := app.models.Users.Logout(r.Context(), userID(r.Context()))
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "log_out", userID(r.Context()))
 	resp := app.sendMessage(true, "Logout Successfull")
 	app.sendJSONResponse(w, 200, resp)
 }
@@ -381,9 +592,9 @@ func (app *application) NewPasswordPost(w http.ResponseWriter, r *http.Request)
 	params := httprouter.ParamsFromContext(r.Context())
 	uri := params.ByName("uri")
 
-	uid, err := app.models.Users.ForgetPasswordUri(uri)
+	uid, err := app.models.Users.ForgetPasswordUri(r.Context(), uri)
 	if err != nil {
-		app.notFound(w)
+		app.modelError(w, err)
 		return
 	}
 
@@ -409,13 +620,13 @@ func (app *application) NewPasswordPost(w http.ResponseWriter, r *http.Request)
 	}
 
 	if !validator.Valid() {
-		app.sendJSONResponse(w, 200, validator)
+		app.failedValidation(w, validator)
 		return
 	}
 
-	err = app.models.Users.NewPassword(creds.Password, uid)
+	err = app.models.Users.NewPassword(r.Context(), creds.Password, uid)
 	if err != nil {
-		app.CustomError(w, "Internal Server Error"+err.Error(), 500)
+		app.modelError(w, err)
 		return
 	}
 
@@ -441,17 +652,22 @@ func (app *application) ForgetPasswordPost(w http.ResponseWriter, r *http.Reques
 	validator.CheckField(validator.NotBlank(creds.Email), "email", "Please, fill the email field")
 
 	if !validator.Valid() {
-		app.sendJSONResponse(w, 200, validator)
+		app.failedValidation(w, validator)
 		return
 	}
 
-	uid := app.models.Users.EmailExist(creds.Email)
+	uid, err := app.models.Users.EmailExist(r.Context(), creds.Email)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
 	if uid > 0 {
 		token := app.generateHash(creds.Email, r.RemoteAddr)
 		uri := app.generateHash(token, r.RemoteAddr)
-		err := app.models.Users.ForgetPassword(uid, uri)
+		err := app.models.Users.ForgetPassword(r.Context(), uid, uri)
 		if err != nil {
-			app.CustomError(w, "Internal Server Error", 400)
+			app.modelError(w, err)
+			return
 		}
 	}
 
@@ -460,10 +676,15 @@ func (app *application) ForgetPasswordPost(w http.ResponseWriter, r *http.Reques
 	app.sendJSONResponse(w, 200, resp)
 }
 
-// create a token for login, user_verification
+// create a token for login, user_verification, random bytes keep two users
+// of the same address in the same second apart
 func (app *application) generateHash(addr, port string) string {
+	salt := make([]byte, 20)
+	rand.Read(salt)
+
 	data := addr + port + strconv.FormatInt(time.Now().Unix(), 10)
 	hasher := sha1.New()
+	hasher.Write(salt)
 	hasher.Write([]byte(data))
 	hash := hasher.Sum(nil)
 	// Convert hash bytes to a hexadecimal string
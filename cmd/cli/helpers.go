@@ -1,22 +1,74 @@
 package main
 
 import (
+	"context"
+	"crypto/sha1"
+	"errors"
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"test.iamgak.net/models"
+	"test.iamgak.net/validator"
 )
 
+// the body of every error answer, errors holds the messages by field when
+// the request did not validate
+type errorResponse struct {
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// codes of the statuses answered, the others are named after their status text
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "validation_failed",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// writes the error envelope, the request id comes from the response header
+// set by requestID
+func (app *application) errorResponse(w http.ResponseWriter, status int, message string, fields map[string]string) {
+	code, ok := errorCodes[status]
+	if !ok {
+		code = strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	}
+
+	app.sendJSONResponse(w, status, &errorResponse{
+		Code:      code,
+		Message:   message,
+		Errors:    fields,
+		RequestID: w.Header().Get(requestIDHeader),
+	})
+}
+
 func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	trace := fmt.Sprintf("%s %s\n%s", w.Header().Get(requestIDHeader), err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	app.errorResponse(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
 }
 
 // The clientError helper sends a specific status code and corresponding description
 // to the user. We'll use this later in the book to send responses like 400 "Bad
 // Request" when there's a problem with the request that the user sent.
 func (app *application) clientError(w http.ResponseWriter, status int) {
-	http.Error(w, http.StatusText(status), status)
+	app.errorResponse(w, status, http.StatusText(status), nil)
 }
 
 // For consistency, we'll also implement a notFound helper. This is simply a
@@ -27,5 +79,282 @@ func (app *application) notFound(w http.ResponseWriter) {
 }
 
 func (app *application) CustomError(w http.ResponseWriter, message string, status int) {
-	http.Error(w, message, status)
+	app.errorResponse(w, status, message, nil)
+}
+
+// 422 with the messages of every field that did not validate
+func (app *application) failedValidation(w http.ResponseWriter, v *validator.Validator) {
+	app.errorResponse(w, http.StatusUnprocessableEntity, "Please, correct the fields in errors", v.Errors)
+}
+
+// 409 when the field names something that already exists, like the isbn of
+// another book
+func (app *application) conflict(w http.ResponseWriter, field, message string) {
+	app.errorResponse(w, http.StatusConflict, message, map[string]string{field: message})
+}
+
+// the status of the errors the models return by their kind, the message and
+// field of a models.Error are answered, the other errors are server errors
+func (app *application) modelError(w http.ResponseWriter, err error) {
+	var e *models.Error
+	if !errors.As(err, &e) {
+		app.serverError(w, err)
+		return
+	}
+
+	var fields map[string]string
+	if e.Field != "" {
+		fields = map[string]string{e.Field: e.Message}
+	}
+
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		app.errorResponse(w, http.StatusNotFound, e.Message, nil)
+	case errors.Is(err, models.ErrConflict):
+		app.errorResponse(w, http.StatusConflict, e.Message, fields)
+	case errors.Is(err, models.ErrValidation):
+		app.errorResponse(w, http.StatusUnprocessableEntity, e.Message, fields)
+	case errors.Is(err, models.ErrForbidden):
+		app.errorResponse(w, http.StatusForbidden, e.Message, nil)
+	case errors.Is(err, models.ErrUnavailable):
+		app.errorLog.Output(2, fmt.Sprintf("%s %s", w.Header().Get(requestIDHeader), err))
+		w.Header().Set("Retry-After", "5")
+		app.errorResponse(w, http.StatusServiceUnavailable, e.Message+", please try again", nil)
+	default:
+		app.serverError(w, err)
+	}
+}
+
+// book fields check, shared by AddBook and the bulk import
+func validateBook(v *validator.Validator, book *models.Book) {
+	validateBookLimits(v, book, apiLimits)
+}
+
+// the longest title, author, genre and descriptions of a book, in characters
+type bookLimits struct {
+	title, author, genre, descriptions int
+}
+
+var (
+	// of the json api and the imports
+	apiLimits = bookLimits{title: 50, author: 50, genre: 50, descriptions: 100}
+	// of the books columns, ONIX feeds carry whole titles and descriptions. A
+	// text column holds 65535 bytes, up to 4 of them a character.
+	columnLimits = bookLimits{title: 255, author: 255, genre: 50, descriptions: 65535 / 4}
+)
+
+// validateBook with other limits of the fields
+func validateBookLimits(v *validator.Validator, book *models.Book, limits bookLimits) {
+	validateContributors(v, book)
+	validateGenres(v, book)
+	v.CheckField(v.NotBlank(book.ISBN), "isbn", "Please, fill the isbn field")
+	v.CheckField(v.NotBlank(book.Genre), "genre", "Please, fill the genre field")
+	v.CheckField(v.NotBlank(book.Descriptions), "descriptions", "Please, fill the descriptions field")
+	v.CheckField(v.NotBlank(book.Author), "author", "Please, fill the author field")
+	v.CheckField(v.NotBlank(book.Title), "title", "Please, fill the title field")
+
+	if v.Errors["isbn"] == "" {
+		v.CheckField(v.MaxChars(book.ISBN, 20), "isbn", "Please, fill the ISBN shorter than 20")
+	}
+
+	book.Format = strings.ToLower(strings.TrimSpace(book.Format))
+	if book.Format != "" {
+		v.CheckField(slices.Contains(models.Formats, book.Format), "format", "Please, use a format of "+strings.Join(models.Formats, ", "))
+	}
+
+	book.Language = strings.TrimSpace(book.Language)
+	v.CheckField(v.MaxChars(book.Language, 10), "language", "Please, fill the LANGUAGE shorter than 10, like en or pt-BR")
+
+	if v.Errors["genre"] == "" {
+		v.CheckField(v.MaxChars(book.Genre, limits.genre), "genre", fmt.Sprintf("Please, fill the GENRE shorter than %d", limits.genre))
+	}
+
+	if v.Errors["descriptions"] == "" {
+		v.CheckField(v.MaxChars(book.Descriptions, limits.descriptions), "descriptions", fmt.Sprintf("Please, fill the DESCRIPTIONS shorter than %d", limits.descriptions))
+	}
+
+	if v.Errors["author"] == "" {
+		v.CheckField(v.MaxChars(book.Author, limits.author), "author", fmt.Sprintf("Please, fill the AUTHOR shorter than %d", limits.author))
+	}
+	if v.Errors["title"] == "" {
+		v.CheckField(v.MaxChars(book.Title, limits.title), "title", fmt.Sprintf("Please, fill the TITLE shorter than %d", limits.title))
+	}
+}
+
+// contributors need a name and a known role (author when left out), the author
+// field is filled from the credited authors when only contributors are given
+func validateContributors(v *validator.Validator, book *models.Book) {
+	authors := []string{}
+	for i, c := range book.Contributors {
+		if c == nil {
+			c = &models.Contributor{}
+			book.Contributors[i] = c
+		}
+
+		// credits go by name, known names and aliases find their author
+		c.AuthorID = 0
+		c.Name = strings.Join(strings.Fields(c.Name), " ")
+		if c.Role == "" {
+			c.Role = models.RoleAuthor
+		}
+
+		v.CheckField(v.NotBlank(c.Name), "contributors", "Please, fill the name of every contributor")
+		v.CheckField(models.ValidRole(c.Role), "contributors", "Please, use a role of "+strings.Join(models.ContributorRoles, ", "))
+		if c.Role == models.RoleAuthor {
+			authors = append(authors, c.Name)
+		}
+	}
+
+	if strings.TrimSpace(book.Author) == "" {
+		book.Author = strings.Join(authors, ", ")
+	}
+}
+
+// run a background job now and then once per interval until ctx is done,
+// errors are logged, a running job is not cut short by ctx but runs with
+// app.jobsCtx that serve cancels at the shutdown deadline, app.jobs waits for it
+func (app *application) every(ctx context.Context, interval time.Duration, job func(ctx context.Context) error) {
+	app.jobs.Add(1)
+	go func() {
+		defer app.jobs.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := job(app.jobsCtx); err != nil {
+				app.errorLog.Print(err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// positive :id of the route
+func idParam(r *http.Request) (int64, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// records the activity of uid, a failure is only logged as what it records
+// is already done
+func (app *application) activityLog(ctx context.Context, activity string, uid int64) {
+	if err := app.models.Users.ActivityLog(ctx, activity, uid); err != nil {
+		app.errorLog.Output(2, err.Error())
+	}
+}
+
+// work_id and publisher_id, when given, have to be an existing work and
+// publisher, the error is the one of the db
+func (app *application) checkReferences(ctx context.Context, v *validator.Validator, book *models.Book) error {
+	if book.WorkID != 0 {
+		ok, err := app.models.Works.WorkExist(ctx, book.WorkID)
+		if err != nil {
+			return err
+		}
+		v.CheckField(ok, "work_id", "Please, use the id of an existing work")
+	}
+
+	if book.PublisherID != 0 {
+		ok, err := app.models.Publishers.PublisherExist(ctx, book.PublisherID)
+		if err != nil {
+			return err
+		}
+		v.CheckField(ok, "publisher_id", "Please, use the id of an existing publisher")
+	}
+
+	return nil
+}
+
+// the logged in user as writer of books
+func (app *application) editor(ctx context.Context) (*models.Editor, error) {
+	uid := userID(ctx)
+	admin, err := app.models.Users.IsAdmin(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Editor{UID: uid, Admin: admin}, nil
+}
+
+// credits of every book, for the citations and feeds that name them
+func (app *application) loadContributors(ctx context.Context, books []*models.Book) error {
+	for _, book := range books {
+		var err error
+		book.Contributors, err = app.models.Books.Contributors(ctx, book.ISBN)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// genres are given by name or slug, the first one is the main genre when the
+// genre field is empty
+func validateGenres(v *validator.Validator, book *models.Book) {
+	for i, g := range book.Genres {
+		if g == nil {
+			g = &models.BookGenre{}
+			book.Genres[i] = g
+		}
+
+		g.Name = strings.Join(strings.Fields(g.Name), " ")
+		g.Slug = strings.TrimSpace(g.Slug)
+		v.CheckField(v.NotBlank(g.Name) || v.NotBlank(g.Slug), "genres", "Please, fill the name or the slug of every genre")
+		v.CheckField(v.MaxChars(g.Name, 50), "genres", "Please, fill genre names shorter than 50")
+	}
+
+	if strings.TrimSpace(book.Genre) == "" && len(book.Genres) > 0 {
+		book.Genre = book.Genres[0].Name
+		if book.Genre == "" {
+			book.Genre = book.Genres[0].Slug
+		}
+	}
+}
+
+// body from the cache, or built and kept there for ttl under the tags. The
+// response carries an ETag and is answered with 304 when the client already
+// has it.
+func (app *application) serveCached(w http.ResponseWriter, r *http.Request, key, contentType string, ttl time.Duration, tags []string, build func() ([]byte, error)) {
+	body, err := app.models.Cache.Fetch(r.Context(), key, ttl, tags, func(ctx context.Context) ([]byte, error) {
+		return build()
+	})
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	serveBody(w, r, body, contentType, ttl)
+}
+
+// body with its ETag, a client may keep it for ttl
+func serveBody(w http.ResponseWriter, r *http.Request, body []byte, contentType string, ttl time.Duration) {
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(ttl.Seconds())))
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// If-None-Match holds a list of etags, or *
+func etagMatch(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
 }
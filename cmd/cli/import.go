@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"test.iamgak.net/config"
+	"test.iamgak.net/marc"
+	"test.iamgak.net/models"
+	"test.iamgak.net/validator"
+)
+
+var errImportFormat = errors.New("import: unsupported format, use csv, json, jsonl, marc or marcxml")
+
+// a parsed row of the import file, row is the line number in the file
+type importRow struct {
+	row  int
+	book *models.Book
+	err  error
+}
+
+// an upload the import can not read as its format, a validation error of the
+// field file so the cause reaches the client with a 422
+func importFileError(err error) error {
+	message := strings.TrimPrefix(strings.TrimPrefix(err.Error(), "import: "), "marc: ")
+	return &models.Error{Kind: models.ErrValidation, Field: "file", Message: message}
+}
+
+// guess the format from the explicit value, the file name or the content type
+func importFormat(format, name, contentType string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".csv":
+			format = "csv"
+		case ".json":
+			format = "json"
+		case ".jsonl", ".ndjson":
+			format = "jsonl"
+		case ".mrc", ".marc":
+			format = "marc"
+		case ".xml", ".marcxml":
+			format = "marcxml"
+		}
+	}
+
+	if format == "" {
+		switch {
+		case strings.HasPrefix(contentType, "text/csv"):
+			format = "csv"
+		case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+			format = "jsonl"
+		case strings.HasPrefix(contentType, "application/json"):
+			format = "json"
+		case strings.HasPrefix(contentType, "application/marcxml+xml"):
+			format = "marcxml"
+		case strings.HasPrefix(contentType, "application/marc"):
+			format = "marc"
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case "csv":
+		return "csv", nil
+	case "json":
+		return "json", nil
+	case "jsonl", "ndjson":
+		return "jsonl", nil
+	case "marc", "mrc":
+		return "marc", nil
+	case "marcxml", "xml":
+		return "marcxml", nil
+	}
+
+	return "", errImportFormat
+}
+
+func readImportRows(r io.Reader, format string) ([]*importRow, error) {
+	switch format {
+	case "csv":
+		return readBooksCSV(r)
+	case "json":
+		return readBooksJSON(r)
+	case "marc":
+		return readBooksMARC(marc.ReadBinary(r))
+	case "marcxml":
+		return readBooksMARC(marc.ReadXML(r))
+	}
+
+	return readBooksJSONL(r)
+}
+
+// first line is the header, columns can be in any order, format and language
+// are optional
+func readBooksCSV(r io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	var parseErr *csv.ParseError
+	if err == io.EOF || errors.As(err, &parseErr) {
+		return nil, importFileError(fmt.Errorf("reading csv header: %w", err))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, name := range []string{"isbn", "title", "author", "price", "descriptions", "genre"} {
+		if _, ok := columns[name]; !ok {
+			return nil, importFileError(fmt.Errorf("csv header is missing the %q column", name))
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []*importRow{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		row := &importRow{row: line}
+		if err != nil {
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			row.err = err
+			rows = append(rows, row)
+			continue
+		}
+
+		book := &models.Book{
+			ISBN:         field(record, "isbn"),
+			Title:        field(record, "title"),
+			Author:       field(record, "author"),
+			Descriptions: field(record, "descriptions"),
+			Genre:        field(record, "genre"),
+			Format:       field(record, "format"),
+			Language:     field(record, "language"),
+		}
+
+		if price := field(record, "price"); price != "" {
+			p, err := strconv.ParseFloat(price, 32)
+			if err != nil {
+				row.err = fmt.Errorf("invalid price %q", price)
+			}
+			book.Price = float32(p)
+		}
+
+		row.book = book
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// a json array of objects with the fields of POST /book/create, row is the
+// place in the array
+func readBooksJSON(r io.Reader) ([]*importRow, error) {
+	var items []json.RawMessage
+	err := json.NewDecoder(r).Decode(&items)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return nil, importFileError(errors.New("a json file should be an array of book objects, use jsonl for one object per line"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*importRow{}
+	for i, item := range items {
+		row := &importRow{row: i + 1}
+		err := json.Unmarshal(item, &row.book)
+		if err != nil || row.book == nil {
+			row.book = nil
+			row.err = errors.New("item is not a valid book json object")
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// one json object per line, same fields as POST /book/create
+func readBooksJSONL(r io.Reader) ([]*importRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	rows := []*importRow{}
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := &importRow{row: line}
+		err := json.Unmarshal([]byte(text), &row.book)
+		if err != nil || row.book == nil {
+			row.book = nil
+			row.err = errors.New("line is not a valid book json object")
+		}
+
+		rows = append(rows, row)
+	}
+
+	err := scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return nil, importFileError(errors.New("a line is longer than 1 MiB"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// one row per MARC record, 020/100/245/520/650 are mapped
+func readBooksMARC(records []*marc.Record, err error) ([]*importRow, error) {
+	var syntaxErr *xml.SyntaxError
+	if errors.Is(err, marc.ErrRecord) || errors.As(err, &syntaxErr) {
+		return nil, importFileError(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []*importRow{}
+	for i, rec := range records {
+		book, _ := rec.Book()
+		rows = append(rows, &importRow{row: i + 1, book: book})
+	}
+
+	return rows, nil
+}
+
+// validate every row like AddBook, then write the valid ones unless it is a dry run
+func (app *application) importBooks(ctx context.Context, r io.Reader, format string, dryRun, upsert bool, by *models.Editor) (*models.ImportReport, error) {
+	rows, err := readImportRows(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ImportReport{
+		DryRun: dryRun,
+		Upsert: upsert,
+		Total:  len(rows),
+		Errors: []*models.ImportRowError{},
+	}
+
+	seen := map[string]int{}
+	books := []*models.Book{}
+	for _, row := range rows {
+		validator := &validator.Validator{
+			Errors: make(map[string]string),
+		}
+
+		if row.err != nil {
+			validator.AddFieldError("row", row.err.Error())
+		} else {
+			row.book.ISBN = strings.TrimSpace(row.book.ISBN)
+			validateBook(validator, row.book)
+			if err := app.checkReferences(ctx, validator, row.book); err != nil {
+				return nil, err
+			}
+		}
+
+		if validator.Valid() {
+			if first, ok := seen[row.book.ISBN]; ok {
+				validator.Errors["isbn"] = fmt.Sprintf("isbn repeated, first seen on row %d", first)
+			} else if !upsert {
+				exist, err := app.models.Books.BookExist(ctx, row.book.ISBN)
+				if err != nil {
+					return nil, err
+				}
+				validator.CheckField(!exist, "isbn", "isbn already Exist")
+			}
+		}
+
+		if !validator.Valid() {
+			rowErr := &models.ImportRowError{Row: row.row, Errors: validator.Errors}
+			if row.book != nil {
+				rowErr.ISBN = row.book.ISBN
+			}
+			report.Errors = append(report.Errors, rowErr)
+			report.Skipped++
+			continue
+		}
+
+		seen[row.book.ISBN] = row.row
+		books = append(books, row.book)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	report.Inserted, report.Updated, err = app.models.Books.ImportBooks(ctx, books, upsert, by)
+	return report, err
+}
+
+// go run ./cmd/cli import -file books.csv [-format csv|json|jsonl|marc|marcxml] [-dry-run] [-upsert]
+func (app *application) runImport(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("file", "", "CSV, JSON Lines, MARC21 or MARCXML file to import")
+	format := fs.String("format", "", "csv, json, jsonl, marc or marcxml, guessed from the file extension when empty")
+	dryRun := fs.Bool("dry-run", false, "validate only, nothing is written")
+	upsert := fs.Bool("upsert", false, "update books whose isbn already exists")
+	dsnFlag := fs.String("dsn", cfg.DSN(), "data source name of the database.driver")
+	fs.Parse(args)
+
+	if *file == "" {
+		fs.Usage()
+		return errors.New("import: -file is required")
+	}
+
+	kind, err := importFormat(*format, *file, "")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := app.openModels(*dsnFlag, cfg); err != nil {
+		return err
+	}
+	defer app.models.Close()
+
+	report, err := app.importBooks(context.Background(), f, kind, *dryRun, *upsert, cliEditor)
+	if err != nil {
+		return err
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	return out.Encode(report)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+func TestImportFormat(t *testing.T) {
+	tests := []struct {
+		format, name, contentType string
+		want                      string
+	}{
+		{"", "books.csv", "", "csv"},
+		{"", "books.json", "", "json"},
+		{"", "books.JSON", "", "json"},
+		{"", "books.jsonl", "", "jsonl"},
+		{"", "books.ndjson", "", "jsonl"},
+		{"", "books.mrc", "", "marc"},
+		{"", "books.xml", "", "marcxml"},
+		{"", "upload", "application/json; charset=utf-8", "json"},
+		{"", "upload", "application/x-ndjson", "jsonl"},
+		{"", "upload", "text/csv", "csv"},
+		// the explicit format wins over the name
+		{"jsonl", "books.json", "", "jsonl"},
+		{"JSON", "books.txt", "", "json"},
+		{"ndjson", "", "", "jsonl"},
+	}
+
+	for _, tt := range tests {
+		got, err := importFormat(tt.format, tt.name, tt.contentType)
+		if err != nil || got != tt.want {
+			t.Errorf("importFormat(%q, %q, %q) = %q, %v, want %q", tt.format, tt.name, tt.contentType, got, err, tt.want)
+		}
+	}
+
+	if _, err := importFormat("", "books.txt", "text/plain"); !errors.Is(err, errImportFormat) {
+		t.Errorf("importFormat of a text file = %v, want errImportFormat", err)
+	}
+}
+
+// an export in every json format reads back as the same books
+func TestExportImportJSON(t *testing.T) {
+	books := []*models.Book{
+		{ISBN: "978-0-00-000001-1", Title: "Dune", Author: "Frank Herbert", Price: 9.99, Genre: "Science Fiction"},
+		{ISBN: "978-0-00-000002-2", Title: "Dune Messiah", Author: "Frank Herbert", Price: 8.99, Genre: "Science Fiction"},
+	}
+
+	for _, format := range []string{"json", "jsonl"} {
+		var buf bytes.Buffer
+		if err := writeBooks(&buf, format, books); err != nil {
+			t.Fatal(err)
+		}
+
+		rows, err := readImportRows(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(rows) != len(books) {
+			t.Fatalf("%s: %d rows, want %d", format, len(rows), len(books))
+		}
+		for i, row := range rows {
+			if row.err != nil || row.row != i+1 || row.book.ISBN != books[i].ISBN || row.book.Title != books[i].Title {
+				t.Errorf("%s: row %d = %+v %+v, want %+v", format, i+1, row, row.book, books[i])
+			}
+		}
+	}
+}
+
+func TestReadBooksJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		// rows and the ones with an error
+		rows, bad int
+		fileErr   bool
+	}{
+		{name: "empty array", file: "[]"},
+		{name: "books", file: `[{"isbn":"1"}, {"isbn":"2"}]`, rows: 2},
+		{name: "items that are no books", file: `[{"isbn":"1"}, "2", null, []]`, rows: 4, bad: 3},
+		{name: "an object", file: `{"isbn":"1"}`, fileErr: true},
+		{name: "json lines", file: "{\"isbn\":\"1\"}\n{\"isbn\":\"2\"}", fileErr: true},
+		{name: "cut short", file: `[{"isbn":"1"}`, fileErr: true},
+		{name: "empty file", file: "", fileErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := readBooksJSON(bytes.NewReader([]byte(tt.file)))
+			var modelErr *models.Error
+			if tt.fileErr {
+				if !errors.As(err, &modelErr) || modelErr.Field != "file" {
+					t.Errorf("readBooksJSON = %v, want an error of the field file", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bad := 0
+			for _, row := range rows {
+				if row.err != nil {
+					bad++
+				}
+			}
+			if len(rows) != tt.rows || bad != tt.bad {
+				t.Errorf("readBooksJSON = %d rows, %d bad, want %d and %d", len(rows), bad, tt.rows, tt.bad)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"test.iamgak.net/models"
+	"test.iamgak.net/recommend"
+)
+
+const (
+	// books per list of the home page
+	listSize = 10
+	// trending counts the views and reviews of the last week, an event counts
+	// half as much every two days, a review as much as ten views
+	trendingWindow   = 7 * 24 * time.Hour
+	trendingHalfLife = 48 * time.Hour
+	reviewWeight     = 10
+	// bestsellers count the copies sold, then the reviews, of the last month
+	bestsellerWindow = 30 * 24 * time.Hour
+	// new and notable are the best rated books listed in the last three months,
+	// newest first when they have no ratings yet
+	newWindow = 90 * 24 * time.Hour
+)
+
+type homeLists struct {
+	Genre       string         `json:"genre,omitempty"`
+	Trending    []*models.Book `json:"trending"`
+	Bestsellers []*models.Book `json:"bestsellers"`
+	New         []*models.Book `json:"new_and_notable"`
+}
+
+// every home page list, overall and for each genre with books
+func (app *application) refreshLists(ctx context.Context, ttl time.Duration) error {
+	start := time.Now()
+	views, err := app.models.Lists.ViewEvents(ctx, start.Add(-trendingWindow))
+	if err != nil {
+		return err
+	}
+
+	reviews, err := app.models.Lists.ReviewEvents(ctx, start.Add(-bestsellerWindow))
+	if err != nil {
+		return err
+	}
+
+	sales, err := app.models.Lists.SaleEvents(ctx, start.Add(-bestsellerWindow))
+	if err != nil {
+		return err
+	}
+
+	newBooks, err := app.models.Lists.NewBooks(ctx, start.Add(-newWindow))
+	if err != nil {
+		return err
+	}
+
+	ratings, err := app.models.Recommend.Ratings(ctx)
+	if err != nil {
+		return err
+	}
+
+	slugs, err := app.models.Lists.GenreSlugs(ctx)
+	if err != nil {
+		return err
+	}
+
+	// reviews weigh more than views for trending, the window is shorter than
+	// the one of the bestsellers
+	trending := append([]recommend.Event{}, views...)
+	for _, e := range reviews {
+		if start.Sub(e.At) <= trendingWindow {
+			trending = append(trending, recommend.Event{ISBN: e.ISBN, At: e.At, Weight: reviewWeight})
+		}
+	}
+
+	listed := map[string]bool{}
+	for _, e := range newBooks {
+		listed[e.ISBN] = true
+	}
+
+	newRatings := []recommend.Rating{}
+	for _, r := range ratings {
+		if listed[r.ISBN] {
+			newRatings = append(newRatings, r)
+		}
+	}
+
+	lists := models.ListSet{models.ListTrending: {}, models.ListBestsellers: {}, models.ListNew: {}}
+	genres := map[string]bool{"": true}
+	for _, list := range slugs {
+		for _, slug := range list {
+			genres[slug] = true
+		}
+	}
+
+	for genre := range genres {
+		in := func(isbn string) bool { return true }
+		if genre != "" {
+			in = func(isbn string) bool {
+				for _, slug := range slugs[isbn] {
+					if slug == genre {
+						return true
+					}
+				}
+				return false
+			}
+		}
+
+		lists[models.ListTrending][genre] = recommend.Trending(recommend.Filter(trending, in), start, trendingHalfLife, listSize)
+		lists[models.ListBestsellers][genre] = recommend.Merge(listSize, nil,
+			recommend.Total(recommend.Filter(sales, in), listSize),
+			recommend.Total(recommend.Filter(reviews, in), listSize))
+
+		rated := []recommend.Rating{}
+		for _, r := range newRatings {
+			if in(r.ISBN) {
+				rated = append(rated, r)
+			}
+		}
+		lists[models.ListNew][genre] = recommend.Merge(listSize, nil,
+			recommend.Popular(rated, listSize),
+			recommend.Trending(recommend.Filter(newBooks, in), start, newWindow, listSize))
+	}
+
+	if err := app.models.Lists.SaveLists(ctx, lists, ttl); err != nil {
+		return err
+	}
+
+	app.infoLog.Printf("home page lists for %d genres in %s", len(genres)-1, time.Since(start))
+	return nil
+}
+
+// a view of a book for the trending list, a failure is not worth failing the page
+func (app *application) countView(ctx context.Context, isbn string) {
+	if err := app.models.Lists.RecordView(ctx, isbn); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// the books of every list of the last job run, overall for the genre ""
+func (app *application) homeLists(ctx context.Context, genre string) (*homeLists, error) {
+	home := &homeLists{Genre: genre}
+	for name, books := range map[string]*[]*models.Book{
+		models.ListTrending:    &home.Trending,
+		models.ListBestsellers: &home.Bestsellers,
+		models.ListNew:         &home.New,
+	} {
+		list, err := app.models.Lists.List(ctx, name, genre)
+		if err != nil {
+			return nil, err
+		}
+
+		if *books, err = app.scoredBooks(ctx, list); err != nil {
+			return nil, err
+		}
+	}
+
+	return home, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHomeRoute(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/book/create", `{"isbn":"978-0-00-000003-5","title":"Emma","author":"Jane Austen","price":5.99,"descriptions":"A matchmaker","genre":"Romance"}`, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/admin/sale/create", `{"isbn":"978-0-00-000003-5","quantity":2}`, admin), http.StatusOK)
+
+	// no lists before the first run of the job
+	home := decode[homeLists](t, ts.do(http.MethodGet, "/", "", ""))
+	if len(home.New) != 0 || len(home.Bestsellers) != 0 {
+		t.Errorf("home before the job = %+v, want empty lists", home)
+	}
+
+	if err := ts.app.refreshLists(context.Background(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	home = decode[homeLists](t, ts.do(http.MethodGet, "/", "", ""))
+	if len(home.New) != 2 || len(home.Bestsellers) != 1 || home.Bestsellers[0].Title != "Emma" {
+		t.Errorf("home = %+v, want both books new and Emma as bestseller", home)
+	}
+
+	home = decode[homeLists](t, ts.do(http.MethodGet, "/?genre=science-fiction", "", ""))
+	if home.Genre != "science-fiction" || len(home.New) != 1 || home.New[0].Title != "Dune" || len(home.Bestsellers) != 0 {
+		t.Errorf("home of science fiction = %+v, want Dune only", home)
+	}
+}
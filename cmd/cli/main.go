@@ -3,106 +3,207 @@ package main
 import (
 	"context"
 	"crypto/tls"
-	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
-
-	// "fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	_ "github.com/go-sql-driver/mysql" // sql pool register
 	"github.com/gorilla/sessions"
-	"github.com/joho/godotenv"
-	"github.com/redis/go-redis/v9"
+	_ "github.com/lib/pq"           // postgres
+	_ "github.com/mattn/go-sqlite3" // sqlite3, needs cgo
+	"test.iamgak.net/config"
+	"test.iamgak.net/covers"
 	"test.iamgak.net/models"
 )
 
+// the command line tools run with the rights of an admin
+var cliEditor = &models.Editor{Admin: true}
+
 type application struct {
-	infoLog         *log.Logger
-	errorLog        *log.Logger
-	db              *sql.DB
-	models          *models.Init
-	session         *sessions.CookieStore
-	isAuthenticated bool
-	user_id         int64
+	infoLog  *log.Logger
+	errorLog *log.Logger
+	db       *models.DB
+	models   *models.Init
+	covers   *covers.Service
+	session  *sessions.CookieStore
+	baseURL  string         // of the absolute links, see config.BaseURL
+	currency string         // of the book prices, store.currency
+	jobs     sync.WaitGroup // background jobs still running, see every
+	jobsCtx  context.Context
+	stopJobs context.CancelFunc // cancels jobsCtx, at the shutdown deadline
 }
 
 func main() {
-	err := godotenv.Load()
+	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
+	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	if err != nil {
-		log.Fatal("Error loading .env file:", err)
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		// the server also starts without it, with the flags of config
+		args = args[1:]
+	} else if len(args) > 0 {
+		app := &application{errorLog: errorLog, infoLog: infoLog}
+		commands := map[string]func(args []string, cfg *config.Config) error{
+			"import":  app.runImport,  // go run ./cmd/cli import -file books.csv, same as book import
+			"onix":    app.runOnix,    // go run ./cmd/cli onix -file feed.xml
+			"migrate": app.runMigrate, // go run ./cmd/cli migrate up
+			"seed":    app.runSeed,    // go run ./cmd/cli seed -books 50 -users 10 -reviews 200
+			"user":    app.runUser,    // go run ./cmd/cli user create -email admin@example.com -role admin
+			"book":    app.runBook,    // go run ./cmd/cli book export -file books.csv
+			"cache":   app.runCache,   // go run ./cmd/cli cache flush
+			"token":   app.runToken,   // go run ./cmd/cli token purge -older-than 24h
+		}
+
+		if run, ok := commands[args[0]]; ok {
+			cfg, err := config.Read(os.Args[0], nil)
+			if err != nil {
+				errorLog.Fatal(err)
+			}
+
+			if err := run(args[1:], cfg); err != nil {
+				errorLog.Fatal(err)
+			}
+			return
+		}
 	}
 
-	// // Access the loaded environment variables
-	sessionKey := os.Getenv("SESSION_KEY")
-	// dbHost := os.Getenv("DB_HOST")
-	dbUser := os.Getenv("DB_USER")
-	dbPort := os.Getenv("DB_PORT")
-	dbName := os.Getenv("DB_NAME")
-	var store = sessions.NewCookieStore([]byte(sessionKey))
-	addr := flag.String("addr", ":"+dbPort, "HTTP network address")
-	dsn := flag.String("dsn", fmt.Sprintf("%s:@/%s?parseTime=true", dbUser, dbName), "MySQL data source name")
-	flag.Parse()
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	db, err := openDB(*dsn)
+	cfg, err := config.Load(os.Args[0], args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
-	defer db.Close()
-
-	ctx := context.Background()
-	redis_name := "localhost"
-	redis_password := ""
-	redis_port := 6379
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", redis_name, redis_port),
-		Password: redis_password, // no password set
-		DB:       0,              // use default DB
-	})
-
-	err = client.Set(ctx, "foo", "bar111", 0).Err()
+	db, err := openDB(cfg.Database.Driver, cfg.DSN())
 	if err != nil {
-		panic(err)
+		errorLog.Fatal(err)
+	}
+
+	if cfg.Database.Migrate {
+		if err := migrateUp(context.Background(), db, cfg.Database.Driver, infoLog); err != nil {
+			errorLog.Fatal(err)
+		}
 	}
 
-	val, err := client.Get(ctx, "foo").Result()
+	client, c := openCache(cfg.Redis, cfg.Cache.Size, errorLog)
+	coverStore, err := coverStorage(cfg.Covers)
 	if err != nil {
-		panic(err)
+		errorLog.Fatal(err)
 	}
 
-	fmt.Println("foo", val)
 	// And add it to the application dependencies.
 	app := &application{
 		errorLog: errorLog,
 		infoLog:  infoLog,
 		db:       db,
-		models:   models.Constructor(db, client),
-		session:  store,
+		models:   models.Constructor(db, client, c),
+		covers:   covers.New(coverStore),
+		session:  sessions.NewCookieStore([]byte(cfg.Session.Key)),
+		baseURL:  cfg.BaseURL(),
+		currency: cfg.Store.Currency,
+	}
+	app.models.Books.SetCoverURLs(app.covers.URLs)
+	app.models.Cache.ErrorLog = errorLog
+	app.jobsCtx, app.stopJobs = context.WithCancel(context.Background())
+	defer app.stopJobs()
+
+	ln, err := net.Listen("tcp", cfg.HTTP.Addr)
+	if err != nil {
+		errorLog.Fatal(err)
 	}
 
+	// SIGINT or SIGTERM stops the jobs and the server, a second one kills it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// the lists live for two intervals so one failed run does not empty them
+	recommendEvery, listsEvery := cfg.Jobs.RecommendInterval, cfg.Jobs.ListsInterval
+	app.every(ctx, recommendEvery, func(ctx context.Context) error { return app.refreshRecommendations(ctx, 2*recommendEvery) })
+	app.every(ctx, listsEvery, func(ctx context.Context) error { return app.refreshLists(ctx, 2*listsEvery) })
+
 	// app.SetSession()
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
 	}
 
 	srv := &http.Server{
-		Addr:      *addr,
+		Addr:      cfg.HTTP.Addr,
 		ErrorLog:  errorLog,
 		TLSConfig: tlsConfig,
 		// MaxHeaderBytes: 524288, // 0.5MB Max header size per request
-		IdleTimeout:  time.Minute, // conncection close after 1 minute it do again handshake or something
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  cfg.HTTP.IdleTimeout, // conncection close after 1 minute it do again handshake or something
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
 		Handler:      app.routes(),
 	}
 
-	infoLog.Printf("Starting server on %s", *addr)
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	infoLog.Printf("Starting server on %s", cfg.HTTP.Addr)
+	err = app.serve(ctx, stop, srv, ln, cfg)
+	// the db and redis go last, nothing uses them any more
+	if cerr := app.models.Close(); cerr != nil {
+		errorLog.Print(cerr)
+	}
+
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	infoLog.Print("Server stopped")
+}
+
+// serve on ln until ctx is done, then stop taking connections and wait for
+// the requests in flight and the running jobs, for at most the shutdown
+// timeout, the jobs still running then are cancelled
+func (app *application) serve(ctx context.Context, stop context.CancelFunc, srv *http.Server, ln net.Listener, cfg *config.Config) error {
+	errs := make(chan error, 1)
+	go func() {
+		if cfg.TLS.Enabled {
+			errs <- srv.ServeTLS(ln, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			errs <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		// could not serve, the jobs stop with the process
+		app.stopJobs()
+		return err
+	case <-ctx.Done():
+	}
+
+	// back to the default handling, a second signal kills the server
+	stop()
+	app.infoLog.Printf("Shutting down, waiting up to %s for requests and jobs", cfg.HTTP.ShutdownTimeout)
+	deadline, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(deadline); err != nil {
+		app.stopJobs()
+		return fmt.Errorf("shutdown: %w", err)
+	}
+
+	jobs := make(chan struct{})
+	go func() {
+		app.jobs.Wait()
+		close(jobs)
+	}()
+
+	select {
+	case <-jobs:
+		return nil
+	case <-deadline.Done():
+		// the next run redoes what they did not finish
+		app.stopJobs()
+		<-jobs
+		return fmt.Errorf("shutdown: background jobs cancelled after %s", cfg.HTTP.ShutdownTimeout)
+	}
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"test.iamgak.net/config"
+)
+
+func newServeApp(t *testing.T) *application {
+	app := &application{
+		infoLog:  log.New(io.Discard, "", 0),
+		errorLog: log.New(io.Discard, "", 0),
+	}
+	app.jobsCtx, app.stopJobs = context.WithCancel(context.Background())
+	t.Cleanup(app.stopJobs)
+	return app
+}
+
+// a request in flight when the server is told to stop is still answered
+func TestServeFinishesRequests(t *testing.T) {
+	app := newServeApp(t)
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "slow answer")
+	})}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.HTTP.ShutdownTimeout = 5 * time.Second
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- app.serve(ctx, stop, srv, ln, cfg) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{string(body), err}
+	}()
+
+	<-started
+	stop()
+
+	res := <-responses
+	if res.err != nil || res.body != "slow answer" {
+		t.Errorf("response = %q, %v, want the slow answer", res.body, res.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve = %v, want a clean shutdown", err)
+	}
+
+	if _, err := http.Get("http://" + ln.Addr().String() + "/"); err == nil {
+		t.Error("the server still answers after the shutdown")
+	}
+}
+
+// a job outliving the shutdown timeout is cancelled and waited for
+func TestServeCancelsJobsAtDeadline(t *testing.T) {
+	app := newServeApp(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.HTTP.ShutdownTimeout = 100 * time.Millisecond
+	ctx, stop := context.WithCancel(context.Background())
+
+	running := make(chan struct{})
+	jobErr := make(chan error, 1)
+	app.every(ctx, time.Hour, func(ctx context.Context) error {
+		close(running)
+		<-ctx.Done()
+		jobErr <- ctx.Err()
+		return nil
+	})
+	<-running
+
+	served := make(chan error, 1)
+	go func() { served <- app.serve(ctx, stop, &http.Server{Handler: http.NotFoundHandler()}, ln, cfg) }()
+	stop()
+
+	select {
+	case err := <-served:
+		if err == nil || !strings.Contains(err.Error(), "cancelled") {
+			t.Errorf("serve = %v, want the jobs cancelled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve still waits for the job")
+	}
+
+	if err := <-jobErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("context of the job = %v, want canceled", err)
+	}
+}
+
+// stopping the jobs lets the running one finish, with a context that is not done
+func TestEveryFinishesRunningJob(t *testing.T) {
+	app := newServeApp(t)
+	ctx, stop := context.WithCancel(context.Background())
+
+	runs := 0
+	release := make(chan struct{})
+	var jobCtx error
+	app.every(ctx, time.Hour, func(ctx context.Context) error {
+		runs++
+		<-release
+		jobCtx = ctx.Err()
+		return nil
+	})
+
+	stop()
+	close(release)
+	app.jobs.Wait()
+
+	if runs != 1 || jobCtx != nil {
+		t.Errorf("runs = %d, context error %v, want one run with a live context", runs, jobCtx)
+	}
+}
@@ -1,9 +1,59 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"net/http"
+
+	"test.iamgak.net/models"
 )
 
+const requestIDHeader = "X-Request-Id"
+
+type contextKey string
+
+// the uid of the logged in user, LoginMiddleware keeps it in the context of
+// the request as every request is served by the same application
+const userIDKey = contextKey("user_id")
+
+// uid of the logged in user of the request, 0 without LoginMiddleware
+func userID(ctx context.Context) int64 {
+	uid, _ := ctx.Value(userIDKey).(int64)
+	return uid
+}
+
+// keeps the X-Request-Id of a proxy in front when it is sane, otherwise gives
+// the request a new one, either way it is answered in the header and in every
+// error body
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			b := make([]byte, 8)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Security-Policy",
@@ -18,7 +68,7 @@ func secureHeaders(next http.Handler) http.Handler {
 
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		app.infoLog.Printf("%s - %s %s %s %s", r.RemoteAddr, w.Header().Get(requestIDHeader), r.Proto, r.Method, r.URL.RequestURI())
 		next.ServeHTTP(w, r)
 	})
 }
@@ -27,28 +77,41 @@ func (app *application) LoginMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("ldata")
 		if err != nil || cookie.Value == "" || len(cookie.Value) != 40 {
-			app.notFound(w)
+			app.CustomError(w, "Please, login first", http.StatusUnauthorized)
 			app.infoLog.Print("Invalid Logout")
 			return
 		}
 
-		userID, err := app.models.Users.ValidUser(cookie.Value)
-		if err != nil {
-			app.serverError(w, err)
+		uid, err := app.models.Users.ValidUser(r.Context(), cookie.Value)
+		if err != nil && !errors.Is(err, models.ErrUserNotFound) {
+			app.modelError(w, err)
 			return
 		}
 
-		if userID > 0 {
-			app.isAuthenticated = true
+		if uid <= 0 {
+			app.CustomError(w, "the login expired, please login again", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey, uid)))
+
+	})
+}
+
+// should be chained after LoginMiddleware, it reads the uid of the request
+func (app *application) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin, err := app.models.Users.IsAdmin(r.Context(), userID(r.Context()))
+		if err != nil {
+			app.modelError(w, err)
+			return
 		}
 
-		if !app.isAuthenticated {
-			http.Redirect(w, r, "/api/user/login/", http.StatusSeeOther)
+		if !admin {
+			app.CustomError(w, "only admins may do this", http.StatusForbidden)
 			return
 		}
 
-		app.user_id = userID
 		next.ServeHTTP(w, r)
-
 	})
 }
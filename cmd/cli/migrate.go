@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"test.iamgak.net/config"
+	"test.iamgak.net/database"
+	"test.iamgak.net/models"
+)
+
+var errMigrateUsage = errors.New("migrate: use up, down, status, create NAME or force VERSION")
+
+// the migrations embedded for the database.driver
+func newMigrator(db *models.DB, driver string) (*models.Migrator, error) {
+	files, err := database.Migrations(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewMigrator(db, files)
+}
+
+// database.migrate, the pending migrations before the server starts
+func migrateUp(ctx context.Context, db *models.DB, driver string, infoLog *log.Logger) error {
+	migrator, err := newMigrator(db, driver)
+	if err != nil {
+		return err
+	}
+
+	done, err := migrator.Up(ctx, 0)
+	for _, mig := range done {
+		infoLog.Printf("migrated to %06d_%s", mig.Version, mig.Name)
+	}
+
+	return err
+}
+
+func (app *application) runMigrate(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	n := fs.Int("n", 0, "migrations to apply or revert, up applies every pending one and down the latest one when 0")
+	dir := fs.String("dir", filepath.Join("database", "migration"), "source directory of the migrations, for create")
+	dsnFlag := fs.String("dsn", cfg.DSN(), "data source name of the database.driver")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: migrate up|down|status|create|force [flags] [NAME|VERSION]")
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return errMigrateUsage
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+
+	if action == "create" {
+		return createMigration(*dir, fs.Arg(0))
+	}
+
+	db, err := openDB(cfg.Database.Driver, *dsnFlag)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(db, cfg.Database.Driver)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		done, err := migrator.Up(ctx, *n)
+		for _, mig := range done {
+			fmt.Printf("applied %06d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "down":
+		if *n == 0 {
+			*n = 1
+		}
+		done, err := migrator.Down(ctx, *n)
+		for _, mig := range done {
+			fmt.Printf("reverted %06d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "status":
+		list, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range list {
+			name := fmt.Sprintf("%06d", s.Version)
+			if s.Migration != nil {
+				name += "_" + s.Name
+			}
+
+			switch {
+			case s.Dirty:
+				fmt.Printf("%-40s dirty   %s\n", name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			case s.Applied:
+				fmt.Printf("%-40s applied %s\n", name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			default:
+				fmt.Printf("%-40s pending\n", name)
+			}
+		}
+		return nil
+	case "force":
+		version, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			fs.Usage()
+			return errMigrateUsage
+		}
+		return migrator.Force(ctx, version)
+	}
+
+	fs.Usage()
+	return errMigrateUsage
+}
+
+// empty up and down files of the next version in the directory of every
+// driver, the versions stay the same across the drivers
+func createMigration(dir, name string) error {
+	name = strings.Trim(strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, name), "_")
+	if name == "" {
+		return errors.New("migrate: create needs a NAME like add_books_isbn13")
+	}
+
+	var version int64
+	for _, d := range models.Dialects {
+		if err := os.MkdirAll(filepath.Join(dir, string(d)), 0o755); err != nil {
+			return err
+		}
+
+		migrations, err := models.ReadMigrations(os.DirFS(filepath.Join(dir, string(d))))
+		if err != nil {
+			return err
+		}
+		if len(migrations) > 0 {
+			version = max(version, migrations[len(migrations)-1].Version)
+		}
+	}
+	version++
+
+	for _, d := range models.Dialects {
+		for _, direction := range []string{"up", "down"} {
+			file := filepath.Join(dir, string(d), fmt.Sprintf("%06d_%s.%s.sql", version, name, direction))
+			body := fmt.Sprintf("-- %s of %s for %s\n", direction, name, d)
+			if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+				return err
+			}
+			fmt.Println(file)
+		}
+	}
+
+	return nil
+}
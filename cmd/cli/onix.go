@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+
+	"test.iamgak.net/config"
+	"test.iamgak.net/models"
+	"test.iamgak.net/onix"
+	"test.iamgak.net/validator"
+)
+
+type onixReport struct {
+	*models.ImportReport
+	Deleted int `json:"deleted"`
+	// element path -> number of products carrying it
+	Unmapped map[string]int `json:"unmapped"`
+}
+
+// load an ONIX 3.0 feed, products are validated like AddBook up to the size of
+// the columns and upserted by isbn, NotificationType 05 deletes the book and 04
+// only replaces the supplied fields. Books are priced in the currency of the
+// store.
+func (app *application) ingestOnix(ctx context.Context, r io.Reader, dryRun bool, by *models.Editor) (*onixReport, error) {
+	feed, err := onix.Parse(r, app.currency)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &onixReport{
+		ImportReport: &models.ImportReport{
+			DryRun: dryRun,
+			Upsert: true,
+			Total:  len(feed.Records),
+			Errors: []*models.ImportRowError{},
+		},
+		Unmapped: map[string]int{},
+	}
+
+	// a later product for the same isbn wins, like applying the deltas in order
+	order := []string{}
+	books := map[string]*models.Book{}
+	deletes := map[string]bool{}
+	for i, rec := range feed.Records {
+		for _, path := range rec.Unmapped {
+			report.Unmapped[path]++
+		}
+
+		validator := &validator.Validator{
+			Errors: make(map[string]string),
+		}
+
+		book := rec.Book
+		if rec.Delete {
+			validator.CheckField(validator.NotBlank(book.ISBN), "isbn", "Product has no ISBN-13 identifier")
+		} else {
+			if rec.Partial && validator.NotBlank(book.ISBN) {
+				book, err = app.mergeBook(ctx, book)
+				if err != nil {
+					return nil, err
+				}
+			}
+			validateBookLimits(validator, book, columnLimits)
+			// a block update without a price keeps the stored one
+			validator.CheckField(rec.Partial || rec.Priced, "price", "Product has no retail price in "+app.currency)
+		}
+
+		if !validator.Valid() {
+			report.Errors = append(report.Errors, &models.ImportRowError{Row: i + 1, ISBN: book.ISBN, Errors: validator.Errors})
+			report.Skipped++
+			continue
+		}
+
+		if _, ok := books[book.ISBN]; !ok && !deletes[book.ISBN] {
+			order = append(order, book.ISBN)
+		}
+
+		if rec.Delete {
+			deletes[book.ISBN] = true
+			delete(books, book.ISBN)
+		} else {
+			delete(deletes, book.ISBN)
+			books[book.ISBN] = book
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	upserts := []*models.Book{}
+	for _, isbn := range order {
+		if book, ok := books[isbn]; ok {
+			upserts = append(upserts, book)
+		}
+	}
+
+	report.Inserted, report.Updated, err = app.models.Books.ImportBooks(ctx, upserts, true, by)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, isbn := range order {
+		if !deletes[isbn] {
+			continue
+		}
+
+		err := app.models.Books.DeleteBook(ctx, isbn, by)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			return nil, err
+		}
+		if err == nil {
+			report.Deleted++
+		}
+	}
+
+	return report, nil
+}
+
+// blank fields of a block update keep the stored value
+func (app *application) mergeBook(ctx context.Context, update *models.Book) (*models.Book, error) {
+	stored, err := app.models.Books.FindBook(ctx, update.ISBN)
+	if errors.Is(err, models.ErrNoRecord) {
+		return update, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Title != "" {
+		stored.Title = update.Title
+	}
+	if update.Author != "" {
+		stored.Author = update.Author
+	}
+	if len(update.Contributors) > 0 {
+		stored.Contributors = update.Contributors
+	} else if update.Author == "" {
+		// keep the editors, translators, ... and not just Book.Author
+		stored.Contributors, err = app.models.Books.Contributors(ctx, stored.ISBN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if update.Genre != "" {
+		stored.Genre = update.Genre
+	} else {
+		// keep every genre the book is filed under and not just Book.Genre
+		stored.Genres, err = app.models.Books.BookGenres(ctx, stored.ISBN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if update.Descriptions != "" {
+		stored.Descriptions = update.Descriptions
+	}
+	if update.Price != 0 {
+		stored.Price = update.Price
+	}
+
+	return stored, nil
+}
+
+// go run ./cmd/cli onix -file feed.xml [-dry-run]
+func (app *application) runOnix(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("onix", flag.ExitOnError)
+	file := fs.String("file", "", "ONIX 3.0 XML file to load")
+	dryRun := fs.Bool("dry-run", false, "map and validate only, nothing is written")
+	dsnFlag := fs.String("dsn", cfg.DSN(), "data source name of the database.driver")
+	fs.Parse(args)
+
+	if *file == "" {
+		fs.Usage()
+		return errors.New("onix: -file is required")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := app.openModels(*dsnFlag, cfg); err != nil {
+		return err
+	}
+	defer app.models.Close()
+	app.currency = cfg.Store.Currency
+
+	report, err := app.ingestOnix(context.Background(), f, *dryRun, cliEditor)
+	if err != nil {
+		return err
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	return out.Encode(report)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"test.iamgak.net/models"
+	"test.iamgak.net/models/memory"
+)
+
+const testOnixProduct = `<Product>
+  <RecordReference>ref-1</RecordReference>
+  <NotificationType>03</NotificationType>
+  <ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>978-0-00-000001-1</IDValue></ProductIdentifier>
+  <DescriptiveDetail>
+    <TitleDetail><TitleType>01</TitleType><TitleElement><TitleElementLevel>01</TitleElementLevel><TitleText>%TITLE%</TitleText></TitleElement></TitleDetail>
+    <Contributor><ContributorRole>A01</ContributorRole><PersonName>Frank Herbert</PersonName></Contributor>
+    <Subject><MainSubject/><SubjectHeadingText>Science Fiction</SubjectHeadingText></Subject>
+  </DescriptiveDetail>
+  <CollateralDetail><TextContent><TextType>03</TextType><Text>%TEXT%</Text></TextContent></CollateralDetail>
+  <ProductSupply><SupplyDetail>
+    <Price><PriceType>01</PriceType><PriceAmount>8.50</PriceAmount></Price>
+    <Price><PriceType>01</PriceType><PriceAmount>9.99</PriceAmount><CurrencyCode>USD</CurrencyCode></Price>
+  </SupplyDetail></ProductSupply>
+</Product>`
+
+func TestIngestOnix(t *testing.T) {
+	app := &application{
+		infoLog:  log.New(io.Discard, "", 0),
+		errorLog: log.New(io.Discard, "", 0),
+		models:   memory.Models(memory.New()),
+		currency: "USD",
+	}
+
+	// longer than AddBook takes, within the columns
+	title := strings.Repeat("Dune ", 24)[:119]
+	text := strings.Repeat("A desert planet. ", 60)
+	product := strings.NewReplacer("%TITLE%", title, "%TEXT%", text).Replace(testOnixProduct)
+	feed := `<ONIXMessage release="3.0"><Header><DefaultCurrencyCode>EUR</DefaultCurrencyCode></Header>` + product + `</ONIXMessage>`
+
+	report, err := app.ingestOnix(context.Background(), strings.NewReader(feed), false, &models.Editor{Admin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Inserted != 1 || len(report.Errors) != 0 {
+		t.Fatalf("report = %+v, %v, want the product inserted", report.ImportReport, report.Errors)
+	}
+	if report.Unmapped["ProductSupply/SupplyDetail/Price/EUR"] != 1 {
+		t.Errorf("unmapped = %v, want the EUR price", report.Unmapped)
+	}
+
+	book, err := app.models.Books.FindBook(context.Background(), "978-0-00-000001-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Title != title || book.Descriptions != strings.TrimSpace(text) {
+		t.Errorf("book = %q, %d characters of description, want the whole title and text", book.Title, len(book.Descriptions))
+	}
+	if book.Price != 9.99 {
+		t.Errorf("price = %v, want the USD price 9.99", book.Price)
+	}
+
+	// no title is longer than the column
+	long := strings.NewReplacer("%TITLE%", strings.Repeat("x", 256), "%TEXT%", text).Replace(testOnixProduct)
+	report, err = app.ingestOnix(context.Background(), strings.NewReader(`<ONIXMessage release="3.0">`+long+`</ONIXMessage>`), true, &models.Editor{Admin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != 1 || len(report.Errors) != 1 || report.Errors[0].Errors["title"] == "" {
+		t.Errorf("report of a title of 256 = %+v, want the title rejected", report.Errors)
+	}
+
+	// a product priced in EUR only does not overwrite the stored price
+	euro := strings.Replace(product, "<CurrencyCode>USD</CurrencyCode>", "", 1)
+	report, err = app.ingestOnix(context.Background(), strings.NewReader(feed[:strings.Index(feed, "<Product>")]+euro+`</ONIXMessage>`), false, &models.Editor{Admin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != 1 || len(report.Errors) != 1 || report.Errors[0].Errors["price"] != "Product has no retail price in USD" {
+		t.Errorf("report of a EUR product = %+v, want the price rejected", report.Errors)
+	}
+	if book, _ := app.models.Books.FindBook(context.Background(), "978-0-00-000001-1"); book == nil || book.Price != 9.99 {
+		t.Errorf("book after a EUR product = %+v, want the stored price", book)
+	}
+}
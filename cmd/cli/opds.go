@@ -0,0 +1,217 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"test.iamgak.net/models"
+	"test.iamgak.net/opds"
+)
+
+// books per page of an acquisition feed
+const opdsPerPage = 20
+
+// "/opds" serves OPDS 1.2 (Atom), "/opds2" serves OPDS 2.0 (JSON)
+func opdsPrefix(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, "/opds2") {
+		return "/opds2"
+	}
+
+	return "/opds"
+}
+
+// page query parameter, 1 when missing or invalid
+func pageParam(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+
+	return page
+}
+
+func (app *application) sendOPDS(w http.ResponseWriter, r *http.Request, feed *opds.Feed) {
+	feed.Currency = app.currency
+	var err error
+	if opdsPrefix(r) == "/opds2" {
+		w.Header().Set("Content-Type", opds.JSONType)
+		err = opds.WriteJSON(w, feed)
+	} else {
+		w.Header().Set("Content-Type", opds.AtomType+";kind="+feed.Kind)
+		err = opds.WriteAtom(w, feed)
+	}
+
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// start of the catalog, links to the genre and author navigation and all the books
+func (app *application) OPDSRoot(w http.ResponseWriter, r *http.Request) {
+	prefix := opdsPrefix(r)
+	genres, err := app.models.Books.Genres(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	authors, err := app.models.Books.Authors(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	total := 0
+	for _, g := range genres {
+		total += g.Count
+	}
+
+	feed := &opds.Feed{
+		ID:      "urn:bookstore:catalog",
+		Title:   opds.StoreName + " Catalog",
+		Updated: time.Now(),
+		Kind:    opds.KindNavigation,
+		Self:    prefix,
+		Links:   []opds.Link{{Rel: "start", Href: prefix, Kind: opds.KindNavigation}},
+		Navigation: []opds.Navigation{
+			{Title: "All Books", Href: prefix + "/books", Count: total},
+			{Title: "By Genre", Href: prefix + "/genres", Count: len(genres)},
+			{Title: "By Author", Href: prefix + "/authors", Count: len(authors)},
+		},
+	}
+
+	app.sendOPDS(w, r, feed)
+}
+
+// navigation feed with one entry per genre
+func (app *application) OPDSGenres(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Books.Genres(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendOPDS(w, r, app.opdsFacetFeed(r, "genre", "Genres", genres))
+}
+
+// navigation feed with one entry per author
+func (app *application) OPDSAuthors(w http.ResponseWriter, r *http.Request) {
+	authors, err := app.models.Books.Authors(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendOPDS(w, r, app.opdsFacetFeed(r, "author", "Authors", authors))
+}
+
+func (app *application) opdsFacetFeed(r *http.Request, kind, title string, facets []*models.Facet) *opds.Feed {
+	prefix := opdsPrefix(r)
+	feed := &opds.Feed{
+		ID:      "urn:bookstore:" + kind + "s",
+		Title:   title,
+		Updated: time.Now(),
+		Kind:    opds.KindNavigation,
+		Self:    prefix + "/" + kind + "s",
+		Links: []opds.Link{
+			{Rel: "start", Href: prefix, Kind: opds.KindNavigation},
+			{Rel: "up", Href: prefix, Kind: opds.KindNavigation},
+		},
+	}
+
+	for _, f := range facets {
+		feed.Navigation = append(feed.Navigation, opds.Navigation{
+			Title: f.Name,
+			Href:  prefix + "/" + kind + "/" + url.PathEscape(f.Name),
+			Count: f.Count,
+		})
+	}
+
+	return feed
+}
+
+// acquisition feed of every book
+func (app *application) OPDSBooks(w http.ResponseWriter, r *http.Request) {
+	prefix := opdsPrefix(r)
+	app.opdsAcquisition(w, r, models.BookFilter{}, "urn:bookstore:books", "All Books", prefix+"/books", prefix)
+}
+
+// acquisition feed of one genre
+func (app *application) OPDSGenre(w http.ResponseWriter, r *http.Request) {
+	prefix := opdsPrefix(r)
+	genre := httprouter.ParamsFromContext(r.Context()).ByName("name")
+	self := prefix + "/genre/" + url.PathEscape(genre)
+	app.opdsAcquisition(w, r, models.BookFilter{Genre: genre}, "urn:bookstore:genre:"+genre, genre, self, prefix+"/genres")
+}
+
+// acquisition feed of one author
+func (app *application) OPDSAuthor(w http.ResponseWriter, r *http.Request) {
+	prefix := opdsPrefix(r)
+	author := httprouter.ParamsFromContext(r.Context()).ByName("name")
+	self := prefix + "/author/" + url.PathEscape(author)
+	app.opdsAcquisition(w, r, models.BookFilter{Author: author}, "urn:bookstore:author:"+author, author, self, prefix+"/authors")
+}
+
+// search results by title, author or isbn, the target of the OpenSearch template
+func (app *application) OPDSSearch(w http.ResponseWriter, r *http.Request) {
+	prefix := opdsPrefix(r)
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		app.CustomError(w, "q parameter is required", 400)
+		return
+	}
+
+	self := prefix + "/search?q=" + url.QueryEscape(q)
+	app.opdsAcquisition(w, r, models.BookFilter{Query: q}, "urn:bookstore:search:"+q, "Search: "+q, self, prefix)
+}
+
+func (app *application) opdsAcquisition(w http.ResponseWriter, r *http.Request, filter models.BookFilter, id, title, self, up string) {
+	prefix := opdsPrefix(r)
+	page := pageParam(r)
+	books, total, err := app.models.Books.FilterBooks(r.Context(), filter, opdsPerPage, (page-1)*opdsPerPage)
+	if err == nil {
+		err = app.loadContributors(r.Context(), books)
+	}
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	feed := &opds.Feed{
+		ID:      id,
+		Title:   title,
+		Updated: time.Now(),
+		Kind:    opds.KindAcquisition,
+		Self:    self,
+		Links: []opds.Link{
+			{Rel: "start", Href: prefix, Kind: opds.KindNavigation},
+			{Rel: "up", Href: up, Kind: opds.KindNavigation},
+		},
+		Total:   total,
+		PerPage: opdsPerPage,
+		Page:    page,
+	}
+
+	feed.Paginate(self)
+	for _, book := range books {
+		feed.Publications = append(feed.Publications, opds.Publication{
+			Book: book,
+			Href: "/book/search/" + url.PathEscape(book.ISBN) + "/",
+		})
+	}
+
+	app.sendOPDS(w, r, feed)
+}
+
+// OpenSearch description, templates need absolute urls
+func (app *application) OPDSOpenSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", opds.OpenSearchType)
+	err := opds.WriteOpenSearch(w, app.baseURL)
+	if err != nil {
+		app.errorLog.Print(err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"test.iamgak.net/opds"
+)
+
+func TestOPDSRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	tests := []struct {
+		path string
+		// in the body of both versions, "" for none
+		want string
+		// a feed of books without Dune
+		without bool
+	}{
+		{"", "/genres", false},
+		{"/genres", "Science Fiction", false},
+		{"/authors", "Frank Herbert", false},
+		{"/books", "Dune", false},
+		{"/genre/Science%20Fiction", "Dune", false},
+		{"/genre/Poetry", "", true},
+		{"/author/Frank%20Herbert", "Dune", false},
+		{"/author/Isaac%20Asimov", "", true},
+		{"/search?q=dune", "Dune", false},
+		{"/search?q=foundation", "", true},
+	}
+
+	for _, prefix := range []string{"/opds", "/opds2"} {
+		contentType := opds.AtomType
+		if prefix == "/opds2" {
+			contentType = opds.JSONType
+		}
+
+		for _, tt := range tests {
+			w := ts.do(http.MethodGet, prefix+tt.path, "", "")
+			ts.expect(w, http.StatusOK)
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, contentType) {
+				t.Errorf("%s%s is %s, want %s", prefix, tt.path, got, contentType)
+			}
+
+			body := w.Body.String()
+			if !strings.Contains(body, tt.want) || tt.without && strings.Contains(body, "Dune") {
+				t.Errorf("%s%s = %s, want %q", prefix, tt.path, body, tt.want)
+			}
+		}
+
+		ts.expect(ts.do(http.MethodGet, prefix+"/search?q=+", "", ""), http.StatusBadRequest)
+	}
+
+	// every author on their own, the other roles as contributors
+	ts.expect(ts.do(http.MethodPost, "/book/create", creditedBook, admin), http.StatusOK)
+	credits := map[string][]string{
+		"/opds":  {"<name>Frank Herbert</name>", "<name>Brian Herbert</name>", "<contributor>", "<name>Ana Ruiz</name>"},
+		"/opds2": {`"author":[{"name":"Frank Herbert"},{"name":"Brian Herbert"}]`, `"translator":[{"name":"Ana Ruiz"}]`},
+	}
+	for prefix, want := range credits {
+		body := ts.do(http.MethodGet, prefix+"/search?q=messiah", "", "").Body.String()
+		for _, text := range want {
+			if !strings.Contains(body, text) {
+				t.Errorf("%s/search?q=messiah = %s, want %q", prefix, body, text)
+			}
+		}
+	}
+
+	w := ts.do(http.MethodGet, "/opds/opensearch.xml", "", "")
+	ts.expect(w, http.StatusOK)
+	if w.Header().Get("Content-Type") != opds.OpenSearchType || !strings.Contains(w.Body.String(), testBaseURL+"/opds/search?q={searchTerms}") {
+		t.Errorf("opensearch = %s", w.Body.String())
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"test.iamgak.net/models"
+	"test.iamgak.net/opds"
+)
+
+// highest value of reviews.rating
+const bestRating = 5
+
+var bookPageTemplate = template.Must(template.New("book").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Book.Title}} by {{.Book.Author}} | {{.SiteName}}</title>
+<meta name="description" content="{{.Book.Descriptions}}">
+<link rel="canonical" href="{{.URL}}">
+<meta property="og:type" content="book">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:title" content="{{.Book.Title}}">
+<meta property="og:description" content="{{.Book.Descriptions}}">
+<meta property="og:url" content="{{.URL}}">
+<meta property="book:isbn" content="{{.Book.ISBN}}">
+{{range .Authors}}<meta property="book:author" content="{{.}}">
+{{end}}{{range .Genres}}<meta property="book:tag" content="{{.}}">
+{{end}}<meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="{{.Book.Title}}">
+<meta name="twitter:description" content="{{.Book.Descriptions}}">
+<script type="application/ld+json">{{.JSONLD}}</script>
+</head>
+<body>
+<h1>{{.Book.Title}}</h1>
+<p>by {{range $i, $c := .Credits}}{{if $i}}, {{end}}<a href="/author/{{$c.AuthorID}}">{{$c.Name}}</a>{{if ne $c.Role "author"}} ({{$c.Role}}){{end}}{{else}}{{.Book.Author}}{{end}}</p>
+<p>ISBN {{.Book.ISBN}} &middot; {{.Book.Genre}} &middot; {{.Price}} {{.Currency}}</p>
+<p>{{.Book.Descriptions}}</p>
+{{if .Reviews}}<h2>Reviews ({{.Rating}} / {{.BestRating}})</h2>
+{{range .Reviews}}<article>
+<h3>{{.Title}} &middot; {{.Rating}} / {{$.BestRating}}</h3>
+<p>{{.Descriptions}}</p>
+</article>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// schema.org BookFormatType of models.Formats
+var bookFormats = map[string]string{
+	"hardcover": "https://schema.org/Hardcover",
+	"paperback": "https://schema.org/Paperback",
+	"ebook":     "https://schema.org/EBook",
+	"audiobook": "https://schema.org/AudiobookFormat",
+}
+
+type bookPage struct {
+	SiteName   string
+	URL        string
+	Book       *models.Book
+	Authors    []string
+	Credits    []*models.Contributor
+	Genres     []string
+	Price      string
+	Currency   string
+	Reviews    []*models.Review
+	Rating     string
+	BestRating int
+	JSONLD     template.JS
+}
+
+// /book/:isbn is the html page, /book/:isbn.marcxml and /book/:isbn.mrc the MARC record
+func (app *application) BookResource(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	switch path.Ext(params.ByName("isbn")) {
+	case "":
+		app.BookPage(w, r)
+	case ".marcxml", ".mrc":
+		app.BookMARC(w, r)
+	default:
+		app.notFound(w)
+	}
+}
+
+// server rendered book page with schema.org JSON-LD and Open Graph tags for
+// search engines and link previews
+func (app *application) BookPage(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	isbn := params.ByName("isbn")
+	book, err := app.models.Books.FindBook(r.Context(), isbn)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	reviews, err := app.models.Review.GetReviewByIsbn(r.Context(), isbn)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	avg, count, err := app.models.Review.RatingSummary(r.Context(), isbn)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	book.Contributors, err = app.models.Books.Contributors(r.Context(), isbn)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	genres := []string{}
+	filed, err := app.models.Books.BookGenres(r.Context(), isbn)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+	for _, g := range filed {
+		genres = append(genres, g.Name)
+	}
+	if len(genres) == 0 && book.Genre != "" {
+		genres = append(genres, book.Genre)
+	}
+
+	page := &bookPage{
+		SiteName:   opds.StoreName,
+		URL:        app.baseURL + "/book/" + url.PathEscape(book.ISBN),
+		Book:       book,
+		Authors:    book.CreditedAs(models.RoleAuthor),
+		Credits:    book.Credits(),
+		Genres:     genres,
+		Price:      strconv.FormatFloat(float64(book.Price), 'f', 2, 32),
+		Currency:   app.currency,
+		Reviews:    reviews,
+		Rating:     strconv.FormatFloat(avg, 'f', 1, 64),
+		BestRating: bestRating,
+	}
+
+	ld, err := bookJSONLD(page, avg, count)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	page.JSONLD = ld
+	app.countView(r.Context(), book.ISBN)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := bookPageTemplate.Execute(w, page); err != nil {
+		app.errorLog.Print(err)
+	}
+}
+
+// schema.org Book with its Offer, AggregateRating and Reviews
+func bookJSONLD(page *bookPage, avg float64, count int) (template.JS, error) {
+	type thing = map[string]any
+
+	people := func(names []string) []thing {
+		list := []thing{}
+		for _, name := range names {
+			list = append(list, thing{"@type": "Person", "name": name})
+		}
+		return list
+	}
+
+	ld := thing{
+		"@context":    "https://schema.org",
+		"@type":       "Book",
+		"@id":         page.URL,
+		"url":         page.URL,
+		"name":        page.Book.Title,
+		"isbn":        page.Book.ISBN,
+		"author":      people(page.Authors),
+		"description": page.Book.Descriptions,
+		"genre":       page.Genres,
+		"offers": thing{
+			"@type":         "Offer",
+			"url":           page.URL,
+			"price":         page.Price,
+			"priceCurrency": page.Currency,
+			"availability":  "https://schema.org/InStock",
+		},
+	}
+
+	if format, ok := bookFormats[page.Book.Format]; ok {
+		ld["bookFormat"] = format
+	}
+	if page.Book.Language != "" {
+		ld["inLanguage"] = page.Book.Language
+	}
+
+	// schema.org Book has the same properties as our other roles
+	for _, role := range []string{models.RoleEditor, models.RoleTranslator, models.RoleIllustrator} {
+		if names := page.Book.CreditedAs(role); len(names) > 0 {
+			ld[role] = people(names)
+		}
+	}
+
+	if count > 0 {
+		ld["aggregateRating"] = thing{
+			"@type":       "AggregateRating",
+			"ratingValue": page.Rating,
+			"reviewCount": count,
+			"bestRating":  bestRating,
+			"worstRating": 1,
+		}
+
+		reviews := []thing{}
+		for _, review := range page.Reviews {
+			reviews = append(reviews, thing{
+				"@type":      "Review",
+				"name":       review.Title,
+				"reviewBody": review.Descriptions,
+				"author":     thing{"@type": "Person", "name": "Reader " + strconv.FormatInt(review.Uid, 10)},
+				"reviewRating": thing{
+					"@type":       "Rating",
+					"ratingValue": review.Rating,
+					"bestRating":  bestRating,
+				},
+			})
+		}
+		ld["review"] = reviews
+	}
+
+	// json.Marshal escapes <, > and &, so the result can not close the script tag
+	data, err := json.Marshal(ld)
+	return template.JS(data), err
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// every book page with the date the book last changed
+func (app *application) Sitemap(w http.ResponseWriter, r *http.Request) {
+	stamps, err := app.models.Books.BookStamps(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	base := app.baseURL
+	set := sitemapURLSet{URLs: []sitemapURL{{Loc: base + "/"}}}
+	for _, stamp := range stamps {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     base + "/book/" + url.PathEscape(stamp.ISBN),
+			LastMod: stamp.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(set); err != nil {
+		app.errorLog.Print(err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"test.iamgak.net/marc"
+)
+
+func TestBookPageRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	_, reader := ts.login("reader@example.com", "user")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/review/create", `{"isbn":"978-0-00-000001-1","title":"A classic","rating":4,"price":9.99,"descriptions":"Spice must flow"}`, reader), http.StatusOK)
+
+	w := ts.do(http.MethodGet, "/book/978-0-00-000001-1", "", "")
+	ts.expect(w, http.StatusOK)
+	body := w.Body.String()
+	for _, want := range []string{
+		"<title>Dune",
+		`"@type":"Book"`,
+		`<meta property="og:url" content="` + testBaseURL + `/book/978-0-00-000001-1">`,
+		"Frank Herbert",
+		"Spice must flow",
+		`"ratingValue":"4.0"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("book page = %s, want %s", body, want)
+		}
+	}
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-00-000009-7", "", ""), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-00-000001-1.pdf", "", ""), http.StatusNotFound)
+
+	w = ts.do(http.MethodGet, "/book/978-0-00-000001-1.marcxml", "", "")
+	ts.expect(w, http.StatusOK)
+	records, err := marc.ReadXML(w.Body)
+	if err != nil || len(records) != 1 || records[0].Value("245", "a") == "" {
+		t.Errorf("marcxml = %v, %v, want the record of Dune", records, err)
+	}
+
+	w = ts.do(http.MethodGet, "/book/978-0-00-000001-1.mrc", "", "")
+	ts.expect(w, http.StatusOK)
+	if w.Header().Get("Content-Type") != "application/marc" {
+		t.Errorf("mrc is %s", w.Header().Get("Content-Type"))
+	}
+	records, err = marc.ReadBinary(bytes.NewReader(w.Body.Bytes()))
+	if err != nil || len(records) != 1 {
+		t.Fatalf("mrc = %v, %v, want one record", records, err)
+	}
+	if book, _ := records[0].Book(); book.ISBN != "978-0-00-000001-1" || book.Author != "Frank Herbert" {
+		t.Errorf("book of the mrc = %+v", book)
+	}
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-00-000009-7.mrc", "", ""), http.StatusNotFound)
+
+	w = ts.do(http.MethodGet, "/sitemap.xml", "", "")
+	ts.expect(w, http.StatusOK)
+	if body := w.Body.String(); !strings.Contains(body, "<loc>"+testBaseURL+"/book/978-0-00-000001-1</loc>") || !strings.Contains(body, "<lastmod>") {
+		t.Errorf("sitemap = %s, want the page of Dune", body)
+	}
+}
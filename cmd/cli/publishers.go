@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"test.iamgak.net/models"
+	"test.iamgak.net/validator"
+)
+
+type publisherDashboard struct {
+	Publisher *models.Publisher    `json:"publisher"`
+	Since     string               `json:"since,omitempty"`
+	Titles    []*models.TitleStats `json:"titles"`
+	UnitsSold int                  `json:"units_sold"`
+	Revenue   float64              `json:"revenue"`
+	Reviews   int                  `json:"reviews"`
+	// average over every review of every title
+	Rating float64 `json:"rating"`
+}
+
+// publisher of :id with the role of the logged in user, admins count as owners,
+// 404 for an unknown publisher and 403 for users outside of it
+func (app *application) publisherAccess(w http.ResponseWriter, r *http.Request) (*models.Publisher, string, bool) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return nil, "", false
+	}
+
+	publisher, err := app.models.Publishers.GetPublisher(r.Context(), id)
+	if err != nil {
+		app.modelError(w, err)
+		return nil, "", false
+	}
+
+	by, err := app.editor(r.Context())
+	if err != nil {
+		app.modelError(w, err)
+		return nil, "", false
+	}
+
+	role := models.PublisherOwner
+	if !by.Admin {
+		role, err = app.models.Publishers.MemberRole(r.Context(), id, userID(r.Context()))
+		if err != nil {
+			app.modelError(w, err)
+			return nil, "", false
+		}
+	}
+
+	if role == "" {
+		app.clientError(w, http.StatusForbidden)
+		return nil, "", false
+	}
+
+	return publisher, role, true
+}
+
+// publishers the logged in user is a member of, with their role
+func (app *application) MyPublishers(w http.ResponseWriter, r *http.Request) {
+	publishers, err := app.models.Publishers.UserPublishers(r.Context(), userID(r.Context()))
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendJSONResponse(w, 200, publishers)
+}
+
+// titles of the publisher with units sold, revenue and reviews, ?since=2006-01-02
+// counts the sales and reviews from that day on, members only
+func (app *application) PublisherDashboard(w http.ResponseWriter, r *http.Request) {
+	publisher, _, ok := app.publisherAccess(w, r)
+	if !ok {
+		return
+	}
+
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.DateOnly, s); err != nil {
+			app.CustomError(w, "since should be a date like 2006-01-02", 400)
+			return
+		}
+	}
+
+	titles, err := app.models.Books.PublisherTitles(r.Context(), publisher.ID, since)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	dashboard := &publisherDashboard{Publisher: publisher, Since: r.URL.Query().Get("since"), Titles: titles}
+	var ratings float64
+	for _, t := range titles {
+		dashboard.UnitsSold += t.UnitsSold
+		dashboard.Revenue += t.Revenue
+		dashboard.Reviews += t.Reviews
+		ratings += t.Rating * float64(t.Reviews)
+	}
+
+	if dashboard.Reviews > 0 {
+		dashboard.Rating = ratings / float64(dashboard.Reviews)
+	}
+
+	app.sendJSONResponse(w, 200, dashboard)
+}
+
+// members of the publisher with their role, members only
+func (app *application) PublisherMembers(w http.ResponseWriter, r *http.Request) {
+	publisher, _, ok := app.publisherAccess(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := app.models.Publishers.Members(r.Context(), publisher.ID)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendJSONResponse(w, 200, members)
+}
+
+// email and role of the member of the request, owners only
+func (app *application) decodeMember(w http.ResponseWriter, r *http.Request) (*models.Publisher, *models.Member, bool) {
+	publisher, role, ok := app.publisherAccess(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if role != models.PublisherOwner {
+		app.clientError(w, http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	var member *models.Member
+	err := json.NewDecoder(r.Body).Decode(&member)
+	if err != nil || member == nil {
+		app.CustomError(w, "Unsupported or empty fields", 400)
+		return nil, nil, false
+	}
+
+	member.Email = strings.TrimSpace(member.Email)
+	validator := &validator.Validator{
+		Errors: make(map[string]string),
+	}
+
+	validator.CheckField(validator.NotBlank(member.Email), "email", "Please, fill the email field")
+	if validator.Valid() {
+		member.UID, err = app.models.Users.EmailExist(r.Context(), member.Email)
+		if err != nil {
+			app.modelError(w, err)
+			return nil, nil, false
+		}
+		validator.CheckField(member.UID > 0, "email", "No user registered with this email")
+	}
+
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return nil, nil, false
+	}
+
+	return publisher, member, true
+}
+
+// add a user to the publisher or change their role, owners only
+func (app *application) SetPublisherMember(w http.ResponseWriter, r *http.Request) {
+	publisher, member, ok := app.decodeMember(w, r)
+	if !ok {
+		return
+	}
+
+	if member.Role == "" {
+		member.Role = models.PublisherEditor
+	}
+
+	if member.Role != models.PublisherOwner && member.Role != models.PublisherEditor {
+		validator := &validator.Validator{Errors: map[string]string{"role": "Please, use owner or editor as role"}}
+		app.failedValidation(w, validator)
+		return
+	}
+
+	err := app.models.Publishers.SetMember(r.Context(), publisher.ID, member.UID, member.Role)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Publisher Member Updated", userID(r.Context()))
+	resp := app.sendMessage(true, "Publisher Member Saved, Sucessfully")
+	app.sendJSONResponse(w, 200, resp)
+}
+
+// take a user out of the publisher, owners only
+func (app *application) RemovePublisherMember(w http.ResponseWriter, r *http.Request) {
+	publisher, member, ok := app.decodeMember(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Publishers.RemoveMember(r.Context(), publisher.ID, member.UID)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Publisher Member Removed", userID(r.Context()))
+	resp := app.sendMessage(true, "Publisher Member Removed")
+	app.sendJSONResponse(w, 200, resp)
+}
+
+// new publisher with the user of owner (an email) as its owner, admin only
+func (app *application) CreatePublisher(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name  string `json:"name"`
+		Owner string `json:"owner"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		app.CustomError(w, "Unsupported or empty fields", 400)
+		return
+	}
+
+	publisher := &models.Publisher{Name: strings.Join(strings.Fields(input.Name), " ")}
+	validator := &validator.Validator{
+		Errors: make(map[string]string),
+	}
+
+	validator.CheckField(validator.NotBlank(publisher.Name), "name", "Please, fill the name field")
+	validator.CheckField(validator.MaxChars(publisher.Name, 255), "name", "Please, fill the NAME shorter than 255")
+	owner, err := app.models.Users.EmailExist(r.Context(), strings.TrimSpace(input.Owner))
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+	validator.CheckField(owner > 0, "owner", "Please, fill the email of a registered user as owner")
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return
+	}
+
+	err = app.models.Publishers.CreatePublisher(r.Context(), publisher, owner)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Publisher Created", userID(r.Context()))
+	app.sendJSONResponse(w, 200, publisher)
+}
+
+// record a sale of a book, amount defaults to quantity times the price, admin only
+func (app *application) RecordSale(w http.ResponseWriter, r *http.Request) {
+	var sale *models.Sale
+	err := json.NewDecoder(r.Body).Decode(&sale)
+	if err != nil || sale == nil {
+		app.CustomError(w, "Unsupported or empty fields", 400)
+		return
+	}
+
+	sale.ISBN = strings.TrimSpace(sale.ISBN)
+	validator := &validator.Validator{
+		Errors: make(map[string]string),
+	}
+
+	validator.CheckField(validator.NotBlank(sale.ISBN), "isbn", "Please, fill the isbn field")
+	validator.CheckField(sale.Quantity > 0, "quantity", "Please, fill the quantity with a positive number")
+	validator.CheckField(sale.Amount >= 0, "amount", "Please, fill the amount with a positive number or leave it out")
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return
+	}
+
+	book, err := app.models.Books.FindBook(r.Context(), sale.ISBN)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	if sale.Amount == 0 {
+		sale.Amount = float64(book.Price) * float64(sale.Quantity)
+	}
+
+	if err := app.models.Books.RecordSale(r.Context(), sale); err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Sale Recorded", userID(r.Context()))
+	resp := app.sendMessage(true, "Sale Recorded, Sucessfully")
+	app.sendJSONResponse(w, 200, resp)
+}
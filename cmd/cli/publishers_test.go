@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+func TestPublisherRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	_, owner := ts.login("owner@example.com", "user")
+	editorID, editor := ts.login("editor@example.com", "user")
+	_, outsider := ts.login("outsider@example.com", "user")
+
+	ts.expect(ts.do(http.MethodPost, "/admin/publisher/create", `{"name":"Chilton","owner":"owner@example.com"}`, owner), http.StatusForbidden)
+	ts.expect(ts.do(http.MethodPost, "/admin/publisher/create", `{"name":"Chilton","owner":"nobody@example.com"}`, admin), http.StatusUnprocessableEntity)
+	w := ts.do(http.MethodPost, "/admin/publisher/create", `{"name":"Chilton","owner":"owner@example.com"}`, admin)
+	ts.expect(w, http.StatusOK)
+	publisher := decode[*models.Publisher](t, w)
+	base := "/publisher/" + strconv.FormatInt(publisher.ID, 10)
+
+	mine := decode[[]*models.Publisher](t, ts.do(http.MethodGet, "/publishers", "", owner))
+	if len(mine) != 1 || mine[0].Name != "Chilton" || mine[0].Role != models.PublisherOwner {
+		t.Errorf("publishers of the owner = %+v", mine)
+	}
+
+	ts.expect(ts.do(http.MethodPost, base+"/member", `{"email":"editor@example.com"}`, outsider), http.StatusForbidden)
+	ts.expect(ts.do(http.MethodPost, base+"/member", `{"email":"nobody@example.com"}`, owner), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, base+"/member", `{"email":"editor@example.com","role":"reader"}`, owner), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, base+"/member", `{"email":"editor@example.com"}`, owner), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, base+"/member", `{"email":"outsider@example.com"}`, editor), http.StatusForbidden)
+
+	members := decode[[]*models.Member](t, ts.do(http.MethodGet, base+"/members", "", editor))
+	if len(members) != 2 {
+		t.Errorf("members = %+v, want the owner and the editor", members)
+	}
+	ts.expect(ts.do(http.MethodGet, base+"/members", "", outsider), http.StatusForbidden)
+	ts.expect(ts.do(http.MethodGet, "/publisher/999/members", "", admin), http.StatusNotFound)
+
+	// the book of a member is listed under their publisher
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, editor), http.StatusOK)
+	if book := ts.book("978-0-00-000001-1"); book.PublisherID != publisher.ID {
+		t.Errorf("publisher of the book of %d = %d, want %d", editorID, book.PublisherID, publisher.ID)
+	}
+
+	ts.expect(ts.do(http.MethodPost, "/admin/sale/create", `{"isbn":"978-0-00-000001-1","quantity":3}`, owner), http.StatusForbidden)
+	ts.expect(ts.do(http.MethodPost, "/admin/sale/create", `{"isbn":"978-0-00-000001-1","quantity":0}`, admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/admin/sale/create", `{"isbn":"978-0-00-000009-7","quantity":1}`, admin), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodPost, "/admin/sale/create", `{"isbn":"978-0-00-000001-1","quantity":3}`, admin), http.StatusOK)
+
+	dashboard := decode[publisherDashboard](t, ts.do(http.MethodGet, base+"/dashboard", "", owner))
+	if len(dashboard.Titles) != 1 || dashboard.UnitsSold != 3 || dashboard.Revenue < 29.96 || dashboard.Revenue > 29.98 {
+		t.Errorf("dashboard = %+v, want 3 copies of Dune for 29.97", dashboard)
+	}
+	dashboard = decode[publisherDashboard](t, ts.do(http.MethodGet, base+"/dashboard?since=2999-01-01", "", owner))
+	if dashboard.UnitsSold != 0 {
+		t.Errorf("dashboard since 2999 = %+v, want no sales", dashboard)
+	}
+	ts.expect(ts.do(http.MethodGet, base+"/dashboard?since=yesterday", "", owner), http.StatusBadRequest)
+	ts.expect(ts.do(http.MethodGet, base+"/dashboard", "", outsider), http.StatusForbidden)
+
+	ts.expect(ts.do(http.MethodPost, base+"/member/delete", `{"email":"editor@example.com"}`, editor), http.StatusForbidden)
+	ts.expect(ts.do(http.MethodPost, base+"/member/delete", `{"email":"editor@example.com"}`, owner), http.StatusOK)
+	ts.expect(ts.do(http.MethodGet, base+"/members", "", editor), http.StatusForbidden)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"test.iamgak.net/models"
+	"test.iamgak.net/recommend"
+)
+
+const (
+	// books in every list and neighbours kept per book
+	recommendPerList = 20
+	// readers who rated both books before a pair counts as similar
+	recommendMinCommon = 2
+)
+
+type recommendations struct {
+	// false when the reader had no ratings at the last run and gets the best rated books
+	Personal bool           `json:"personal"`
+	Books    []*models.Book `json:"books"`
+}
+
+// lists of every reader (collaborative filtering, topped up by genre and author
+// similarity, then by the best rated books) and of every book
+func (app *application) refreshRecommendations(ctx context.Context, ttl time.Duration) error {
+	start := time.Now()
+	ratings, err := app.models.Recommend.Ratings(ctx)
+	if err != nil {
+		return err
+	}
+
+	features, err := app.models.Recommend.BookFeatures(ctx)
+	if err != nil {
+		return err
+	}
+
+	items := recommend.ItemSimilarity(ratings, recommendMinCommon, recommendPerList)
+	content := recommend.ContentSimilarity(features, recommendPerList)
+	recs := &models.Recommendations{
+		Users:   map[int64][]recommend.Scored{},
+		Similar: recommend.Neighbours{},
+		Popular: recommend.Popular(ratings, recommendPerList),
+	}
+
+	rated := map[int64]map[string]float64{}
+	for _, r := range ratings {
+		if rated[r.UID] == nil {
+			rated[r.UID] = map[string]float64{}
+		}
+		rated[r.UID][r.ISBN] = r.Score
+	}
+
+	for uid, books := range rated {
+		recs.Users[uid] = recommend.Merge(recommendPerList, books,
+			recommend.Recommend(books, items, recommendPerList),
+			recommend.Recommend(books, content, recommendPerList),
+			recs.Popular)
+	}
+
+	for _, sims := range []recommend.Neighbours{items, content} {
+		for isbn := range sims {
+			if _, ok := recs.Similar[isbn]; !ok {
+				recs.Similar[isbn] = recommend.Merge(recommendPerList, nil, items[isbn], content[isbn])
+			}
+		}
+	}
+
+	if err := app.models.Recommend.SaveRecommendations(ctx, recs, ttl); err != nil {
+		return err
+	}
+
+	app.infoLog.Printf("recommendations for %d readers and %d books in %s", len(recs.Users), len(recs.Similar), time.Since(start))
+	return nil
+}
+
+// books for the logged in user from the last run of the job
+func (app *application) Recommendations(w http.ResponseWriter, r *http.Request) {
+	list, err := app.models.Recommend.ForUser(r.Context(), userID(r.Context()))
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	resp := &recommendations{Personal: list != nil}
+	if !resp.Personal {
+		if list, err = app.models.Recommend.Popular(r.Context()); err != nil {
+			app.modelError(w, err)
+			return
+		}
+	}
+
+	resp.Books, err = app.scoredBooks(r.Context(), list)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendJSONResponse(w, 200, resp)
+}
+
+// books read by the readers of the book of isbn and books with its genres and
+// authors, books newer than the last run of the job by genres and authors only
+func (app *application) SimilarBooks(w http.ResponseWriter, r *http.Request) {
+	isbn := httprouter.ParamsFromContext(r.Context()).ByName("isbn")
+	exist, err := app.models.Books.BookExist(r.Context(), isbn)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+	if !exist {
+		app.notFound(w)
+		return
+	}
+
+	list, err := app.models.Recommend.Similar(r.Context(), isbn)
+	if err == nil && list == nil {
+		list, err = app.models.Recommend.SimilarByMetadata(r.Context(), isbn, recommendPerList)
+	}
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	books, err := app.scoredBooks(r.Context(), list)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendJSONResponse(w, 200, books)
+}
+
+// books of a list in its order
+func (app *application) scoredBooks(ctx context.Context, list []recommend.Scored) ([]*models.Book, error) {
+	isbns := make([]string, len(list))
+	for i, s := range list {
+		isbns[i] = s.ISBN
+	}
+
+	return app.models.Books.BooksByISBN(ctx, isbns)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"test.iamgak.net/models"
+)
+
+func TestRecommendationRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/book/create", `{"isbn":"978-0-00-000002-8","title":"Children of Dune","author":"Frank Herbert","price":8.99,"descriptions":"The sequel","genre":"Science Fiction"}`, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/book/create", `{"isbn":"978-0-00-000003-5","title":"Emma","author":"Jane Austen","price":5.99,"descriptions":"A matchmaker","genre":"Romance"}`, admin), http.StatusOK)
+
+	review := func(login, isbn string) {
+		t.Helper()
+		ts.expect(ts.do(http.MethodPost, "/review/create", `{"isbn":"`+isbn+`","title":"Read it","rating":5,"price":9.99,"descriptions":"Liked it"}`, login), http.StatusOK)
+	}
+
+	// two readers of both books make them similar, the third read only Dune
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		_, login := ts.login(email, "user")
+		review(login, "978-0-00-000001-1")
+		review(login, "978-0-00-000002-8")
+	}
+	_, reader := ts.login("reader@example.com", "user")
+	review(reader, "978-0-00-000001-1")
+	_, newcomer := ts.login("newcomer@example.com", "user")
+
+	// before the first run the similar books are the ones of the same authors and genres
+	similar := decode[[]*models.Book](t, ts.do(http.MethodGet, "/book/978-0-00-000002-8/similar", "", ""))
+	if len(similar) != 1 || similar[0].ISBN != "978-0-00-000001-1" {
+		t.Errorf("similar before the job = %v, want Dune", similar)
+	}
+
+	if err := ts.app.refreshRecommendations(context.Background(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.expect(ts.do(http.MethodGet, "/recommendations", "", ""), http.StatusUnauthorized)
+	recs := decode[recommendations](t, ts.do(http.MethodGet, "/recommendations", "", reader))
+	if !recs.Personal || len(recs.Books) == 0 || recs.Books[0].ISBN != "978-0-00-000002-8" {
+		t.Errorf("recommendations = %+v, want Children of Dune first", recs)
+	}
+	for _, b := range recs.Books {
+		if b.ISBN == "978-0-00-000001-1" {
+			t.Errorf("recommendations = %+v, want no book the reader rated", recs)
+		}
+	}
+
+	recs = decode[recommendations](t, ts.do(http.MethodGet, "/recommendations", "", newcomer))
+	if recs.Personal || len(recs.Books) != 2 || recs.Books[0].ISBN != "978-0-00-000001-1" {
+		t.Errorf("recommendations without ratings = %+v, want the best rated books", recs)
+	}
+
+	similar = decode[[]*models.Book](t, ts.do(http.MethodGet, "/book/978-0-00-000001-1/similar", "", ""))
+	if len(similar) == 0 || similar[0].ISBN != "978-0-00-000002-8" {
+		t.Errorf("similar = %v, want Children of Dune", similar)
+	}
+	ts.expect(ts.do(http.MethodGet, "/book/978-0-00-000009-7/similar", "", ""), http.StatusNotFound)
+}
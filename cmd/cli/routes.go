@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
@@ -9,24 +10,90 @@ import (
 
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
-	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+	// httprouter can not mix /book/listing with /book/:isbn, so the routes addressed
+	// by isbn live on a second router that gets whatever the main one did not match
+	isbnRouter := httprouter.New()
+	isbnRouter.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.notFound(w)
 	})
+	router.NotFound = isbnRouter
+	for _, rt := range []*httprouter.Router{router, isbnRouter} {
+		rt.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			app.clientError(w, http.StatusMethodNotAllowed)
+		})
+		rt.PanicHandler = func(w http.ResponseWriter, r *http.Request, v any) {
+			app.serverError(w, fmt.Errorf("panic: %v", v))
+		}
+	}
 
 	auth := alice.New(app.LoginMiddleware)
+	admin := auth.Append(app.AdminMiddleware)
 
 	//home related routes
-	router.HandlerFunc(http.MethodGet, "/", app.Home)
+	router.HandlerFunc(http.MethodGet, "/", app.Home)               // trending, bestsellers and new and notable books, ?genre=
+	router.HandlerFunc(http.MethodGet, "/sitemap.xml", app.Sitemap) // every book page for search engines
 	//books related routes
-	router.HandlerFunc(http.MethodGet, "/book/listing", app.BookListing)        // all the book listing
-	router.HandlerFunc(http.MethodGet, "/book/search/:isbn/", app.BookInfo)     // review of given isbn
-	router.Handler(http.MethodPost, "/book/create", auth.ThenFunc(app.AddBook)) // create a new book info different isbn
+	router.HandlerFunc(http.MethodGet, "/book/listing", app.BookListing)                 // all the book listing
+	router.HandlerFunc(http.MethodGet, "/book/search/:isbn/", app.BookInfo)              // book of given isbn, or its citation with .bib/.ris/.csl.json
+	router.Handler(http.MethodPost, "/book/create", auth.ThenFunc(app.AddBook))          // create a new book info different isbn
+	router.Handler(http.MethodPost, "/book/update/:isbn", auth.ThenFunc(app.UpdateBook)) // change book info, multipart with a cover image
+	router.Handler(http.MethodGet, "/covers/*filepath", app.coverFiles())                // covers and thumbnails of the local storage
+	isbnRouter.HandlerFunc(http.MethodGet, "/book/:isbn", app.BookResource)              // html page, or the MARC record with .marcxml / .mrc
+	isbnRouter.HandlerFunc(http.MethodGet, "/book/:isbn/barcode.svg", app.BookBarcode)   // EAN-13 of the isbn, ?addon= price code
+	isbnRouter.HandlerFunc(http.MethodGet, "/book/:isbn/barcode.png", app.BookBarcode)
+	isbnRouter.HandlerFunc(http.MethodGet, "/book/:isbn/qr.svg", app.BookQR) // QR code of the book page
+	isbnRouter.HandlerFunc(http.MethodGet, "/book/:isbn/qr.png", app.BookQR)
+	isbnRouter.HandlerFunc(http.MethodGet, "/book/:isbn/similar", app.SimilarBooks) // readers of this book also liked, and books with its genres and authors
+	//author related routes
+	router.HandlerFunc(http.MethodGet, "/author/:id", app.AuthorInfo) // author with aliases and bibliography
+	//genre related routes
+	router.HandlerFunc(http.MethodGet, "/genres", app.GenreTree)      // the genre tree with book counts
+	router.HandlerFunc(http.MethodGet, "/genre/:slug", app.GenreInfo) // sub genres and books of the genre and its descendants, ?page=
+	//series related routes
+	router.HandlerFunc(http.MethodGet, "/series/:id", app.SeriesInfo) // works of the series in order with their editions
+	//publisher related routes
+	router.Handler(http.MethodGet, "/publishers", auth.ThenFunc(app.MyPublishers))                            // publishers of logged in user with their role
+	router.Handler(http.MethodGet, "/publisher/:id/dashboard", auth.ThenFunc(app.PublisherDashboard))         // titles with sales and review stats, ?since=
+	router.Handler(http.MethodGet, "/publisher/:id/members", auth.ThenFunc(app.PublisherMembers))             // members and their role
+	router.Handler(http.MethodPost, "/publisher/:id/member", auth.ThenFunc(app.SetPublisherMember))           // add a member or change the role, owners only
+	router.Handler(http.MethodPost, "/publisher/:id/member/delete", auth.ThenFunc(app.RemovePublisherMember)) // take a member out, owners only
+	//admin related routes
+	router.Handler(http.MethodPost, "/admin/book/import", admin.ThenFunc(app.BookImport))           // bulk csv / json lines / marc import
+	router.Handler(http.MethodPost, "/admin/author/update/:id", admin.ThenFunc(app.UpdateAuthor))   // name, bio and aliases
+	router.Handler(http.MethodPost, "/admin/author/merge/:id", admin.ThenFunc(app.MergeAuthors))    // fold duplicate authors into this one
+	router.Handler(http.MethodPost, "/admin/genre/create", admin.ThenFunc(app.CreateGenre))         // new genre, parent_id to place it in the tree
+	router.Handler(http.MethodPost, "/admin/genre/update/:id", admin.ThenFunc(app.UpdateGenre))     // rename or move a genre
+	router.Handler(http.MethodPost, "/admin/genre/delete/:id", admin.ThenFunc(app.DeleteGenre))     // remove a genre, its books move to the parent
+	router.Handler(http.MethodPost, "/admin/series/create", admin.ThenFunc(app.CreateSeries))       // new series, total when known
+	router.Handler(http.MethodPost, "/admin/series/update/:id", admin.ThenFunc(app.UpdateSeries))   // rename a series
+	router.Handler(http.MethodPost, "/admin/work/update/:id", admin.ThenFunc(app.UpdateWork))       // title and place of a work in a series
+	router.Handler(http.MethodPost, "/admin/publisher/create", admin.ThenFunc(app.CreatePublisher)) // new publisher with its owner
+	router.Handler(http.MethodPost, "/admin/sale/create", admin.ThenFunc(app.RecordSale))           // record the sale of a book
+	//opds catalog routes, OPDS 1.2 under /opds and OPDS 2.0 under /opds2
+	for _, prefix := range []string{"/opds", "/opds2"} {
+		router.HandlerFunc(http.MethodGet, prefix, app.OPDSRoot)                   // catalog start
+		router.HandlerFunc(http.MethodGet, prefix+"/genres", app.OPDSGenres)       // navigation by genre
+		router.HandlerFunc(http.MethodGet, prefix+"/authors", app.OPDSAuthors)     // navigation by author
+		router.HandlerFunc(http.MethodGet, prefix+"/books", app.OPDSBooks)         // every book, paged
+		router.HandlerFunc(http.MethodGet, prefix+"/genre/:name", app.OPDSGenre)   // books of a genre, paged
+		router.HandlerFunc(http.MethodGet, prefix+"/author/:name", app.OPDSAuthor) // books of an author, paged
+		router.HandlerFunc(http.MethodGet, prefix+"/search", app.OPDSSearch)       // ?q= title, author or isbn
+	}
+	router.HandlerFunc(http.MethodGet, "/opds/opensearch.xml", app.OPDSOpenSearch) // search description for both versions
+	//feed routes, Atom or ?format=rss
+	router.HandlerFunc(http.MethodGet, "/feed/books", app.FeedBooks)           // recently added books
+	router.HandlerFunc(http.MethodGet, "/feed/genre/:name", app.FeedGenre)     // recently added books of a genre
+	router.HandlerFunc(http.MethodGet, "/feed/author/:name", app.FeedAuthor)   // recently added books of an author
+	router.HandlerFunc(http.MethodGet, "/feed/reviews/:isbn", app.FeedReviews) // new reviews of given isbn
 	//review related routes
-	router.HandlerFunc(http.MethodGet, "/review/listing", app.ReviewListing)              // all the reviews
-	router.Handler(http.MethodGet, "/myreview/", auth.ThenFunc(app.MyReview))             // review of logged in user
-	router.HandlerFunc(http.MethodGet, "/review/search/:isbn/", app.ReviewSearch)         // review of given isbn
-	router.Handler(http.MethodPost, "/review/create", auth.ThenFunc(app.AddReview))       // create review
-	router.Handler(http.MethodGet, "/review/delete/:id", auth.ThenFunc(app.DeleteReview)) // delete your own review
+	router.HandlerFunc(http.MethodGet, "/review/listing", app.ReviewListing)                 // all the reviews
+	router.Handler(http.MethodGet, "/myreview/", auth.ThenFunc(app.MyReview))                // review of logged in user
+	router.Handler(http.MethodGet, "/myreview/export", auth.ThenFunc(app.MyReviewCitations)) // reviewed books as bibtex, ris or csl-json
+	router.Handler(http.MethodGet, "/recommendations", auth.ThenFunc(app.Recommendations))   // books for logged in user from their ratings
+	router.HandlerFunc(http.MethodGet, "/review/search/:isbn/", app.ReviewSearch)            // review of given isbn
+	router.Handler(http.MethodPost, "/review/create", auth.ThenFunc(app.AddReview))          // create review
+	router.Handler(http.MethodGet, "/review/delete/:id", auth.ThenFunc(app.DeleteReview))    // delete your own review
 	//user related routes
 	router.HandlerFunc(http.MethodPost, "/user/forget_password/", app.ForgetPasswordPost) // to create forget password request
 	router.HandlerFunc(http.MethodPost, "/user/register", app.UserRegister)               // to register
@@ -34,6 +101,6 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/user/activation/:uri", app.UserActivation)       // after registration uri created authentication
 	router.HandlerFunc(http.MethodPost, "/user/login", app.UserLogin)                     // login
 	router.Handler(http.MethodPost, "/user/logout", auth.ThenFunc(app.UserLogout))        // logout
-	standard := alice.New(app.logRequest, secureHeaders)
+	standard := alice.New(requestID, app.logRequest, secureHeaders)
 	return standard.Then(router)
 }
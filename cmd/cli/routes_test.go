@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"test.iamgak.net/covers"
+	"test.iamgak.net/models"
+	"test.iamgak.net/models/memory"
+	"test.iamgak.net/storage"
+)
+
+const testBaseURL = "https://books.example.com"
+
+// the routes of an application, with the memory store behind it when the
+// server is a memory one and the db when it is a SQLite one
+type testServer struct {
+	t       *testing.T
+	app     *application
+	store   *memory.Store
+	db      *models.DB
+	handler http.Handler
+}
+
+func testApp(t *testing.T, m *models.Init) *application {
+	t.Helper()
+	st, err := storage.NewLocal(t.TempDir(), "/covers/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		infoLog:  log.New(io.Discard, "", 0),
+		errorLog: log.New(io.Discard, "", 0),
+		models:   m,
+		covers:   covers.New(st),
+		baseURL:  testBaseURL,
+		currency: "USD",
+	}
+	app.models.Books.SetCoverURLs(app.covers.URLs)
+	return app
+}
+
+// the books, users and reviews of a memory store, the other models have no db
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	store := memory.New()
+	app := testApp(t, memory.Models(store))
+	return &testServer{t: t, app: app, store: store, handler: app.routes()}
+}
+
+// every model on a migrated SQLite db
+func newSQLServer(t *testing.T) *testServer {
+	t.Helper()
+	db, err := openDB("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := models.Constructor(db, nil, nil)
+	t.Cleanup(func() { m.Close() })
+	if err := migrateUp(context.Background(), db, "sqlite", log.New(io.Discard, "", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	app := testApp(t, m)
+	return &testServer{t: t, app: app, db: db, handler: app.routes()}
+}
+
+// the response to the request, with the login cookie when it is not empty
+func (ts *testServer) do(method, path, body, login string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if login != "" {
+		r.AddCookie(&http.Cookie{Name: "ldata", Value: login})
+	}
+
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
+
+// a multipart upload of content as the form field file
+func (ts *testServer) upload(path, filename, content, login string) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("file", filename)
+	if err == nil {
+		_, err = io.WriteString(part, content)
+	}
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, path, body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	if login != "" {
+		r.AddCookie(&http.Cookie{Name: "ldata", Value: login})
+	}
+
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
+
+func (ts *testServer) expect(w *httptest.ResponseRecorder, status int) {
+	ts.t.Helper()
+	if w.Code != status {
+		ts.t.Fatalf("status %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+}
+
+// an activated user with role, logged in through the login route, the uid
+// and the login cookie
+func (ts *testServer) login(email, role string) (int64, string) {
+	ts.t.Helper()
+	ctx := context.Background()
+	users := ts.app.models.Users
+	uid, err := users.InsertUser(ctx, email, "secret12", "activation-"+email)
+	if err == nil {
+		err = users.Activate(ctx, uid)
+	}
+	if err == nil {
+		err = users.SetRole(ctx, uid, role)
+	}
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+
+	w := ts.do(http.MethodPost, "/user/login", `{"email":"`+email+`","password":"secret12"}`, "")
+	ts.expect(w, http.StatusOK)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "ldata" {
+			return uid, c.Value
+		}
+	}
+
+	ts.t.Fatal("login without the ldata cookie")
+	return 0, ""
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(w.Body).Decode(&v); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+
+	return v
+}
+
+// the book of isbn as the book info route answers it
+func (ts *testServer) book(isbn string) *models.Book {
+	ts.t.Helper()
+	w := ts.do(http.MethodGet, "/book/search/"+isbn+"/", "", "")
+	ts.expect(w, http.StatusOK)
+	info := decode[[]*models.Book](ts.t, w)
+	if len(info) != 1 {
+		ts.t.Fatalf("book info of %s = %v, want one book", isbn, info)
+	}
+
+	return info[0]
+}
+
+const testBook = `{"isbn":"978-0-00-000001-1","title":"Dune","author":"Frank Herbert","price":9.99,"descriptions":"Desert planet","genre":"Science Fiction"}`
+
+// a book of two authors and a translator, named by its contributors only
+const creditedBook = `{"isbn":"978-0-00-000002-2","title":"Dune Messiah","price":8.99,"descriptions":"Twelve years later","genre":"Science Fiction","contributors":[{"name":"Frank Herbert","role":"author"},{"name":"Brian Herbert","role":"author"},{"name":"Ana Ruiz","role":"translator"}]}`
+
+func TestLoginRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	uid, login := ts.login("reader@example.com", "user")
+
+	ts.expect(ts.do(http.MethodPost, "/user/login", `{"email":"reader@example.com","password":"wrong-pass"}`, ""), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodPost, "/user/login", `{"email":"nobody@example.com","password":"secret12"}`, ""), http.StatusUnauthorized)
+
+	ts.expect(ts.do(http.MethodGet, "/myreview/", "", ""), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodGet, "/myreview/", "", strings.Repeat("a", 40)), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodGet, "/myreview/", "", login), http.StatusOK)
+
+	ts.expect(ts.do(http.MethodPost, "/user/logout", "", login), http.StatusOK)
+	ts.expect(ts.do(http.MethodGet, "/myreview/", "", login), http.StatusUnauthorized)
+
+	activity := ts.store.Activity(uid)
+	if len(activity) != 2 || activity[0] != "logged_in" || activity[1] != "log_out" {
+		t.Errorf("activity = %v, want logged_in and log_out", activity)
+	}
+}
+
+func TestBookRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, ""), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodPost, "/book/create", `{"isbn":"978-0-00-000002-8"}`, admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusConflict)
+
+	books := decode[[]*models.Book](t, ts.do(http.MethodGet, "/book/listing", "", ""))
+	if len(books) != 1 || books[0].Title != "Dune" {
+		t.Fatalf("listing = %v, want Dune", books)
+	}
+
+	w := ts.do(http.MethodGet, "/book/search/978-0-00-000001-1/", "", "")
+	ts.expect(w, http.StatusOK)
+	if info := decode[[]*models.Book](t, w); len(info) != 1 || info[0].Author != "Frank Herbert" {
+		t.Errorf("book info = %v, want Dune by Frank Herbert", info)
+	}
+	ts.expect(ts.do(http.MethodGet, "/book/search/978-0-00-000009-7/", "", ""), http.StatusNotFound)
+}
+
+func TestUserRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	ctx := context.Background()
+
+	ts.expect(ts.do(http.MethodPost, "/user/register", `{"email":"reader@example.com","password":"secret12","repeatPassword":"secret13"}`, ""), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/user/register", `{"email":"not-an-email","password":"secret12","repeatPassword":"secret12"}`, ""), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/user/register", `{"email":"reader@example.com","password":"secret12","repeatPassword":"secret12"}`, ""), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/user/register", `{"email":"reader@example.com","password":"secret12","repeatPassword":"secret12"}`, ""), http.StatusConflict)
+
+	// the account logs in once the link of the activation mail is opened
+	login := `{"email":"reader@example.com","password":"secret12"}`
+	ts.expect(ts.do(http.MethodPost, "/user/login", login, ""), http.StatusUnauthorized)
+
+	var token string
+	if err := ts.db.QueryRowContext(ctx, "SELECT `activation_token` FROM `users` WHERE `email` = ?", "reader@example.com").Scan(&token); err != nil {
+		t.Fatal(err)
+	}
+	ts.expect(ts.do(http.MethodGet, "/user/activation/unknown", "", ""), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodGet, "/user/activation/"+token, "", ""), http.StatusOK)
+	ts.expect(ts.do(http.MethodGet, "/user/activation/"+token, "", ""), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodPost, "/user/login", login, ""), http.StatusOK)
+
+	// an unknown email gets the same answer and no link
+	ts.expect(ts.do(http.MethodPost, "/user/forget_password/", `{"email":""}`, ""), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/user/forget_password/", `{"email":"nobody@example.com"}`, ""), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/user/forget_password/", `{"email":"reader@example.com"}`, ""), http.StatusOK)
+
+	var uri string
+	if err := ts.db.QueryRowContext(ctx, "SELECT `uri` FROM `forget_passw` WHERE `superseded` = 0").Scan(&uri); err != nil {
+		t.Fatal(err)
+	}
+	ts.expect(ts.do(http.MethodPost, "/user/new_password/unknown", `{"password":"secret34","repeatPassword":"secret34"}`, ""), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodPost, "/user/new_password/"+uri, `{"password":"secret34","repeatPassword":"secret35"}`, ""), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/user/new_password/"+uri, `{"password":"secret34","repeatPassword":"secret34"}`, ""), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/user/new_password/"+uri, `{"password":"secret56","repeatPassword":"secret56"}`, ""), http.StatusNotFound)
+
+	ts.expect(ts.do(http.MethodPost, "/user/login", login, ""), http.StatusUnauthorized)
+	ts.expect(ts.do(http.MethodPost, "/user/login", `{"email":"reader@example.com","password":"secret34"}`, ""), http.StatusOK)
+}
+
+func TestReviewRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	uid, reader := ts.login("reader@example.com", "user")
+	_, other := ts.login("other@example.com", "user")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	review := `{"isbn":"978-0-00-000001-1","title":"Dune","rating":5,"price":9.99,"descriptions":"A classic"}`
+	ts.expect(ts.do(http.MethodPost, "/review/create", `{"isbn":"978-0-00-000009-7","title":"Dune","rating":5,"price":9.99,"descriptions":"A classic"}`, reader), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodPost, "/review/create", review, reader), http.StatusOK)
+
+	mine := decode[[]*models.Review](t, ts.do(http.MethodGet, "/myreview/", "", reader))
+	if len(mine) != 1 || mine[0].Uid != uid {
+		t.Fatalf("myreview = %v, want the review of %d", mine, uid)
+	}
+	if theirs := decode[[]*models.Review](t, ts.do(http.MethodGet, "/myreview/", "", other)); len(theirs) != 0 {
+		t.Errorf("myreview of another user = %v, want none", theirs)
+	}
+
+	listing := decode[[]*models.Review](t, ts.do(http.MethodGet, "/review/search/978-0-00-000001-1/", "", ""))
+	if len(listing) != 1 {
+		t.Fatalf("reviews of the book = %v, want one", listing)
+	}
+	if all := decode[[]*models.Review](t, ts.do(http.MethodGet, "/review/search/978-0-00-000001-1/?editions=all", "", "")); len(all) != 1 {
+		t.Errorf("reviews of every edition = %v, want one", all)
+	}
+	if all := decode[[]*models.Review](t, ts.do(http.MethodGet, "/review/listing", "", "")); len(all) != 1 {
+		t.Errorf("review listing = %v, want one", all)
+	}
+
+	// the api has no review ids
+	recent, err := ts.store.RecentReviews(context.Background(), "978-0-00-000001-1", 1)
+	if err != nil || len(recent) != 1 {
+		t.Fatalf("RecentReviews = %v, %v", recent, err)
+	}
+	remove := "/review/delete/" + strconv.FormatInt(recent[0].ID, 10)
+
+	ts.expect(ts.do(http.MethodGet, "/review/delete/abc", "", reader), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodGet, remove, "", other), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodGet, remove, "", reader), http.StatusOK)
+	ts.expect(ts.do(http.MethodGet, remove, "", reader), http.StatusNotFound)
+
+	if mine := decode[[]*models.Review](t, ts.do(http.MethodGet, "/myreview/", "", reader)); len(mine) != 0 {
+		t.Errorf("myreview after the delete = %v, want none", mine)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	ts := newTestServer(t)
+	_, reader := ts.login("reader@example.com", "user")
+	_, admin := ts.login("admin@example.com", "admin")
+
+	ts.expect(ts.upload("/admin/book/import", "books.jsonl", testBook, ""), http.StatusUnauthorized)
+	ts.expect(ts.upload("/admin/book/import", "books.jsonl", testBook, reader), http.StatusForbidden)
+	ts.expect(ts.upload("/admin/book/import", "books.jsonl", testBook, admin), http.StatusOK)
+
+	if exist, _ := ts.store.BookExist(context.Background(), "978-0-00-000001-1"); !exist {
+		t.Error("the imported book is not stored")
+	}
+
+	// a .json file is an array of books, json lines are .jsonl
+	w := ts.upload("/admin/book/import", "books.json", `[`+creditedBook+`, 7]`, admin)
+	ts.expect(w, http.StatusOK)
+	if report := decode[models.ImportReport](t, w); report.Total != 2 || report.Inserted != 1 || len(report.Errors) != 1 || report.Errors[0].Row != 2 {
+		t.Errorf("import of a json array = %+v, want the book in and the number refused", report)
+	}
+	w = ts.upload("/admin/book/import", "books.json", testBook+"\n"+creditedBook, admin)
+	ts.expect(w, http.StatusUnprocessableEntity)
+	if resp := decode[errorResponse](t, w); !strings.Contains(resp.Errors["file"], "use jsonl") {
+		t.Errorf("import of json lines named .json = %+v", resp)
+	}
+
+	// a file the import can not read is the fault of the client
+	w = ts.upload("/admin/book/import", "books.csv", "title,author,price,descriptions,genre\nDune,Frank Herbert,9.99,Desert planet,Science Fiction\n", admin)
+	ts.expect(w, http.StatusUnprocessableEntity)
+	if resp := decode[errorResponse](t, w); resp.Errors["file"] != `csv header is missing the "isbn" column` {
+		t.Errorf("import of a csv without isbn = %+v", resp)
+	}
+	ts.expect(ts.upload("/admin/book/import", "books.csv", "", admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.upload("/admin/book/import", "books.mrc", "00010nam\x1d", admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.upload("/admin/book/import", "books.marcxml", "<collection><record>", admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.upload("/admin/book/import", "books.jsonl", strings.Repeat("x", 2<<20), admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.upload("/admin/book/import", "books.jsonl", strings.Repeat("\n", 33<<20), admin), http.StatusRequestEntityTooLarge)
+}
+
+// every request sees the user of its own cookie, however many run at once
+func TestConcurrentLogins(t *testing.T) {
+	ts := newTestServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	logins := map[int64]string{}
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		uid, login := ts.login(email, "user")
+		logins[uid] = login
+		ts.expect(ts.do(http.MethodPost, "/review/create", `{"isbn":"978-0-00-000001-1","title":"Dune","rating":4,"price":9.99,"descriptions":"by `+email+`"}`, login), http.StatusOK)
+	}
+
+	var wg sync.WaitGroup
+	for uid, login := range logins {
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(uid int64, login string) {
+				defer wg.Done()
+				w := ts.do(http.MethodGet, "/myreview/", "", login)
+				var reviews []*models.Review
+				if err := json.NewDecoder(w.Body).Decode(&reviews); err != nil || len(reviews) != 1 || reviews[0].Uid != uid {
+					t.Errorf("myreview of %d = %v %v, want only their review", uid, reviews, err)
+				}
+			}(uid, login)
+		}
+	}
+	wg.Wait()
+}
+
+func TestAbsoluteLinks(t *testing.T) {
+	ts := newTestServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	// the links do not follow the Host header of the client
+	for _, path := range []string{"/sitemap.xml", "/opds/opensearch.xml"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Host = "attacker.example"
+		w := httptest.NewRecorder()
+		ts.handler.ServeHTTP(w, r)
+		ts.expect(w, http.StatusOK)
+
+		body := w.Body.String()
+		if !strings.Contains(body, testBaseURL+"/") || strings.Contains(body, "attacker.example") {
+			t.Errorf("%s = %s, want links of %s", path, body, testBaseURL)
+		}
+	}
+}
+
+func TestFeedSelf(t *testing.T) {
+	ts := newTestServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	// the cached feed is shared, its self link is the one of each request
+	for _, uri := range []string{"/feed/books?format=rss", "/feed/books", "/feed/books?utm_source=mail"} {
+		r := httptest.NewRequest(http.MethodGet, uri, nil)
+		r.Host = "attacker.example"
+		w := httptest.NewRecorder()
+		ts.handler.ServeHTTP(w, r)
+		ts.expect(w, http.StatusOK)
+
+		body := w.Body.String()
+		if !strings.Contains(body, `"`+testBaseURL+strings.ReplaceAll(uri, "&", "&amp;")+`"`) || strings.Contains(body, "attacker.example") {
+			t.Errorf("%s = %s, want it as self link", uri, body)
+		}
+		if !strings.Contains(body, "Dune") {
+			t.Errorf("%s = %s, want Dune", uri, body)
+		}
+	}
+}
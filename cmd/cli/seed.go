@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	"test.iamgak.net/config"
+	"test.iamgak.net/models"
+)
+
+var (
+	seedFirstNames = []string{"Ada", "Alan", "Clara", "Diego", "Elena", "Farid", "Grace", "Hiro", "Ines", "Jonas", "Kofi", "Lena", "Marta", "Nadia", "Omar", "Priya", "Rafael", "Sofia", "Tomas", "Yara"}
+	seedLastNames  = []string{"Almeida", "Brooks", "Castillo", "Dubois", "Eriksen", "Fischer", "Gupta", "Hayes", "Ivanova", "Jensen", "Kowalski", "Lindqvist", "Moreau", "Nakamura", "Okafor", "Petrov", "Quinn", "Rossi", "Silva", "Tanaka"}
+	seedAdjectives = []string{"Silent", "Broken", "Hidden", "Last", "Golden", "Distant", "Forgotten", "Burning", "Quiet", "Endless", "Winter", "Crimson", "Little", "Northern", "Glass"}
+	seedNouns      = []string{"River", "Garden", "Empire", "Letters", "Harbor", "Machine", "Orchard", "Kingdom", "Lighthouse", "Library", "Mountain", "Archive", "Voyage", "City", "Tide"}
+	seedGenres     = []string{"Fiction", "Fantasy", "Science Fiction", "Mystery", "Romance", "History", "Biography", "Poetry"}
+	seedLanguages  = []string{"en", "en", "en", "en", "es", "fr", "de", "pt-BR"}
+	seedOpinions   = []string{"Could not put it down.", "A slow start, but worth it.", "Beautifully written.", "The ending surprised me.", "Good, not great.", "Would read it again.", "The characters stay with you.", "A solid read for a long trip."}
+)
+
+// the genre tree of the demo catalog, parents before their children
+var seedTaxonomy = []struct{ name, parent string }{
+	{"Fiction", ""},
+	{"Science Fiction", "Fiction"},
+	{"Cyberpunk", "Science Fiction"},
+	{"Political Satire", "Fiction"},
+	{"Fantasy", "Fiction"},
+	{"Mystery", "Fiction"},
+	{"Romance", "Fiction"},
+	{"Poetry", "Fiction"},
+	{"Non-Fiction", ""},
+	{"Reality", "Non-Fiction"},
+	{"History", "Non-Fiction"},
+	{"Biography", "Non-Fiction"},
+}
+
+// the books of the examples of the README, a publisher is owned by
+// seed2@example.com, and their author with its bio and aliases
+var seedDemoBooks = []struct {
+	book      models.Book
+	publisher string
+	bio       string
+	aliases   []string
+}{
+	{book: models.Book{ISBN: "978-3-16-148410-0", Title: "Sapiens", Author: "Yoah N Harari", Price: 19.99, Descriptions: "Human Kind Development", Genre: "Reality", Format: "paperback", Language: "en"}},
+	{
+		book:      models.Book{ISBN: "978-1-23-456789-7", Title: "Animal Farm", Author: "George Orwell", Price: 29.99, Descriptions: "Politics & leadership", Genre: "Fiction", Genres: []*models.BookGenre{{Name: "Fiction"}, {Name: "Political Satire"}}, Format: "hardcover", Language: "en"},
+		publisher: "Secker & Warburg",
+		bio:       "English novelist and essayist, author of Animal Farm and Nineteen Eighty-Four.",
+		aliases:   []string{"G. Orwell", "Eric Arthur Blair"},
+	},
+}
+
+// what seed wrote, the password is the one of every seeded user
+type seedReport struct {
+	Demo     bool   `json:"demo"`
+	Books    int    `json:"books"`
+	Users    int    `json:"users"`
+	Reviews  int    `json:"reviews"`
+	Password string `json:"password"`
+}
+
+// an isbn-13 of the 978 prefix with a valid check digit, hyphenated
+func seedISBN(r *rand.Rand) string {
+	digits := fmt.Sprintf("978%09d", r.Intn(1_000_000_000))
+	sum := 0
+	for i, d := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(d-'0') * weight
+	}
+	check := (10 - sum%10) % 10
+
+	return fmt.Sprintf("%s-%s-%s-%s-%d", digits[:3], digits[3:4], digits[4:8], digits[8:], check)
+}
+
+func seedPick(r *rand.Rand, list []string) string {
+	return list[r.Intn(len(list))]
+}
+
+// go run ./cmd/cli seed -books 50 -users 10 -reviews 200, fake data for development
+func (app *application) runSeed(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	nBooks := fs.Int("books", 50, "books to create")
+	nUsers := fs.Int("users", 10, "activated users to create, seed1@example.com and so on")
+	nReviews := fs.Int("reviews", 200, "reviews to create, by random users of random books")
+	seed := fs.Int64("seed", 1, "seed of the random data, the same seed writes the same data")
+	password := fs.String("password", "password1", "password of every created user")
+	demo := fs.Bool("demo", true, "add the demo catalog of the README examples: a genre tree, Sapiens and Animal Farm under the publisher Secker & Warburg owned by seed2@example.com")
+	dsnFlag := fs.String("dsn", cfg.DSN(), "data source name of the database.driver")
+	fs.Parse(args)
+
+	if err := app.openModels(*dsnFlag, cfg); err != nil {
+		return err
+	}
+	defer app.models.Close()
+
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(*seed))
+	report := &seedReport{Password: *password}
+
+	uids, err := app.seedUsers(ctx, *nUsers, *password)
+	if err != nil {
+		return err
+	}
+	report.Users = len(uids)
+
+	if *demo {
+		if err := app.seedDemo(ctx, uids); err != nil {
+			return err
+		}
+		report.Demo = true
+	}
+
+	books, err := app.seedBooks(ctx, r, *nBooks)
+	if err != nil {
+		return err
+	}
+	report.Books = len(books)
+
+	if len(books) == 0 {
+		if books, err = app.models.Books.BooksListing(ctx); err != nil {
+			return err
+		}
+	}
+
+	if len(books) > 0 && len(uids) > 0 {
+		for i := 0; i < *nReviews; i++ {
+			book := books[r.Intn(len(books))]
+			rating := []float32{3, 4, 4, 5, 5, 5}[r.Intn(6)]
+			review := &models.Review{
+				Isbn:         book.ISBN,
+				Title:        book.Title,
+				Price:        book.Price,
+				Rating:       rating,
+				Descriptions: seedPick(r, seedOpinions),
+				Uid:          uids[r.Intn(len(uids))],
+			}
+			if err := app.models.Review.CreateReview(ctx, review); err != nil {
+				return err
+			}
+			report.Reviews++
+		}
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	return out.Encode(report)
+}
+
+func (app *application) seedBooks(ctx context.Context, r *rand.Rand, n int) ([]*models.Book, error) {
+	genres := seedGenres
+	if taxonomy, err := app.models.Genres.Taxonomy(ctx); err == nil && len(taxonomy.ByID) > 0 {
+		genres = []string{}
+		for _, g := range taxonomy.ByID {
+			genres = append(genres, g.Name)
+		}
+	}
+
+	books := []*models.Book{}
+	seen := map[string]bool{}
+	for len(books) < n {
+		isbn := seedISBN(r)
+		if seen[isbn] {
+			continue
+		}
+		seen[isbn] = true
+
+		exist, err := app.models.Books.BookExist(ctx, isbn)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			continue
+		}
+
+		title := "The " + seedPick(r, seedAdjectives) + " " + seedPick(r, seedNouns)
+		genre := seedPick(r, genres)
+		books = append(books, &models.Book{
+			ISBN:         isbn,
+			Title:        title,
+			Author:       seedPick(r, seedFirstNames) + " " + seedPick(r, seedLastNames),
+			Price:        float32(500+r.Intn(3500)) / 100,
+			Descriptions: "A " + strings.ToLower(genre) + " novel about " + strings.ToLower(title) + ".",
+			Genre:        genre,
+			Format:       seedPick(r, models.Formats),
+			Language:     seedPick(r, seedLanguages),
+		})
+	}
+
+	if _, _, err := app.models.Books.ImportBooks(ctx, books, false, cliEditor); err != nil {
+		return nil, err
+	}
+
+	return books, nil
+}
+
+// the demo catalog, the parts already there are left as they are
+func (app *application) seedDemo(ctx context.Context, uids []int64) error {
+	taxonomy, err := app.models.Genres.Taxonomy(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(taxonomy.ByID) == 0 {
+		ids := map[string]int64{}
+		for _, g := range seedTaxonomy {
+			genre := &models.Genre{Name: g.name, ParentID: ids[g.parent]}
+			if err := app.models.Genres.CreateGenre(ctx, genre); err != nil {
+				return err
+			}
+			ids[g.name] = genre.ID
+		}
+	}
+
+	for _, demo := range seedDemoBooks {
+		book := demo.book
+		exist, err := app.models.Books.BookExist(ctx, book.ISBN)
+		if err != nil {
+			return err
+		}
+		if exist {
+			continue
+		}
+
+		if demo.publisher != "" && len(uids) > 0 {
+			publisher := &models.Publisher{Name: demo.publisher}
+			if err := app.models.Publishers.CreatePublisher(ctx, publisher, uids[min(1, len(uids)-1)]); err != nil {
+				return err
+			}
+			book.PublisherID = publisher.ID
+		}
+
+		if err := app.models.Books.CreateBook(ctx, &book, cliEditor); err != nil {
+			return err
+		}
+
+		// a few sales for the dashboard of the publisher
+		if book.PublisherID != 0 {
+			for _, quantity := range []int{2, 1} {
+				sale := &models.Sale{ISBN: book.ISBN, Quantity: quantity, Amount: float64(quantity) * float64(book.Price)}
+				if err := app.models.Books.RecordSale(ctx, sale); err != nil {
+					return err
+				}
+			}
+		}
+
+		if demo.bio == "" {
+			continue
+		}
+
+		contributors, err := app.models.Books.Contributors(ctx, book.ISBN)
+		if err != nil {
+			return err
+		}
+		for _, c := range contributors {
+			author := &models.Author{ID: c.AuthorID, Name: c.Name, Bio: demo.bio, Aliases: demo.aliases}
+			if err := app.models.Authors.UpdateAuthor(ctx, author); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// users seed1@example.com and on, the ones already there are used as they are
+func (app *application) seedUsers(ctx context.Context, n int, password string) ([]int64, error) {
+	uids := []int64{}
+	for i := 1; i <= n; i++ {
+		email := fmt.Sprintf("seed%d@example.com", i)
+		uid, err := app.models.Users.EmailExist(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		if uid != 0 {
+			uids = append(uids, uid)
+			continue
+		}
+
+		uid, err = app.models.Users.InsertUser(ctx, email, password, app.generateHash(email, password))
+		if err != nil {
+			return nil, err
+		}
+		if err := app.models.Users.Activate(ctx, uid); err != nil {
+			return nil, err
+		}
+
+		uids = append(uids, uid)
+	}
+
+	return uids, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"test.iamgak.net/models"
+	"test.iamgak.net/validator"
+)
+
+// series with its works in order, each work with all its editions
+func (app *application) SeriesInfo(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	series, err := app.models.Works.GetSeries(r.Context(), id)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	series.Works, err = app.models.Books.SeriesWorks(r.Context(), id)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.sendJSONResponse(w, 200, series)
+}
+
+func (app *application) decodeSeries(w http.ResponseWriter, r *http.Request) (*models.Series, bool) {
+	var series *models.Series
+	err := json.NewDecoder(r.Body).Decode(&series)
+	if err != nil || series == nil {
+		app.CustomError(w, "Unsupported or empty fields", 400)
+		return nil, false
+	}
+
+	series.Name = strings.Join(strings.Fields(series.Name), " ")
+	validator := &validator.Validator{
+		Errors: make(map[string]string),
+	}
+
+	validator.CheckField(validator.NotBlank(series.Name), "name", "Please, fill the name field")
+	validator.CheckField(validator.MaxChars(series.Name, 255), "name", "Please, fill the NAME shorter than 255")
+	validator.CheckField(series.Total >= 0, "total", "Please, fill the total with a positive number or leave it out")
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return nil, false
+	}
+
+	return series, true
+}
+
+// new series, admin only
+func (app *application) CreateSeries(w http.ResponseWriter, r *http.Request) {
+	series, ok := app.decodeSeries(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.models.Works.CreateSeries(r.Context(), series); err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Series Created", userID(r.Context()))
+	app.sendJSONResponse(w, 200, series)
+}
+
+// rename a series or change its total, admin only
+func (app *application) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	series, ok := app.decodeSeries(w, r)
+	if !ok {
+		return
+	}
+
+	series.ID = id
+	err := app.models.Works.UpdateSeries(r.Context(), series)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Series Updated", userID(r.Context()))
+	resp := app.sendMessage(true, "Series Record Updated, Sucessfully")
+	app.sendJSONResponse(w, 200, resp)
+}
+
+// title of a work and its place in a series (series_id 0 takes it out), admin only
+func (app *application) UpdateWork(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	var work *models.Work
+	err := json.NewDecoder(r.Body).Decode(&work)
+	if err != nil || work == nil {
+		app.CustomError(w, "Unsupported or empty fields", 400)
+		return
+	}
+
+	work.ID = id
+	work.Title = strings.TrimSpace(work.Title)
+	validator := &validator.Validator{
+		Errors: make(map[string]string),
+	}
+
+	validator.CheckField(validator.NotBlank(work.Title), "title", "Please, fill the title field")
+	validator.CheckField(validator.MaxChars(work.Title, 255), "title", "Please, fill the TITLE shorter than 255")
+	if work.SeriesID != 0 {
+		exist, err := app.models.Works.SeriesExist(r.Context(), work.SeriesID)
+		if err != nil {
+			app.modelError(w, err)
+			return
+		}
+		validator.CheckField(exist, "series_id", "Please, use the id of an existing series")
+		validator.CheckField(work.SeriesPosition > 0, "series_position", "Please, fill the position of the work in the series, starting at 1")
+	}
+
+	if !validator.Valid() {
+		app.failedValidation(w, validator)
+		return
+	}
+
+	err = app.models.Works.UpdateWork(r.Context(), work)
+	if err != nil {
+		app.modelError(w, err)
+		return
+	}
+
+	app.activityLog(r.Context(), "Work Updated", userID(r.Context()))
+	resp := app.sendMessage(true, "Work Record Updated, Sucessfully")
+	app.sendJSONResponse(w, 200, resp)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+func TestSeriesRoutes(t *testing.T) {
+	ts := newSQLServer(t)
+	_, admin := ts.login("admin@example.com", "admin")
+	_, reader := ts.login("reader@example.com", "user")
+	ts.expect(ts.do(http.MethodPost, "/book/create", testBook, admin), http.StatusOK)
+
+	ts.expect(ts.do(http.MethodPost, "/admin/series/create", `{"name":"Dune Chronicles"}`, reader), http.StatusForbidden)
+	ts.expect(ts.do(http.MethodPost, "/admin/series/create", `{"name":"Dune Chronicles","total":-1}`, admin), http.StatusUnprocessableEntity)
+	w := ts.do(http.MethodPost, "/admin/series/create", `{"name":"Dune  Chronicles","total":6}`, admin)
+	ts.expect(w, http.StatusOK)
+	series := decode[*models.Series](t, w)
+	if series.ID == 0 || series.Name != "Dune Chronicles" {
+		t.Fatalf("created series = %+v", series)
+	}
+	info := "/series/" + strconv.FormatInt(series.ID, 10)
+
+	work := "/admin/work/update/" + strconv.FormatInt(ts.book("978-0-00-000001-1").WorkID, 10)
+	ts.expect(ts.do(http.MethodPost, work, `{"title":"Dune","series_id":999,"series_position":1}`, admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, work, `{"title":"Dune","series_id":`+strconv.FormatInt(series.ID, 10)+`}`, admin), http.StatusUnprocessableEntity)
+	ts.expect(ts.do(http.MethodPost, "/admin/work/update/999", `{"title":"Dune"}`, admin), http.StatusNotFound)
+	ts.expect(ts.do(http.MethodPost, work, `{"title":"Dune","series_id":`+strconv.FormatInt(series.ID, 10)+`,"series_position":1}`, admin), http.StatusOK)
+
+	got := decode[*models.Series](t, ts.do(http.MethodGet, info, "", ""))
+	if got.Total != 6 || len(got.Works) != 1 || len(got.Works[0].Editions) != 1 || got.Works[0].Editions[0].ISBN != "978-0-00-000001-1" {
+		t.Errorf("series = %+v, want Dune as its first work", got)
+	}
+	if place := ts.book("978-0-00-000001-1").Series; place == nil || place.Position != 1 || place.Total != 6 {
+		t.Errorf("series place of the book = %+v, want 1 of 6", place)
+	}
+
+	ts.expect(ts.do(http.MethodPost, "/admin/series/update/"+strconv.FormatInt(series.ID, 10), `{"name":"Dune Saga"}`, admin), http.StatusOK)
+	ts.expect(ts.do(http.MethodPost, "/admin/series/update/999", `{"name":"Dune Saga"}`, admin), http.StatusNotFound)
+	if got := decode[*models.Series](t, ts.do(http.MethodGet, info, "", "")); got.Name != "Dune Saga" || got.Total != 0 {
+		t.Errorf("renamed series = %+v, want Dune Saga without a total", got)
+	}
+	ts.expect(ts.do(http.MethodGet, "/series/999", "", ""), http.StatusNotFound)
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// a file of the test with content
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestReadLayers(t *testing.T) {
+	for _, file := range []string{
+		writeFile(t, "config.yaml", "http:\n  addr: \":9000\"\nredis:\n  db: 2\ncache:\n  size: 50\n"),
+		writeFile(t, "config.toml", "[http]\naddr = \":9000\"\n[redis]\ndb = 2\n[cache]\nsize = 50\n"),
+	} {
+		t.Run(filepath.Ext(file), func(t *testing.T) {
+			// the file overrides the defaults, the environment the file and the flags the environment
+			t.Setenv("CONFIG_FILE", file)
+			t.Setenv("HTTP_ADDR", ":9100")
+			t.Setenv("CACHE_SIZE", "60")
+			c, err := Read("test", []string{"-cache.size", "70", "-dsn", "user@tcp(db:3306)/books"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.MySQL.Port != 3306 || c.HTTP.ReadTimeout != 5*time.Second {
+				t.Errorf("defaults = %d, %s, want 3306 and 5s", c.MySQL.Port, c.HTTP.ReadTimeout)
+			}
+			if c.Redis.DB != 2 {
+				t.Errorf("redis.db = %d, want 2 of the file", c.Redis.DB)
+			}
+			if c.HTTP.Addr != ":9100" {
+				t.Errorf("http.addr = %q, want :9100 of the environment", c.HTTP.Addr)
+			}
+			if c.Cache.Size != 70 {
+				t.Errorf("cache.size = %d, want 70 of the flag", c.Cache.Size)
+			}
+			if c.MySQL.DSN != "user@tcp(db:3306)/books" {
+				t.Errorf("mysql.dsn = %q, want the one of -dsn", c.MySQL.DSN)
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  [2]string
+		args []string
+		want string
+	}{
+		{name: "unknown yaml key", file: writeFile(t, "c.yaml", "http:\n  adress: \":9000\"\n"), want: "adress"},
+		{name: "unknown toml key", file: writeFile(t, "c.toml", "[http]\nadress = \":9000\"\n"), want: "http.adress"},
+		{name: "unknown format", file: writeFile(t, "c.json", "{}"), want: "unknown format"},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "none.yaml"), want: "none.yaml"},
+		{name: "env not a number", env: [2]string{"CACHE_SIZE", "many"}, want: "CACHE_SIZE"},
+		{name: "flag not a duration", args: []string{"-http.read_timeout", "5"}, want: "-http.read_timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CONFIG_FILE", tt.file)
+			if tt.env[0] != "" {
+				t.Setenv(tt.env[0], tt.env[1])
+			}
+
+			_, err := Read("test", tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Read = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+}
+
+// a configuration Validate accepts
+func validConfig() *Config {
+	c := Default()
+	c.TLS.Enabled = false
+	c.MySQL.User = "books"
+	c.Session.Key = strings.Repeat("k", 32)
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("Validate of a valid config = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		edit func(c *Config)
+		want []string
+	}{
+		{"addr without port", func(c *Config) { c.HTTP.Addr = "localhost" }, []string{"http.addr (HTTP_ADDR)"}},
+		{"public url without scheme", func(c *Config) { c.HTTP.PublicURL = "books.example.com" }, []string{"http.public_url (PUBLIC_URL)"}},
+		{"missing certificate", func(c *Config) { c.TLS.Enabled = true; c.TLS.CertFile = "/none.pem" }, []string{"tls.cert_file"}},
+		{"unknown driver", func(c *Config) { c.Database.Driver = "oracle" }, []string{"database.driver"}},
+		{"mysql without user", func(c *Config) { c.MySQL.User = "" }, []string{"mysql.user (DB_USER)"}},
+		{"bad mysql dsn", func(c *Config) { c.MySQL.DSN = "not a dsn" }, []string{"mysql.dsn"}},
+		{"sqlite without path", func(c *Config) { c.Database.Driver = "sqlite"; c.SQLite.Path = "" }, []string{"sqlite.path"}},
+		{"postgres without dsn", func(c *Config) { c.Database.Driver = "postgres" }, []string{"postgres.dsn"}},
+		{"short session key", func(c *Config) { c.Session.Key = "short" }, []string{"session.key"}},
+		{"mail without sender", func(c *Config) { c.Mail.Host = "smtp.example.com" }, []string{"mail.from"}},
+		{"s3 without bucket", func(c *Config) { c.Covers.Storage = "s3"; c.Covers.S3Endpoint = "https://s3.example.com" }, []string{"covers.s3_bucket"}},
+		{"lower case currency", func(c *Config) { c.Store.Currency = "usd" }, []string{"store.currency (STORE_CURRENCY)"}},
+		// every problem is reported at once
+		{"several", func(c *Config) { c.Cache.Size = 0; c.Jobs.ListsInterval = 0 }, []string{"cache.size", "jobs.lists_interval"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.edit(c)
+			err := c.Validate()
+			if err == nil {
+				t.Fatal("Validate = nil, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate = %v, want %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMySQLFormatDSN(t *testing.T) {
+	for _, m := range []MySQL{
+		{Host: "db", Port: 3306, User: "books", Database: "bookstore", Timeout: time.Second},
+		{DSN: "books@tcp(db:3306)/bookstore"},
+	} {
+		cfg, err := mysql.ParseDSN(m.FormatDSN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cfg.ParseTime || !cfg.ClientFoundRows || cfg.Addr != "db:3306" || cfg.DBName != "bookstore" {
+			t.Errorf("FormatDSN of %+v = %s, want parseTime and clientFoundRows", m, m.FormatDSN())
+		}
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	c := validConfig()
+	if got := c.BaseURL(); got != "http://localhost:8000" {
+		t.Errorf("BaseURL without tls = %q", got)
+	}
+
+	c.TLS.Enabled = true
+	if got := c.BaseURL(); got != "https://localhost:8000" {
+		t.Errorf("BaseURL with tls = %q", got)
+	}
+
+	c.HTTP.PublicURL = "https://books.example.com/"
+	if got := c.BaseURL(); got != "https://books.example.com" {
+		t.Errorf("BaseURL of the public url = %q", got)
+	}
+}
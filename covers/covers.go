@@ -0,0 +1,217 @@
+// Package covers checks uploaded cover images, makes the thumbnails and
+// keeps everything in a storage.Storage.
+package covers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"test.iamgak.net/storage"
+)
+
+var (
+	ErrTooLarge    = errors.New("covers: image is too large")
+	ErrUnsupported = errors.New("covers: image should be jpeg, png or gif")
+)
+
+// thumbnail name -> width in pixels, the height keeps the aspect ratio
+var Sizes = map[string]int{
+	"small":  120,
+	"medium": 300,
+	"large":  600,
+}
+
+// the stored file of every accepted content type
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+type Service struct {
+	Store storage.Storage
+	// upload size limit in bytes
+	MaxBytes int64
+	// images with a bigger side are refused before they are decoded
+	MaxPixels int
+}
+
+func New(store storage.Storage) *Service {
+	return &Service{Store: store, MaxBytes: 5 << 20, MaxPixels: 6000}
+}
+
+// Save checks the upload by its content (not the name or the header), keeps
+// the original and the thumbnails under isbn/<version>/ and returns the
+// original key, every upload has its own version so an older cover stays
+// until it is deleted
+func (s *Service) Save(ctx context.Context, isbn string, r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.MaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+
+	if int64(len(data)) > s.MaxBytes {
+		return "", ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return "", ErrUnsupported
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrUnsupported
+	}
+
+	if cfg.Width > s.MaxPixels || cfg.Height > s.MaxPixels {
+		return "", fmt.Errorf("%w, the sides should be at most %d pixels", ErrTooLarge, s.MaxPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrUnsupported
+	}
+
+	version := make([]byte, 6)
+	if _, err := rand.Read(version); err != nil {
+		return "", err
+	}
+
+	// converted once for every size
+	rgba := toRGBA(img)
+	dir := strings.ReplaceAll(isbn, "/", "-") + "/" + hex.EncodeToString(version)
+	key := dir + "/original" + ext
+	for name, width := range Sizes {
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, Thumbnail(rgba, width), &jpeg.Options{Quality: 85})
+		if err == nil {
+			err = s.Store.Put(ctx, dir+"/"+name+".jpg", &buf, int64(buf.Len()), "image/jpeg")
+		}
+		if err != nil {
+			s.Delete(ctx, key)
+			return "", err
+		}
+	}
+
+	err = s.Store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+	if err != nil {
+		s.Delete(ctx, key)
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Delete removes the original and every thumbnail of a stored cover key
+func (s *Service) Delete(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+
+	dir := path.Dir(key)
+	var errs []error
+	for name := range Sizes {
+		errs = append(errs, s.Store.Delete(ctx, dir+"/"+name+".jpg"))
+	}
+	errs = append(errs, s.Store.Delete(ctx, key))
+
+	return errors.Join(errs...)
+}
+
+// URLs of the original and every thumbnail of a stored cover key
+func (s *Service) URLs(key string) map[string]string {
+	if key == "" {
+		return nil
+	}
+
+	urls := map[string]string{"original": s.Store.URL(key)}
+	dir := path.Dir(key)
+	for name := range Sizes {
+		urls[name] = s.Store.URL(dir + "/" + name + ".jpg")
+	}
+
+	return urls
+}
+
+// src as *image.RGBA, without a copy when it already is one
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok {
+		return rgba
+	}
+
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	return rgba
+}
+
+// Thumbnail scales src down to width (never up) by averaging the source
+// pixels of every target pixel, transparent parts end up white
+func Thumbnail(src *image.RGBA, width int) *image.RGBA {
+	b := src.Bounds()
+	if width > b.Dx() {
+		width = b.Dx()
+	}
+
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy0, sy1 := span(y, height, b.Dy())
+		for x := 0; x < width; x++ {
+			sx0, sx1 := span(x, width, b.Dx())
+
+			var r, g, bl, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(b.Min.X+sx0, b.Min.Y+sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += int(src.Pix[i])
+					g += int(src.Pix[i+1])
+					bl += int(src.Pix[i+2])
+					a += int(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			// premultiplied colours over white
+			white := (255*n - a)
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8((r + white) / n)
+			dst.Pix[i+1] = uint8((g + white) / n)
+			dst.Pix[i+2] = uint8((bl + white) / n)
+			dst.Pix[i+3] = 255
+		}
+	}
+
+	return dst
+}
+
+// source range of target pixel i, never empty
+func span(i, target, source int) (int, int) {
+	lo := i * source / target
+	hi := (i + 1) * source / target
+	if hi <= lo {
+		hi = lo + 1
+	}
+
+	return lo, hi
+}
@@ -0,0 +1,224 @@
+package covers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"test.iamgak.net/storage"
+)
+
+// a grey png of width x height
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func testService(t *testing.T) (*Service, string) {
+	t.Helper()
+	root := t.TempDir()
+	store, err := storage.NewLocal(root, "/covers/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(store), root
+}
+
+func TestSave(t *testing.T) {
+	ctx := context.Background()
+	s, root := testService(t)
+	key, err := s.Save(ctx, "978/0451524935", bytes.NewReader(pngImage(t, 400, 600)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path.Base(key) != "original.png" || path.Dir(path.Dir(key)) != "978-0451524935" {
+		t.Fatalf("key = %q, want 978-0451524935/<version>/original.png", key)
+	}
+
+	for name, width := range map[string]int{"small": 120, "medium": 300, "large": 400} {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path.Dir(key)), name+".jpg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil || cfg.Width != width || cfg.Height != width*3/2 {
+			t.Errorf("%s is %dx%d: %v, want %dx%d", name, cfg.Width, cfg.Height, err, width, width*3/2)
+		}
+	}
+
+	urls := s.URLs(key)
+	if len(urls) != 4 || urls["original"] != "/covers/"+key || urls["small"] != "/covers/"+path.Dir(key)+"/small.jpg" {
+		t.Errorf("URLs = %v", urls)
+	}
+
+	// every upload has its own version
+	again, err := s.Save(ctx, "978/0451524935", bytes.NewReader(pngImage(t, 10, 10)))
+	if err != nil || again == key {
+		t.Fatalf("second Save = %q, %v, want a new key", again, err)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(key))); !os.IsNotExist(err) {
+		t.Errorf("original after Delete: %v, want it gone", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(path.Dir(again)), "small.jpg")); err != nil {
+		t.Errorf("Delete removed the other version: %v", err)
+	}
+}
+
+func TestSaveRefused(t *testing.T) {
+	var gifImage bytes.Buffer
+	if err := gif.Encode(&gifImage, image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.White}), nil); err != nil {
+		t.Fatal(err)
+	}
+	valid := pngImage(t, 100, 100)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		maxBytes  int64
+		maxPixels int
+		want      error
+	}{
+		{name: "gif", data: gifImage.Bytes()},
+		{name: "text", data: []byte("<svg xmlns='http://www.w3.org/2000/svg'/>"), want: ErrUnsupported},
+		{name: "broken png", data: valid[:len(valid)/2], want: ErrUnsupported},
+		{name: "png header only", data: valid[:8], want: ErrUnsupported},
+		{name: "over the size limit", data: valid, maxBytes: int64(len(valid)) - 1, want: ErrTooLarge},
+		{name: "at the size limit", data: valid, maxBytes: int64(len(valid))},
+		{name: "over the pixel limit", data: valid, maxPixels: 99, want: ErrTooLarge},
+		{name: "at the pixel limit", data: valid, maxPixels: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := testService(t)
+			if tt.maxBytes > 0 {
+				s.MaxBytes = tt.maxBytes
+			}
+			if tt.maxPixels > 0 {
+				s.MaxPixels = tt.maxPixels
+			}
+
+			_, err := s.Save(context.Background(), "isbn", bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Save = %v, want %v", err, tt.want)
+			}
+
+			// nothing is stored of a refused upload
+			files, _ := filepath.Glob(filepath.Join(root, "*"))
+			if tt.want != nil && len(files) != 0 {
+				t.Errorf("stored %v of a refused upload", files)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	// left half black, right half white, the bottom row transparent
+	src := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if x >= 2 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	tests := []struct {
+		name   string
+		src    *image.RGBA
+		width  int
+		size   image.Point
+		pixels map[image.Point]color.RGBA
+	}{
+		{
+			name:  "never scaled up",
+			src:   src,
+			width: 10,
+			size:  image.Pt(4, 3),
+			pixels: map[image.Point]color.RGBA{
+				{0, 0}: {0, 0, 0, 255},
+				{3, 0}: {255, 255, 255, 255},
+				{0, 2}: {255, 255, 255, 255},
+			},
+		},
+		{
+			name:  "averaged",
+			src:   src,
+			width: 2,
+			size:  image.Pt(2, 1),
+			pixels: map[image.Point]color.RGBA{
+				// two black and one transparent row
+				{0, 0}: {85, 85, 85, 255},
+				{1, 0}: {255, 255, 255, 255},
+			},
+		},
+		{
+			name:  "one pixel high at least",
+			src:   image.NewRGBA(image.Rect(0, 0, 100, 1)),
+			width: 10,
+			size:  image.Pt(10, 1),
+		},
+		{
+			name:   "sub image",
+			src:    src.SubImage(image.Rect(2, 0, 4, 2)).(*image.RGBA),
+			width:  1,
+			size:   image.Pt(1, 1),
+			pixels: map[image.Point]color.RGBA{{0, 0}: {255, 255, 255, 255}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := Thumbnail(tt.src, tt.width)
+			if dst.Bounds().Size() != tt.size {
+				t.Fatalf("size = %v, want %v", dst.Bounds().Size(), tt.size)
+			}
+			for p, want := range tt.pixels {
+				if got := dst.RGBAAt(p.X, p.Y); got != want {
+					t.Errorf("pixel %v = %v, want %v", p, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestToRGBA(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	if toRGBA(rgba) != rgba {
+		t.Error("toRGBA copied an *image.RGBA")
+	}
+
+	gray := image.NewGray(image.Rect(5, 5, 7, 8))
+	gray.SetGray(5, 5, color.Gray{Y: 40})
+	got := toRGBA(gray)
+	if got.Bounds() != image.Rect(0, 0, 2, 3) || got.RGBAAt(0, 0) != (color.RGBA{40, 40, 40, 255}) {
+		t.Errorf("toRGBA of a gray image = %v %v", got.Bounds(), got.RGBAAt(0, 0))
+	}
+}
@@ -1,5 +1,3 @@
-DROP DATABASE IF EXISTS bookstore;
-
 DROP TABLE IF EXISTS reviews;
 DROP TABLE IF EXISTS users;
 DROP TABLE IF EXISTS forget_passw;
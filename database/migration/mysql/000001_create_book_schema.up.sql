@@ -1,6 +1,3 @@
--- Create database if it doesn't exist
-CREATE DATABASE IF NOT EXISTS `bookstore`;
-
 -- Create reviews table
 CREATE TABLE IF NOT EXISTS `reviews` (
   `id` int(11) PRIMARY KEY AUTO_INCREMENT NOT NULL,
@@ -58,26 +55,3 @@ CREATE TABLE IF NOT EXISTS `books` (
   `descriptions` text NOT NULL,
   `price` decimal(10,2) NOT NULL
 );
-
--- Insert dummy data into users table
--- password user type will be password1 for both reset it after ward 
-
-INSERT INTO `users` (`email`, `password`, `created_at`, `login_token`, `active`, `activation_token`) VALUES
-('user1@example.com', '$2a$12$vChxDZJ8me0zA2gWMwq7MOcNYScff4xe6mIv/xEJNwfRDpVSXcure', current_timestamp(), NULL, 1, NULL),
-('user2@example.com', '$2a$12$vChxDZJ8me0zA2gWMwq7MOcNYScff4xe6mIv/xEJNwfRDpVSXcure', current_timestamp(), NULL, 1, NULL);
-
--- Insert dummy data into books table 
-INSERT INTO `books` (`isbn`, `title`, `author`, `genre`, `descriptions`, `price`) VALUES
-('978-3-16-148410-0', 'Sapiens', 'Yoah N Harari', 'Reality', 'Human Kind Development', 19.99),
-('978-1-23-456789-7', 'Animal Farm', 'George Orwell', 'Fiction', 'Politics & leadership', 29.99);
-
--- Insert dummy data into reviews table 
-INSERT INTO `reviews` (`isbn`, `title`, `author`, `genre`, `descriptions`, `price`, `created_at`, `uid`, `rating`, `is_deleted`) VALUES
-('978-3-16-148410-0', 'Book Title 1', 'Author 1', 'Fiction', 'Review for book 1', 19.99, current_timestamp(), 1, 5, 0),
-('978-1-23-456789-7', 'Book Title 2', 'Author 2', 'Non-Fiction', 'Review for book 2', 29.99, current_timestamp(), 2, 4, 0);
-
-
---  Insert dummy data into user_log table 
-INSERT INTO `user_log` (`activity`, `uid`, `created_at`, `superseded`) VALUES
-('Login', 1, current_timestamp(), 0),
-('Logout', 2, current_timestamp(), 0);
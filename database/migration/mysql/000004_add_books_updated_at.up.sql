@@ -0,0 +1 @@
+ALTER TABLE `books` ADD COLUMN `updated_at` datetime NOT NULL DEFAULT current_timestamp() ON UPDATE current_timestamp();
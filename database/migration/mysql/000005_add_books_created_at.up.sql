@@ -0,0 +1 @@
+ALTER TABLE `books` ADD COLUMN `created_at` datetime NOT NULL DEFAULT current_timestamp() AFTER `price`;
@@ -0,0 +1 @@
+ALTER TABLE `books` ADD COLUMN `cover` varchar(255) NULL AFTER `genre`;
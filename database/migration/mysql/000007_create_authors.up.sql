@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS `authors` (
+  `id` int(11) PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `name` varchar(255) UNIQUE NOT NULL,
+  `bio` text NULL,
+  `created_at` datetime NOT NULL DEFAULT current_timestamp()
+);
+
+CREATE TABLE IF NOT EXISTS `author_aliases` (
+  `id` int(11) PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `author_id` int(11) NOT NULL,
+  `alias` varchar(255) UNIQUE NOT NULL,
+  KEY `author_id` (`author_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `book_authors` (
+  `isbn` varchar(100) NOT NULL,
+  `author_id` int(11) NOT NULL,
+  `role` enum('author','editor','translator','illustrator') NOT NULL DEFAULT 'author',
+  `position` int(11) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`isbn`, `author_id`, `role`),
+  KEY `author_id` (`author_id`)
+);
+
+-- books.author holds one name or a comma separated list, every name becomes
+-- one author. Names are trimmed and compared case insensitive, so "orwell" and
+-- " Orwell" end up as the same author; spellings like "G. Orwell" are merged
+-- later with POST /admin/author/merge/:id
+INSERT IGNORE INTO `authors` (`name`)
+WITH RECURSIVE `names` (`isbn`, `name`, `rest`, `position`) AS (
+  SELECT `isbn`, TRIM(SUBSTRING_INDEX(`author`, ',', 1)), IF(LOCATE(',', `author`) > 0, SUBSTRING(`author`, LOCATE(',', `author`) + 1), NULL), 0 FROM `books`
+  UNION ALL
+  SELECT `isbn`, TRIM(SUBSTRING_INDEX(`rest`, ',', 1)), IF(LOCATE(',', `rest`) > 0, SUBSTRING(`rest`, LOCATE(',', `rest`) + 1), NULL), `position` + 1 FROM `names` WHERE `rest` IS NOT NULL
+)
+SELECT DISTINCT `name` FROM `names` WHERE `name` <> '';
+
+INSERT IGNORE INTO `book_authors` (`isbn`, `author_id`, `role`, `position`)
+WITH RECURSIVE `names` (`isbn`, `name`, `rest`, `position`) AS (
+  SELECT `isbn`, TRIM(SUBSTRING_INDEX(`author`, ',', 1)), IF(LOCATE(',', `author`) > 0, SUBSTRING(`author`, LOCATE(',', `author`) + 1), NULL), 0 FROM `books`
+  UNION ALL
+  SELECT `isbn`, TRIM(SUBSTRING_INDEX(`rest`, ',', 1)), IF(LOCATE(',', `rest`) > 0, SUBSTRING(`rest`, LOCATE(',', `rest`) + 1), NULL), `position` + 1 FROM `names` WHERE `rest` IS NOT NULL
+)
+SELECT n.`isbn`, a.`id`, 'author', n.`position` FROM `names` n JOIN `authors` a ON a.`name` = n.`name`;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS `genres` (
+  `id` int(11) PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `parent_id` int(11) NULL,
+  `name` varchar(50) NOT NULL,
+  `slug` varchar(100) UNIQUE NOT NULL,
+  KEY `parent_id` (`parent_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `book_genres` (
+  `isbn` varchar(100) NOT NULL,
+  `genre_id` int(11) NOT NULL,
+  PRIMARY KEY (`isbn`, `genre_id`),
+  KEY `genre_id` (`genre_id`)
+);
+
+-- every books.genre becomes a genre at the top of the tree, admins arrange
+-- them afterwards
+INSERT IGNORE INTO `genres` (`name`, `slug`)
+SELECT DISTINCT TRIM(`genre`), TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(TRIM(`genre`), '[^[:alnum:]]+', '-'))) FROM `books` WHERE TRIM(`genre`) <> '';
+
+INSERT IGNORE INTO `book_genres` (`isbn`, `genre_id`)
+SELECT b.`isbn`, g.`id` FROM `books` b JOIN `genres` g ON g.`slug` = TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(TRIM(b.`genre`), '[^[:alnum:]]+', '-')));
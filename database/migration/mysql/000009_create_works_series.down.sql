@@ -0,0 +1,3 @@
+ALTER TABLE `books` DROP KEY `work_id`, DROP COLUMN `work_id`, DROP COLUMN `format`, DROP COLUMN `language`;
+DROP TABLE IF EXISTS `works`;
+DROP TABLE IF EXISTS `series`;
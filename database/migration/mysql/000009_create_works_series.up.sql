@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS `series` (
+  `id` int(11) PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `total` int(11) NULL
+);
+
+CREATE TABLE IF NOT EXISTS `works` (
+  `id` int(11) PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `title` varchar(255) NOT NULL,
+  `series_id` int(11) NULL,
+  `series_position` int(11) NULL,
+  KEY `series_id` (`series_id`)
+);
+
+ALTER TABLE `books` ADD COLUMN `work_id` int(11) NULL AFTER `isbn`,
+  ADD COLUMN `format` varchar(20) NULL AFTER `cover`,
+  ADD COLUMN `language` varchar(10) NULL AFTER `format`,
+  ADD KEY `work_id` (`work_id`);
+
+-- books with the same title and author are taken as editions of one work,
+-- translations and retitled editions are moved by hand afterwards
+ALTER TABLE `works` ADD COLUMN `edition_key` varchar(512) NULL;
+
+INSERT INTO `works` (`title`, `edition_key`)
+SELECT MIN(TRIM(`title`)), CONCAT(LOWER(TRIM(`title`)), '|', LOWER(TRIM(`author`))) FROM `books`
+GROUP BY CONCAT(LOWER(TRIM(`title`)), '|', LOWER(TRIM(`author`)));
+
+UPDATE `books` b JOIN `works` w ON w.`edition_key` = CONCAT(LOWER(TRIM(b.`title`)), '|', LOWER(TRIM(b.`author`)))
+SET b.`work_id` = w.`id`;
+
+ALTER TABLE `works` DROP COLUMN `edition_key`;
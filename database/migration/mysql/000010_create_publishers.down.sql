@@ -0,0 +1,4 @@
+ALTER TABLE `books` DROP KEY `publisher_id`, DROP COLUMN `publisher_id`;
+DROP TABLE IF EXISTS `sales`;
+DROP TABLE IF EXISTS `publisher_members`;
+DROP TABLE IF EXISTS `publishers`;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS `publishers` (
+  `id` int(11) PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `name` varchar(255) UNIQUE NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT current_timestamp()
+);
+
+CREATE TABLE IF NOT EXISTS `publisher_members` (
+  `publisher_id` int(11) NOT NULL,
+  `uid` int(11) NOT NULL,
+  `role` enum('owner','editor') NOT NULL DEFAULT 'editor',
+  PRIMARY KEY (`publisher_id`, `uid`),
+  KEY `uid` (`uid`)
+);
+
+CREATE TABLE IF NOT EXISTS `sales` (
+  `id` int(11) PRIMARY KEY AUTO_INCREMENT NOT NULL,
+  `isbn` varchar(100) NOT NULL,
+  `quantity` int(11) NOT NULL,
+  `amount` decimal(10,2) NOT NULL,
+  `sold_at` datetime NOT NULL DEFAULT current_timestamp(),
+  KEY `isbn` (`isbn`)
+);
+
+-- books listed before publishers existed stay without one, only admins edit them
+ALTER TABLE `books` ADD COLUMN `publisher_id` int(11) NULL AFTER `work_id`,
+  ADD KEY `publisher_id` (`publisher_id`);
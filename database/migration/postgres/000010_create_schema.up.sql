@@ -0,0 +1,178 @@
+-- the schema of the MySQL migrations up to 000010 in one step, PostgreSQL
+-- databases start here. Text compared by the models is citext, like the MySQL
+-- collation
+
+CREATE EXTENSION IF NOT EXISTS citext;
+
+-- Create reviews table
+CREATE TABLE IF NOT EXISTS "reviews" (
+  "id" SERIAL PRIMARY KEY,
+  "isbn" varchar(50) NOT NULL,
+  "title" varchar(255) NOT NULL,
+  "author" varchar(255) NOT NULL DEFAULT '',
+  "genre" varchar(50) NOT NULL DEFAULT '',
+  "descriptions" text NOT NULL,
+  "price" decimal(5,2) NOT NULL,
+  "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+  "uid" int NOT NULL,
+  "rating" int NOT NULL,
+  "is_deleted" smallint DEFAULT 0
+);
+
+-- Create users table
+
+CREATE TABLE IF NOT EXISTS "users" (
+  "id" SERIAL PRIMARY KEY,
+  "email" citext UNIQUE NOT NULL,
+  "password" varchar(100) NOT NULL,
+  "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+  "login_token" varchar(100) DEFAULT NULL,
+  "active" smallint DEFAULT 0,
+  "activation_token" varchar(100) DEFAULT NULL,
+  "role" varchar(20) NOT NULL DEFAULT 'user'
+);
+
+--  Create forget_passw table
+
+CREATE TABLE IF NOT EXISTS "forget_passw" (
+  "id" SERIAL PRIMARY KEY,
+  "uid" int NOT NULL,
+  "uri" varchar(100) NOT NULL,
+  "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+  "superseded" smallint DEFAULT 0
+);
+
+--  Create user_log table
+
+CREATE TABLE IF NOT EXISTS "user_log" (
+  "id" SERIAL PRIMARY KEY,
+  "activity" varchar(50) NOT NULL,
+  "uid" int NOT NULL,
+  "created_at" timestamp DEFAULT CURRENT_TIMESTAMP,
+  "superseded" smallint DEFAULT 0
+);
+
+--  Create books table
+
+CREATE TABLE IF NOT EXISTS "books" (
+  "isbn" varchar(100) PRIMARY KEY,
+  "work_id" int NULL,
+  "publisher_id" int NULL,
+  "title" citext NOT NULL,
+  "author" citext NOT NULL,
+  "genre" varchar(50) NOT NULL,
+  "cover" varchar(255) NULL,
+  "format" varchar(20) NULL,
+  "language" varchar(10) NULL,
+  "descriptions" text NOT NULL,
+  "price" decimal(10,2) NOT NULL,
+  "created_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  "updated_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS "books_work_id" ON "books" ("work_id");
+CREATE INDEX IF NOT EXISTS "books_publisher_id" ON "books" ("publisher_id");
+
+-- ON UPDATE current_timestamp() of MySQL
+CREATE OR REPLACE FUNCTION "books_updated_at"() RETURNS trigger AS $$
+BEGIN
+  NEW."updated_at" = CURRENT_TIMESTAMP;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "books_updated_at" ON "books";
+CREATE TRIGGER "books_updated_at" BEFORE UPDATE ON "books"
+FOR EACH ROW EXECUTE FUNCTION "books_updated_at"();
+
+--  Create works and series tables, the editions of a work share books.work_id
+
+CREATE TABLE IF NOT EXISTS "series" (
+  "id" SERIAL PRIMARY KEY,
+  "name" varchar(255) NOT NULL,
+  "total" int NULL
+);
+
+CREATE TABLE IF NOT EXISTS "works" (
+  "id" SERIAL PRIMARY KEY,
+  "title" varchar(255) NOT NULL,
+  "series_id" int NULL,
+  "series_position" int NULL
+);
+
+CREATE INDEX IF NOT EXISTS "works_series_id" ON "works" ("series_id");
+
+--  Create authors tables, books are credited through book_authors
+
+CREATE TABLE IF NOT EXISTS "authors" (
+  "id" SERIAL PRIMARY KEY,
+  "name" citext UNIQUE NOT NULL,
+  "bio" text NULL,
+  "created_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS "author_aliases" (
+  "id" SERIAL PRIMARY KEY,
+  "author_id" int NOT NULL,
+  "alias" citext UNIQUE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "author_aliases_author_id" ON "author_aliases" ("author_id");
+
+CREATE TABLE IF NOT EXISTS "book_authors" (
+  "isbn" varchar(100) NOT NULL,
+  "author_id" int NOT NULL,
+  "role" varchar(20) NOT NULL DEFAULT 'author' CHECK ("role" IN ('author','editor','translator','illustrator')),
+  "position" int NOT NULL DEFAULT 0,
+  PRIMARY KEY ("isbn", "author_id", "role")
+);
+
+CREATE INDEX IF NOT EXISTS "book_authors_author_id" ON "book_authors" ("author_id");
+
+--  Create genres tables, a tree through parent_id, books are filed through book_genres
+
+CREATE TABLE IF NOT EXISTS "genres" (
+  "id" SERIAL PRIMARY KEY,
+  "parent_id" int NULL,
+  "name" citext NOT NULL,
+  "slug" citext UNIQUE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "genres_parent_id" ON "genres" ("parent_id");
+
+CREATE TABLE IF NOT EXISTS "book_genres" (
+  "isbn" varchar(100) NOT NULL,
+  "genre_id" int NOT NULL,
+  PRIMARY KEY ("isbn", "genre_id")
+);
+
+CREATE INDEX IF NOT EXISTS "book_genres_genre_id" ON "book_genres" ("genre_id");
+
+--  Create publishers tables, members of a publisher manage the books with its publisher_id
+
+CREATE TABLE IF NOT EXISTS "publishers" (
+  "id" SERIAL PRIMARY KEY,
+  "name" citext UNIQUE NOT NULL,
+  "created_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS "publisher_members" (
+  "publisher_id" int NOT NULL,
+  "uid" int NOT NULL,
+  "role" varchar(10) NOT NULL DEFAULT 'editor' CHECK ("role" IN ('owner','editor')),
+  PRIMARY KEY ("publisher_id", "uid")
+);
+
+CREATE INDEX IF NOT EXISTS "publisher_members_uid" ON "publisher_members" ("uid");
+
+--  Create sales table, one row per recorded sale of a book
+
+CREATE TABLE IF NOT EXISTS "sales" (
+  "id" SERIAL PRIMARY KEY,
+  "isbn" varchar(100) NOT NULL,
+  "quantity" int NOT NULL,
+  "amount" decimal(10,2) NOT NULL,
+  "sold_at" timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS "sales_isbn" ON "sales" ("isbn");
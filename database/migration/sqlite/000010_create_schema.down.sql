@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS `sales`;
+DROP TABLE IF EXISTS `publisher_members`;
+DROP TABLE IF EXISTS `publishers`;
+DROP TABLE IF EXISTS `book_genres`;
+DROP TABLE IF EXISTS `genres`;
+DROP TABLE IF EXISTS `book_authors`;
+DROP TABLE IF EXISTS `author_aliases`;
+DROP TABLE IF EXISTS `authors`;
+DROP TABLE IF EXISTS `works`;
+DROP TABLE IF EXISTS `series`;
+DROP TABLE IF EXISTS `books`;
+DROP TABLE IF EXISTS `user_log`;
+DROP TABLE IF EXISTS `forget_passw`;
+DROP TABLE IF EXISTS `users`;
+DROP TABLE IF EXISTS `reviews`;
@@ -0,0 +1,171 @@
+-- the schema of the MySQL migrations up to 000010 in one step, SQLite
+-- databases start here. Text compared by the models is NOCASE, like the MySQL
+-- collation
+
+-- Create reviews table
+CREATE TABLE IF NOT EXISTS `reviews` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `isbn` varchar(50) NOT NULL,
+  `title` varchar(255) NOT NULL,
+  `author` varchar(255) NOT NULL DEFAULT '',
+  `genre` varchar(50) NOT NULL DEFAULT '',
+  `descriptions` text NOT NULL,
+  `price` decimal(5,2) NOT NULL,
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  `uid` int NOT NULL,
+  `rating` int NOT NULL,
+  `is_deleted` tinyint DEFAULT 0
+);
+
+-- Create users table
+
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `email` varchar(100) COLLATE NOCASE UNIQUE NOT NULL,
+  `password` varchar(100) NOT NULL,
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  `login_token` varchar(100) DEFAULT NULL,
+  `active` tinyint DEFAULT 0,
+  `activation_token` varchar(100) DEFAULT NULL,
+  `role` varchar(20) NOT NULL DEFAULT 'user'
+);
+
+--  Create forget_passw table
+
+CREATE TABLE IF NOT EXISTS `forget_passw` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `uid` int NOT NULL,
+  `uri` varchar(100) NOT NULL,
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  `superseded` tinyint DEFAULT 0
+);
+
+--  Create user_log table
+
+CREATE TABLE IF NOT EXISTS `user_log` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `activity` varchar(50) NOT NULL,
+  `uid` int NOT NULL,
+  `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+  `superseded` tinyint DEFAULT 0
+);
+
+--  Create books table
+
+CREATE TABLE IF NOT EXISTS `books` (
+  `isbn` varchar(100) PRIMARY KEY NOT NULL,
+  `work_id` int NULL,
+  `publisher_id` int NULL,
+  `title` varchar(255) COLLATE NOCASE NOT NULL,
+  `author` varchar(255) COLLATE NOCASE NOT NULL,
+  `genre` varchar(50) NOT NULL,
+  `cover` varchar(255) NULL,
+  `format` varchar(20) NULL,
+  `language` varchar(10) NULL,
+  `descriptions` text NOT NULL,
+  `price` decimal(10,2) NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS `books_work_id` ON `books` (`work_id`);
+CREATE INDEX IF NOT EXISTS `books_publisher_id` ON `books` (`publisher_id`);
+
+-- ON UPDATE current_timestamp() of MySQL
+CREATE TRIGGER IF NOT EXISTS `books_updated_at` AFTER UPDATE ON `books`
+FOR EACH ROW WHEN NEW.`updated_at` = OLD.`updated_at`
+BEGIN
+  UPDATE `books` SET `updated_at` = CURRENT_TIMESTAMP WHERE `isbn` = NEW.`isbn`;
+END;
+
+--  Create works and series tables, the editions of a work share books.work_id
+
+CREATE TABLE IF NOT EXISTS `series` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `total` int NULL
+);
+
+CREATE TABLE IF NOT EXISTS `works` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `title` varchar(255) NOT NULL,
+  `series_id` int NULL,
+  `series_position` int NULL
+);
+
+CREATE INDEX IF NOT EXISTS `works_series_id` ON `works` (`series_id`);
+
+--  Create authors tables, books are credited through book_authors
+
+CREATE TABLE IF NOT EXISTS `authors` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `name` varchar(255) COLLATE NOCASE UNIQUE NOT NULL,
+  `bio` text NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS `author_aliases` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `author_id` int NOT NULL,
+  `alias` varchar(255) COLLATE NOCASE UNIQUE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS `author_aliases_author_id` ON `author_aliases` (`author_id`);
+
+CREATE TABLE IF NOT EXISTS `book_authors` (
+  `isbn` varchar(100) NOT NULL,
+  `author_id` int NOT NULL,
+  `role` varchar(20) NOT NULL DEFAULT 'author' CHECK (`role` IN ('author','editor','translator','illustrator')),
+  `position` int NOT NULL DEFAULT 0,
+  PRIMARY KEY (`isbn`, `author_id`, `role`)
+);
+
+CREATE INDEX IF NOT EXISTS `book_authors_author_id` ON `book_authors` (`author_id`);
+
+--  Create genres tables, a tree through parent_id, books are filed through book_genres
+
+CREATE TABLE IF NOT EXISTS `genres` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `parent_id` int NULL,
+  `name` varchar(50) COLLATE NOCASE NOT NULL,
+  `slug` varchar(100) COLLATE NOCASE UNIQUE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS `genres_parent_id` ON `genres` (`parent_id`);
+
+CREATE TABLE IF NOT EXISTS `book_genres` (
+  `isbn` varchar(100) NOT NULL,
+  `genre_id` int NOT NULL,
+  PRIMARY KEY (`isbn`, `genre_id`)
+);
+
+CREATE INDEX IF NOT EXISTS `book_genres_genre_id` ON `book_genres` (`genre_id`);
+
+--  Create publishers tables, members of a publisher manage the books with its publisher_id
+
+CREATE TABLE IF NOT EXISTS `publishers` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `name` varchar(255) COLLATE NOCASE UNIQUE NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS `publisher_members` (
+  `publisher_id` int NOT NULL,
+  `uid` int NOT NULL,
+  `role` varchar(10) NOT NULL DEFAULT 'editor' CHECK (`role` IN ('owner','editor')),
+  PRIMARY KEY (`publisher_id`, `uid`)
+);
+
+CREATE INDEX IF NOT EXISTS `publisher_members_uid` ON `publisher_members` (`uid`);
+
+--  Create sales table, one row per recorded sale of a book
+
+CREATE TABLE IF NOT EXISTS `sales` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+  `isbn` varchar(100) NOT NULL,
+  `quantity` int NOT NULL,
+  `amount` decimal(10,2) NOT NULL,
+  `sold_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS `sales_isbn` ON `sales` (`isbn`);
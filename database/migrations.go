@@ -0,0 +1,18 @@
+// Package database carries the schema migrations in the binary, one directory
+// of migration/ per database.driver with files named like
+// 000001_create_book_schema.up.sql and 000001_create_book_schema.down.sql
+package database
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+)
+
+//go:embed migration
+var migrations embed.FS
+
+// Migrations of the driver, mysql, sqlite or postgres
+func Migrations(driver string) (fs.FS, error) {
+	return fs.Sub(migrations, path.Join("migration", driver))
+}
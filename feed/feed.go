@@ -0,0 +1,214 @@
+// Package feed renders syndication feeds as Atom 1.0 or RSS 2.0 from one
+// Feed value, so subscribers get the same entries whatever they read.
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+const (
+	Atom = "atom"
+	RSS  = "rss"
+)
+
+var ContentTypes = map[string]string{
+	Atom: "application/atom+xml; charset=utf-8",
+	RSS:  "application/rss+xml; charset=utf-8",
+}
+
+type Feed struct {
+	ID    string
+	Title string
+	// absolute url of the html page the feed is about
+	Link string
+	// absolute url of the feed itself
+	Self        string
+	Description string
+	Author      string
+	Updated     time.Time
+	Entries     []*Entry
+}
+
+type Entry struct {
+	// stable and unique, a tag: or urn: uri
+	ID         string
+	Title      string
+	Link       string
+	Authors    []string
+	Summary    string
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+}
+
+// newest Updated of the entries, or fallback for an empty feed
+func LastUpdated(entries []*Entry, fallback time.Time) time.Time {
+	last := time.Time{}
+	for _, e := range entries {
+		if e.Updated.After(last) {
+			last = e.Updated
+		}
+	}
+
+	if last.IsZero() {
+		return fallback
+	}
+
+	return last
+}
+
+// Write renders the feed in format, Atom when the format is unknown
+func Write(w io.Writer, format string, f *Feed) error {
+	var doc any
+	if format == RSS {
+		doc = rssDocument(f)
+	} else {
+		doc = atomDocument(f)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomPerson   `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+}
+
+func atomDocument(f *Feed) *atomFeed {
+	out := &atomFeed{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: f.Author},
+		Links: []atomLink{
+			{Rel: "self", Href: f.Self, Type: "application/atom+xml"},
+			{Rel: "alternate", Href: f.Link, Type: "text/html"},
+		},
+	}
+
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Updated:   e.Updated.UTC().Format(time.RFC3339),
+			Links:     []atomLink{{Rel: "alternate", Href: e.Link, Type: "text/html"}},
+			Summary:   e.Summary,
+		}
+		for _, name := range e.Authors {
+			entry.Authors = append(entry.Authors, atomPerson{Name: name})
+		}
+		for _, c := range e.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: c})
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+
+	return out
+}
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	XmlnsAtom string     `xml:"xmlns:atom,attr"`
+	XmlnsDC   string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string     `xml:"title"`
+	Link          string     `xml:"link"`
+	Description   string     `xml:"description"`
+	AtomLink      atomLinkNS `xml:"atom:link"`
+	LastBuildDate string     `xml:"lastBuildDate"`
+	Items         []rssItem  `xml:"item"`
+}
+
+// atom:link rel=self, recommended by the RSS validators
+type atomLinkNS struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Creators    []string `xml:"dc:creator"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func rssDocument(f *Feed) *rssFeed {
+	out := &rssFeed{
+		Version:   "2.0",
+		XmlnsAtom: "http://www.w3.org/2005/Atom",
+		XmlnsDC:   "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Description,
+			AtomLink:      atomLinkNS{Rel: "self", Href: f.Self, Type: "application/rss+xml"},
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, e := range f.Entries {
+		out.Channel.Items = append(out.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			GUID:        rssGUID{Value: e.ID},
+			Creators:    e.Authors,
+			Categories:  e.Categories,
+			Description: e.Summary,
+			PubDate:     e.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	return out
+}
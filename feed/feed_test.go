@@ -0,0 +1,130 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastUpdated(t *testing.T) {
+	fallback := time.Unix(0, 0)
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name    string
+		entries []*Entry
+		want    time.Time
+	}{
+		{"no entries", nil, fallback},
+		{"entries without a time", []*Entry{{}, {}}, fallback},
+		{"newest", []*Entry{{Updated: day(2)}, {Updated: day(9)}, {Updated: day(5)}}, day(9)},
+	}
+
+	for _, tt := range tests {
+		if got := LastUpdated(tt.entries, fallback); !got.Equal(tt.want) {
+			t.Errorf("%s: LastUpdated = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func testFeed() *Feed {
+	// not in UTC, the output is
+	published := time.Date(2024, 5, 1, 14, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	return &Feed{
+		ID:          "urn:bookstore:feed:books",
+		Title:       "New books | Bookstore",
+		Link:        "https://books.example.com/opds/books",
+		Self:        "https://books.example.com/feed/books?format=rss",
+		Description: "New books in the Bookstore catalog",
+		Author:      "Bookstore",
+		Updated:     published.Add(time.Hour),
+		Entries: []*Entry{{
+			ID:         "urn:isbn:978-0-451-52493-5",
+			Title:      "Animal Farm & <Co>",
+			Link:       "https://books.example.com/book/978-0-451-52493-5",
+			Authors:    []string{"Terry Pratchett", "Neil Gaiman"},
+			Summary:    "All animals are equal",
+			Categories: []string{"Satire", "Classics"},
+			Published:  published,
+			Updated:    published.Add(time.Hour),
+		}},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Atom, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got atomFeed
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Atom = %s: %v", buf.String(), err)
+	}
+	if got.XMLName.Space != "http://www.w3.org/2005/Atom" || got.Updated != "2024-05-01T13:30:00Z" || got.Author.Name != "Bookstore" {
+		t.Errorf("feed = %+v", got)
+	}
+	if len(got.Links) != 2 || got.Links[0] != (atomLink{Rel: "self", Href: "https://books.example.com/feed/books?format=rss", Type: "application/atom+xml"}) || got.Links[1].Rel != "alternate" {
+		t.Errorf("links = %+v, want self and alternate", got.Links)
+	}
+	if len(got.Entries) != 1 {
+		t.Fatalf("entries = %+v, want one", got.Entries)
+	}
+
+	e := got.Entries[0]
+	if e.Title != "Animal Farm & <Co>" || e.Published != "2024-05-01T12:30:00Z" || e.Updated != "2024-05-01T13:30:00Z" {
+		t.Errorf("entry = %+v", e)
+	}
+	if len(e.Authors) != 2 || e.Authors[0].Name != "Terry Pratchett" || e.Authors[1].Name != "Neil Gaiman" {
+		t.Errorf("authors = %+v, want one per name", e.Authors)
+	}
+	if len(e.Categories) != 2 || e.Categories[1].Term != "Classics" {
+		t.Errorf("categories = %+v", e.Categories)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, RSS, testFeed()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/">`,
+		`<atom:link rel="self" href="https://books.example.com/feed/books?format=rss" type="application/rss+xml">`,
+		`<lastBuildDate>Wed, 01 May 2024 13:30:00 +0000</lastBuildDate>`,
+		`<title>Animal Farm &amp; &lt;Co&gt;</title>`,
+		`<guid isPermaLink="false">urn:isbn:978-0-451-52493-5</guid>`,
+		`<dc:creator>Terry Pratchett</dc:creator>`,
+		`<dc:creator>Neil Gaiman</dc:creator>`,
+		`<category>Satire</category>`,
+		`<pubDate>Wed, 01 May 2024 12:30:00 +0000</pubDate>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("RSS = %s\nwant %s", buf.String(), want)
+		}
+	}
+
+	// an entry without authors has no creator
+	f := testFeed()
+	f.Entries[0].Authors = nil
+	buf.Reset()
+	if err := Write(&buf, RSS, f); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "dc:creator") {
+		t.Errorf("RSS without authors = %s", buf.String())
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "json", testFeed()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `<feed xmlns="http://www.w3.org/2005/Atom">`) {
+		t.Errorf("Write of an unknown format = %s, want Atom", buf.String())
+	}
+}
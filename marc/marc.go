@@ -0,0 +1,515 @@
+// Package marc reads and writes bibliographic records as MARC21 binary
+// (ISO 2709) and MARCXML, and maps them to and from models.Book:
+//
+//	020 $a ISBN, $c price
+//	100 $a author
+//	700 $a contributor, $e relator term or $4 relator code for the role
+//	245 $a title
+//	520 $a summary (descriptions)
+//	650 $a subjects, the first one is the genre
+//
+// Personal names of 100 and 700 entered surname first (first indicator 1),
+// "Orwell, George,", are credited as "George Orwell".
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"test.iamgak.net/models"
+)
+
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+	leaderLength      = 24
+	directoryEntry    = 12
+
+	// new record, language material, monograph, UTF-8, ISBD
+	defaultLeader = "00000nam a2200000 i 4500"
+
+	Namespace = "http://www.loc.gov/MARC21/slim"
+)
+
+var ErrRecord = errors.New("marc: malformed record")
+
+type Record struct {
+	XMLName  xml.Name       `xml:"record"`
+	Leader   string         `xml:"leader"`
+	Controls []ControlField `xml:"controlfield"`
+	Fields   []DataField    `xml:"datafield"`
+}
+
+type ControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type DataField struct {
+	Tag       string     `xml:"tag,attr"`
+	Ind1      string     `xml:"ind1,attr"`
+	Ind2      string     `xml:"ind2,attr"`
+	Subfields []Subfield `xml:"subfield"`
+}
+
+type Subfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// relator terms and codes of models.ContributorRoles
+var relators = map[string]string{
+	"author":      models.RoleAuthor,
+	"aut":         models.RoleAuthor,
+	"editor":      models.RoleEditor,
+	"edt":         models.RoleEditor,
+	"translator":  models.RoleTranslator,
+	"trl":         models.RoleTranslator,
+	"illustrator": models.RoleIllustrator,
+	"ill":         models.RoleIllustrator,
+}
+
+// first value of the subfield
+func (f *DataField) Value(code string) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return sf.Value
+		}
+	}
+
+	return ""
+}
+
+type collection struct {
+	XMLName xml.Name  `xml:"http://www.loc.gov/MARC21/slim collection"`
+	Records []*Record `xml:"record"`
+}
+
+// first value of the subfield in the first field with the tag
+func (r *Record) Value(tag, code string) string {
+	values := r.Values(tag, code)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// every value of the subfield across the fields with the tag
+func (r *Record) Values(tag, code string) []string {
+	values := []string{}
+	for _, f := range r.Fields {
+		if f.Tag != tag {
+			continue
+		}
+		for _, sf := range f.Subfields {
+			if sf.Code == code {
+				values = append(values, sf.Value)
+			}
+		}
+	}
+
+	return values
+}
+
+// Book maps the record, subjects holds every 650 $a
+func (r *Record) Book() (book *models.Book, subjects []string) {
+	book = &models.Book{
+		ISBN:         firstWord(r.Value("020", "a")),
+		Title:        trimPunct(r.Value("245", "a")),
+		Descriptions: strings.TrimSpace(r.Value("520", "a")),
+	}
+
+	price := strings.TrimLeft(r.Value("020", "c"), "$£€ABCDEFGHIJKLMNOPQRSTUVWXYZ ")
+	if p, err := strconv.ParseFloat(firstWord(price), 32); err == nil {
+		book.Price = float32(p)
+	}
+
+	subjects = []string{}
+	for _, s := range r.Values("650", "a") {
+		if s = trimPunct(s); s != "" {
+			subjects = append(subjects, s)
+		}
+	}
+	if len(subjects) > 0 {
+		book.Genre = subjects[0]
+	}
+
+	book.Contributors = r.contributors()
+	authors := []string{}
+	for _, c := range book.Contributors {
+		if c.Role == models.RoleAuthor {
+			authors = append(authors, c.Name)
+		}
+	}
+
+	// Book.Author is read as a comma separated list, a name that keeps a
+	// comma ("King, Martin Luther, Jr.") leaves only the main entry there
+	book.Author = strings.Join(authors, ", ")
+	for _, name := range authors {
+		if strings.Contains(name, ",") {
+			book.Author = authors[0]
+			break
+		}
+	}
+
+	return book, subjects
+}
+
+// the 100 main entry and the 700 added entries
+func (r *Record) contributors() []*models.Contributor {
+	credits := []*models.Contributor{}
+	for _, f := range r.Fields {
+		if f.Tag == "100" {
+			if name := personalName(&f); name != "" {
+				credits = append(credits, &models.Contributor{Name: name, Role: models.RoleAuthor})
+			}
+			break
+		}
+	}
+
+	for _, f := range r.Fields {
+		if f.Tag != "700" {
+			continue
+		}
+
+		name := personalName(&f)
+		if name == "" {
+			continue
+		}
+
+		role, ok := relators[strings.ToLower(trimPunct(f.Value("e")))]
+		if !ok {
+			role, ok = relators[strings.TrimSpace(f.Value("4"))]
+		}
+		if !ok {
+			role = models.RoleAuthor
+		}
+		credits = append(credits, &models.Contributor{Name: name, Role: role})
+	}
+
+	return credits
+}
+
+// $a of a 100 or 700, "Orwell, George," is "George Orwell" when the first
+// indicator says surname first. Names with more than one comma stay as they
+// are, the order of their parts is not known.
+func personalName(f *DataField) string {
+	name := trimPunct(f.Value("a"))
+	if f.Ind1 != "1" {
+		return name
+	}
+
+	surname, forenames, ok := strings.Cut(name, ",")
+	if !ok || strings.Contains(forenames, ",") {
+		return name
+	}
+
+	surname, forenames = strings.TrimSpace(surname), strings.TrimSpace(forenames)
+	if surname == "" || forenames == "" {
+		return name
+	}
+
+	return forenames + " " + surname
+}
+
+// FromBook builds a minimal bibliographic record for the book
+func FromBook(book *models.Book) *Record {
+	isbn := []Subfield{{Code: "a", Value: book.ISBN}}
+	if book.Price > 0 {
+		isbn = append(isbn, Subfield{Code: "c", Value: strconv.FormatFloat(float64(book.Price), 'f', 2, 32)})
+	}
+
+	r := &Record{
+		Leader:   defaultLeader,
+		Controls: []ControlField{{Tag: "001", Value: book.ISBN}},
+		Fields:   []DataField{{Tag: "020", Ind1: " ", Ind2: " ", Subfields: isbn}},
+	}
+
+	// the first credited author is the main entry, everyone else an added entry
+	main := book.Author
+	added := []*models.Contributor{}
+	for i, c := range book.Contributors {
+		if c.Role == models.RoleAuthor {
+			main = c.Name
+			added = append(added, book.Contributors[i+1:]...)
+			break
+		}
+		added = append(added, c)
+	}
+
+	if main != "" {
+		r.Fields = append(r.Fields, DataField{Tag: "100", Ind1: nameIndicator(main), Ind2: " ", Subfields: []Subfield{{Code: "a", Value: main}}})
+	}
+
+	// 1: title added entry when there is an author, 0: no nonfiling characters
+	ind1 := "0"
+	if book.Author != "" {
+		ind1 = "1"
+	}
+	r.Fields = append(r.Fields, DataField{Tag: "245", Ind1: ind1, Ind2: "0", Subfields: []Subfield{{Code: "a", Value: book.Title}}})
+
+	if book.Descriptions != "" {
+		r.Fields = append(r.Fields, DataField{Tag: "520", Ind1: " ", Ind2: " ", Subfields: []Subfield{{Code: "a", Value: book.Descriptions}}})
+	}
+
+	// 4: source not specified, our genres are not LCSH
+	if book.Genre != "" {
+		r.Fields = append(r.Fields, DataField{Tag: "650", Ind1: " ", Ind2: "4", Subfields: []Subfield{{Code: "a", Value: book.Genre}}})
+	}
+
+	for _, c := range added {
+		r.Fields = append(r.Fields, DataField{Tag: "700", Ind1: nameIndicator(c.Name), Ind2: " ", Subfields: []Subfield{{Code: "a", Value: c.Name}, {Code: "e", Value: c.Role}}})
+	}
+
+	return r
+}
+
+// first indicator of a 100 or 700, credits are kept in direct order ("George
+// Orwell", 0) unless they were stored surname first (1)
+func nameIndicator(name string) string {
+	if strings.Contains(name, ",") {
+		return "1"
+	}
+
+	return "0"
+}
+
+// ReadBinary reads every ISO 2709 record of the stream
+func ReadBinary(r io.Reader) ([]*Record, error) {
+	reader := bufio.NewReader(r)
+	records := []*Record{}
+	for {
+		raw, err := reader.ReadBytes(recordTerminator)
+		if err == io.EOF {
+			if len(bytes.TrimSpace(raw)) > 0 {
+				return nil, fmt.Errorf("%w: record %d is not terminated", ErrRecord, len(records)+1)
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// some files put a newline between records
+		raw = bytes.TrimLeft(raw, "\r\n")
+		rec, err := decodeBinary(raw)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+func decodeBinary(raw []byte) (*Record, error) {
+	if len(raw) < leaderLength+1 {
+		return nil, ErrRecord
+	}
+
+	rec := &Record{Leader: string(raw[:leaderLength])}
+	base, ok := number(raw[12:17])
+	if !ok || base <= leaderLength || base > len(raw) {
+		return nil, fmt.Errorf("%w: bad base address", ErrRecord)
+	}
+
+	dir := raw[leaderLength : base-1]
+	if len(dir)%directoryEntry != 0 {
+		return nil, fmt.Errorf("%w: bad directory", ErrRecord)
+	}
+
+	for i := 0; i < len(dir); i += directoryEntry {
+		entry := dir[i : i+directoryEntry]
+		tag := string(entry[:3])
+		length, ok1 := number(entry[3:7])
+		start, ok2 := number(entry[7:12])
+		if !ok1 || !ok2 || length < 1 || base+start+length > len(raw) {
+			return nil, fmt.Errorf("%w: bad directory entry for %s", ErrRecord, tag)
+		}
+
+		// drop the field terminator
+		data := raw[base+start : base+start+length-1]
+		if strings.HasPrefix(tag, "00") {
+			rec.Controls = append(rec.Controls, ControlField{Tag: tag, Value: string(data)})
+			continue
+		}
+
+		if len(data) < 2 {
+			return nil, fmt.Errorf("%w: field %s has no indicators", ErrRecord, tag)
+		}
+
+		field := DataField{Tag: tag, Ind1: string(data[0]), Ind2: string(data[1])}
+		for _, sf := range bytes.Split(data[2:], []byte{subfieldDelimiter}) {
+			if len(sf) == 0 {
+				continue
+			}
+			field.Subfields = append(field.Subfields, Subfield{Code: string(sf[0]), Value: string(sf[1:])})
+		}
+		rec.Fields = append(rec.Fields, field)
+	}
+
+	return rec, nil
+}
+
+// the digits 0-9 of a leader or directory number, no sign or spaces
+func number(digits []byte) (int, bool) {
+	n := 0
+	for _, d := range digits {
+		if d < '0' || d > '9' {
+			return 0, false
+		}
+		n = n*10 + int(d-'0')
+	}
+
+	return n, len(digits) > 0
+}
+
+// WriteBinary writes the records as ISO 2709, lengths and the base address
+// of the leader are computed
+func WriteBinary(w io.Writer, records []*Record) error {
+	for _, rec := range records {
+		raw, err := encodeBinary(rec)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeBinary(rec *Record) ([]byte, error) {
+	var dir, data bytes.Buffer
+	add := func(tag string, field []byte) error {
+		if len(tag) != 3 {
+			return fmt.Errorf("%w: tag %q", ErrRecord, tag)
+		}
+		field = append(field, fieldTerminator)
+		if len(field) > 9999 || data.Len() > 99999 {
+			return fmt.Errorf("%w: field %s is too long", ErrRecord, tag)
+		}
+		fmt.Fprintf(&dir, "%s%04d%05d", tag, len(field), data.Len())
+		data.Write(field)
+		return nil
+	}
+
+	for _, cf := range rec.Controls {
+		if err := add(cf.Tag, []byte(cf.Value)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, df := range rec.Fields {
+		field := []byte{indicator(df.Ind1), indicator(df.Ind2)}
+		for _, sf := range df.Subfields {
+			if len(sf.Code) != 1 {
+				return nil, fmt.Errorf("%w: subfield code %q in %s", ErrRecord, sf.Code, df.Tag)
+			}
+			field = append(field, subfieldDelimiter, sf.Code[0])
+			field = append(field, sf.Value...)
+		}
+		if err := add(df.Tag, field); err != nil {
+			return nil, err
+		}
+	}
+
+	dir.WriteByte(fieldTerminator)
+	base := leaderLength + dir.Len()
+	length := base + data.Len() + 1
+	if length > 99999 {
+		return nil, fmt.Errorf("%w: record is too long", ErrRecord)
+	}
+
+	leader := []byte(rec.Leader)
+	if len(leader) != leaderLength {
+		leader = []byte(defaultLeader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+
+	out := make([]byte, 0, length)
+	out = append(out, leader...)
+	out = append(out, dir.Bytes()...)
+	out = append(out, data.Bytes()...)
+	out = append(out, recordTerminator)
+
+	return out, nil
+}
+
+// ReadXML reads a MARCXML <collection> or a single <record>
+func ReadXML(r io.Reader) ([]*Record, error) {
+	dec := xml.NewDecoder(r)
+	records := []*Record{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("marc: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		rec := &Record{}
+		if err := dec.DecodeElement(rec, &start); err != nil {
+			return nil, fmt.Errorf("marc: record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+// WriteXML writes the records as a MARCXML collection
+func WriteXML(w io.Writer, records []*Record) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(collection{Records: records}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func indicator(ind string) byte {
+	if ind == "" {
+		return ' '
+	}
+
+	return ind[0]
+}
+
+// ISBD punctuation at the end of 100 and 245 values, like "Orwell, George,"
+func trimPunct(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,="))
+}
+
+// 020 $a may carry a qualifier, "9780451524935 (pbk.)"
+func firstWord(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return fields[0]
+}
@@ -0,0 +1,196 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+func testRecord() *Record {
+	return &Record{
+		Leader:   defaultLeader,
+		Controls: []ControlField{{Tag: "001", Value: "9780451524935"}},
+		Fields: []DataField{
+			{Tag: "020", Ind1: " ", Ind2: " ", Subfields: []Subfield{{Code: "a", Value: "9780451524935 (pbk.)"}, {Code: "c", Value: "$9.99"}}},
+			{Tag: "100", Ind1: "1", Ind2: " ", Subfields: []Subfield{{Code: "a", Value: "Orwell, George,"}}},
+			{Tag: "245", Ind1: "1", Ind2: "0", Subfields: []Subfield{{Code: "a", Value: "Animal farm /"}}},
+			{Tag: "650", Ind1: " ", Ind2: "0", Subfields: []Subfield{{Code: "a", Value: "Political satire."}}},
+		},
+	}
+}
+
+func encode(t *testing.T, rec *Record) []byte {
+	t.Helper()
+	raw, err := encodeBinary(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+func TestReadBinaryMalformed(t *testing.T) {
+	valid := encode(t, testRecord())
+	// the first directory entry starts after the leader: tag, length, start
+	entry := leaderLength
+	tests := []struct {
+		name string
+		edit func(raw []byte) []byte
+	}{
+		{"negative start", func(raw []byte) []byte { copy(raw[entry+7:], "-9999"); return raw }},
+		{"negative length", func(raw []byte) []byte { copy(raw[entry+3:], "-001"); return raw }},
+		{"zero length", func(raw []byte) []byte { copy(raw[entry+3:], "0000"); return raw }},
+		{"start past the end", func(raw []byte) []byte { copy(raw[entry+7:], "99999"); return raw }},
+		{"signed start", func(raw []byte) []byte { copy(raw[entry+7:], "+0000"); return raw }},
+		{"spaces in length", func(raw []byte) []byte { copy(raw[entry+3:], " 012"); return raw }},
+		{"negative base", func(raw []byte) []byte { copy(raw[12:], "-0001"); return raw }},
+		{"base past the end", func(raw []byte) []byte { copy(raw[12:], "99999"); return raw }},
+		{"short directory", func(raw []byte) []byte { return append(raw[:entry+5], recordTerminator) }},
+		{"too short", func(raw []byte) []byte { return []byte("00010nam\x1d") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := tt.edit(bytes.Clone(valid))
+			_, err := ReadBinary(bytes.NewReader(raw))
+			if !errors.Is(err, ErrRecord) {
+				t.Errorf("ReadBinary = %v, want ErrRecord", err)
+			}
+		})
+	}
+
+	if _, err := ReadBinary(bytes.NewReader(valid[:len(valid)-1])); !errors.Is(err, ErrRecord) {
+		t.Errorf("ReadBinary of an unterminated record = %v, want ErrRecord", err)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	// a newline between records is accepted
+	raw := encode(t, testRecord())
+	buf.Write(raw)
+	buf.WriteString("\n")
+	buf.Write(raw)
+
+	records, err := ReadBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("ReadBinary = %d records, want 2", len(records))
+	}
+	if got := records[1].Value("245", "a"); got != "Animal farm /" {
+		t.Errorf("245 $a = %q", got)
+	}
+	if len(records[0].Controls) != 1 || records[0].Controls[0].Value != "9780451524935" {
+		t.Errorf("controls = %+v", records[0].Controls)
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteXML(&buf, []*Record{testRecord()}); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ReadXML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Value("100", "a") != "Orwell, George," {
+		t.Errorf("ReadXML = %+v, want the record back", records)
+	}
+}
+
+func TestRecordBook(t *testing.T) {
+	tests := []struct {
+		name    string
+		added   []DataField
+		author  string
+		credits []models.Contributor
+	}{
+		{
+			name:    "main entry only",
+			author:  "George Orwell",
+			credits: []models.Contributor{{Name: "George Orwell", Role: models.RoleAuthor}},
+		},
+		{
+			name: "added entries",
+			added: []DataField{
+				{Tag: "700", Ind1: "1", Subfields: []Subfield{{Code: "a", Value: "Huxley, Aldous,"}, {Code: "e", Value: "author."}}},
+				{Tag: "700", Ind1: "1", Subfields: []Subfield{{Code: "a", Value: "Atwood, Margaret,"}, {Code: "4", Value: "edt"}}},
+			},
+			author: "George Orwell, Aldous Huxley",
+			credits: []models.Contributor{
+				{Name: "George Orwell", Role: models.RoleAuthor},
+				{Name: "Aldous Huxley", Role: models.RoleAuthor},
+				{Name: "Margaret Atwood", Role: models.RoleEditor},
+			},
+		},
+		{
+			name: "name with two commas",
+			added: []DataField{
+				{Tag: "700", Ind1: "1", Subfields: []Subfield{{Code: "a", Value: "King, Martin Luther, Jr.,"}}},
+			},
+			author: "George Orwell",
+			credits: []models.Contributor{
+				{Name: "George Orwell", Role: models.RoleAuthor},
+				{Name: "King, Martin Luther, Jr.", Role: models.RoleAuthor},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := testRecord()
+			rec.Fields = append(rec.Fields, tt.added...)
+			book, subjects := rec.Book()
+
+			if book.ISBN != "9780451524935" || book.Title != "Animal farm" || book.Price != 9.99 || book.Genre != "Political satire." {
+				t.Errorf("Book = %+v", book)
+			}
+			if len(subjects) != 1 {
+				t.Errorf("subjects = %v, want one", subjects)
+			}
+			if book.Author != tt.author {
+				t.Errorf("Author = %q, want %q", book.Author, tt.author)
+			}
+			if len(book.Contributors) != len(tt.credits) {
+				t.Fatalf("Contributors = %d, want %d", len(book.Contributors), len(tt.credits))
+			}
+			for i, c := range book.Contributors {
+				if *c != tt.credits[i] {
+					t.Errorf("Contributors[%d] = %+v, want %+v", i, *c, tt.credits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFromBook(t *testing.T) {
+	book := &models.Book{
+		ISBN:   "9780451524935",
+		Title:  "Animal Farm",
+		Author: "George Orwell",
+		Price:  9.99,
+		Contributors: []*models.Contributor{
+			{Name: "George Orwell", Role: models.RoleAuthor},
+			{Name: "Atwood, Margaret", Role: models.RoleEditor},
+		},
+	}
+
+	rec := FromBook(book)
+	back, _ := rec.Book()
+	if back.ISBN != book.ISBN || back.Title != book.Title || back.Price != book.Price || back.Author != "George Orwell" {
+		t.Errorf("Book of FromBook = %+v", back)
+	}
+	if len(back.Contributors) != 2 || back.Contributors[1].Name != "Margaret Atwood" || back.Contributors[1].Role != models.RoleEditor {
+		t.Errorf("Contributors of FromBook = %v", back.Contributors)
+	}
+	if !strings.HasPrefix(string(encode(t, rec)[5:]), "nam") {
+		t.Errorf("leader of FromBook is not the default one")
+	}
+}
@@ -0,0 +1,309 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// contributor roles of book_authors.role
+const (
+	RoleAuthor      = "author"
+	RoleEditor      = "editor"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+)
+
+var ContributorRoles = []string{RoleAuthor, RoleEditor, RoleTranslator, RoleIllustrator}
+
+// ValidRole reports whether role is one of ContributorRoles
+func ValidRole(role string) bool {
+	for _, r := range ContributorRoles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// a person (or body) credited on a book
+type Contributor struct {
+	AuthorID int64  `json:"author_id,omitempty"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+}
+
+type Author struct {
+	ID      int64         `json:"id"`
+	Name    string        `json:"name"`
+	Bio     string        `json:"bio"`
+	Aliases []string      `json:"aliases"`
+	Books   []*AuthorBook `json:"books,omitempty"`
+}
+
+// bibliography entry, the same book shows up once per role
+type AuthorBook struct {
+	*Book
+	Role string `json:"role"`
+}
+
+type AuthorModel struct {
+	db *DB
+}
+
+// *DB or *Tx
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	// id of the row the INSERT query adds
+	insertID(ctx context.Context, query string, args ...any) (int64, error)
+}
+
+// SplitAuthors splits the Book.Author text, one name or a comma separated list
+func SplitAuthors(author string) []string {
+	names := []string{}
+	for _, name := range strings.Split(author, ",") {
+		if name = strings.Join(strings.Fields(name), " "); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Credits of the book, the names of Book.Author as authors when no
+// Contributors are given
+func (book *Book) Credits() []*Contributor {
+	if len(book.Contributors) > 0 {
+		return book.Contributors
+	}
+
+	credits := []*Contributor{}
+	for _, name := range SplitAuthors(book.Author) {
+		credits = append(credits, &Contributor{Name: name, Role: RoleAuthor})
+	}
+
+	return credits
+}
+
+// CreditedAs are the names of the book credited with role, in credit order
+func (book *Book) CreditedAs(role string) []string {
+	names := []string{}
+	for _, c := range book.Credits() {
+		if c.Role == role {
+			names = append(names, c.Name)
+		}
+	}
+
+	return names
+}
+
+// author of the name or one of its aliases, created when unknown
+func resolveAuthor(ctx context.Context, q queryer, name string) (int64, error) {
+	name = strings.Join(strings.Fields(name), " ")
+
+	var id int64
+	err := q.QueryRowContext(ctx, "SELECT `id` FROM `authors` WHERE `name` = ? UNION SELECT `author_id` FROM `author_aliases` WHERE `alias` = ? LIMIT 1", name, name).Scan(&id)
+	if err != sql.ErrNoRows {
+		return id, err
+	}
+
+	return q.insertID(ctx, "INSERT INTO `authors` (`name`) VALUES (?)", name)
+}
+
+// replace the credits of the book
+func setContributors(ctx context.Context, q queryer, ISBN string, contributors []*Contributor) error {
+	_, err := q.ExecContext(ctx, "DELETE FROM `book_authors` WHERE `isbn` = ?", ISBN)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range contributors {
+		id, err := resolveAuthor(ctx, q, c.Name)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.ExecContext(ctx, "INSERT IGNORE INTO `book_authors` (`isbn`,`author_id`,`role`,`position`) VALUES (?,?,?,?)", ISBN, id, c.Role, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// credits of the book in order
+func (m *BookModel) Contributors(ctx context.Context, ISBN string) ([]*Contributor, error) {
+	stmt := "SELECT a.`id`, a.`name`, ba.`role` FROM `book_authors` ba JOIN `authors` a ON a.`id` = ba.`author_id` WHERE ba.`isbn` = ? ORDER BY ba.`position`"
+	rows, err := m.db.QueryContext(ctx, stmt, ISBN)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contributors := []*Contributor{}
+	for rows.Next() {
+		c := &Contributor{}
+		if err := rows.Scan(&c.AuthorID, &c.Name, &c.Role); err != nil {
+			return nil, err
+		}
+		contributors = append(contributors, c)
+	}
+
+	return contributors, rows.Err()
+}
+
+// author with aliases, ErrNoRecord if id is unknown
+func (m *AuthorModel) GetAuthor(ctx context.Context, id int64) (*Author, error) {
+	author := &Author{Aliases: []string{}}
+	var bio sql.NullString
+	err := m.db.QueryRowContext(ctx, "SELECT `id`, `name`, `bio` FROM `authors` WHERE `id` = ?", id).Scan(&author.ID, &author.Name, &bio)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	author.Bio = bio.String
+
+	aliases, err := m.db.QueryContext(ctx, "SELECT `alias` FROM `author_aliases` WHERE `author_id` = ? ORDER BY `alias`", id)
+	if err != nil {
+		return nil, err
+	}
+	defer aliases.Close()
+
+	for aliases.Next() {
+		var alias string
+		if err := aliases.Scan(&alias); err != nil {
+			return nil, err
+		}
+		author.Aliases = append(author.Aliases, alias)
+	}
+	if err := aliases.Err(); err != nil {
+		return nil, err
+	}
+
+	return author, nil
+}
+
+// bibliography of the author, ordered by title
+func (m *BookModel) AuthorBooks(ctx context.Context, authorID int64) ([]*AuthorBook, error) {
+	stmt := "SELECT " + bookColumns + ", ba.`role` FROM `books` JOIN `book_authors` ba USING (`isbn`) WHERE ba.`author_id` = ? ORDER BY `title`, `isbn`"
+	rows, err := m.db.QueryContext(ctx, stmt, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*AuthorBook{}
+	for rows.Next() {
+		entry := &AuthorBook{Book: &Book{}}
+		if err := m.scanBook(rows, entry.Book, &entry.Role); err != nil {
+			return nil, err
+		}
+		books = append(books, entry)
+	}
+
+	return books, rows.Err()
+}
+
+// change name and bio, the aliases are replaced
+func (m *AuthorModel) UpdateAuthor(ctx context.Context, author *Author) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback if we encounter an error before commit
+
+	var exist int
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM `authors` WHERE `id` = ?", author.ID).Scan(&exist)
+	if err == sql.ErrNoRows {
+		return ErrNoRecord
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE `authors` SET `name` = ?, `bio` = ? WHERE `id` = ?", author.Name, author.Bio, author.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM `author_aliases` WHERE `author_id` = ?", author.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range author.Aliases {
+		_, err = tx.ExecContext(ctx, "INSERT INTO `author_aliases` (`author_id`,`alias`) VALUES (?,?)", author.ID, alias)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MergeAuthors moves the books and aliases of from to into, the names of
+// from become aliases of into and the from authors are removed
+func (m *AuthorModel) MergeAuthors(ctx context.Context, into int64, from []int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback if we encounter an error before commit
+
+	var exist int
+	err = tx.QueryRowContext(ctx, "SELECT 1 FROM `authors` WHERE `id` = ?", into).Scan(&exist)
+	if err == sql.ErrNoRows {
+		return ErrNoRecord
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, id := range from {
+		if id == into {
+			continue
+		}
+
+		var name string
+		err := tx.QueryRowContext(ctx, "SELECT `name` FROM `authors` WHERE `id` = ?", id).Scan(&name)
+		if err == sql.ErrNoRows {
+			return ErrNoRecord
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT IGNORE INTO `book_authors` (`isbn`,`author_id`,`role`,`position`) SELECT ba.`isbn`, a.`id`, ba.`role`, ba.`position` FROM `book_authors` ba JOIN `authors` a ON a.`id` = ? WHERE ba.`author_id` = ?", into, id)
+		if err != nil {
+			return err
+		}
+
+		// aliases are unique, moving them can not clash
+		_, err = tx.ExecContext(ctx, "UPDATE `author_aliases` SET `author_id` = ? WHERE `author_id` = ?", into, id)
+		if err != nil {
+			return err
+		}
+
+		for _, stmt := range []string{
+			"DELETE FROM `book_authors` WHERE `author_id` = ?",
+			"DELETE FROM `author_aliases` WHERE `author_id` = ?",
+			"DELETE FROM `authors` WHERE `id` = ?",
+		} {
+			if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT IGNORE INTO `author_aliases` (`author_id`,`alias`) VALUES (?,?)", into, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -3,11 +3,9 @@ package models
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
-	"fmt"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"test.iamgak.net/cache"
 )
 
 type Book struct {
@@ -17,110 +15,411 @@ type Book struct {
 	Price        float32 `json:"price"`
 	Descriptions string  `json:"descriptions"`
 	Genre        string  `json:"genre"`
+	// storage key of the cover image, empty if the book has none
+	Cover string `json:"-"`
+	// url of the original cover and of every thumbnail size
+	Covers map[string]string `json:"covers,omitempty"`
+	// authors, editors, translators and illustrators in credit order, the
+	// names of Author are credited as authors when empty
+	Contributors []*Contributor `json:"contributors,omitempty"`
+	// every genre the book is filed under, Genre is the main one and the
+	// only one when empty
+	Genres []*BookGenre `json:"genres,omitempty"`
+	// every edition (format, language) of a work shares the work
+	WorkID   int64  `json:"work_id,omitempty"`
+	Format   string `json:"format,omitempty"`
+	Language string `json:"language,omitempty"`
+	// other editions of the work and the place of the work in its series
+	Editions []*Book      `json:"editions,omitempty"`
+	Series   *SeriesPlace `json:"series,omitempty"`
+	// publisher whose members manage the book, 0 when it has none
+	PublisherID int64 `json:"publisher_id,omitempty"`
 }
 
-func (m *BookModel) Close() {
-	m.cancel()
-	m.redis.Close()
-	m.db.Close()
+// columns read by ScanBookData, in order
+const bookColumns = "`isbn`,`title`,`author`,`price`,`descriptions`,`genre`,`cover`,`work_id`,`format`,`language`,`publisher_id`"
+
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
 type BookModel struct {
-	db     *sql.DB
-	redis  *redis.Client
-	ctx    context.Context
-	cancel context.CancelFunc
+	db        *DB
+	cache     *cache.Loader
+	coverURLs func(cover string) map[string]string
+}
+
+// fills Book.Covers of every book read from the db
+func (m *BookModel) SetCoverURLs(fn func(cover string) map[string]string) {
+	m.coverURLs = fn
+}
+
+// credits, genres and work of a book just written
+func saveRelations(ctx context.Context, q queryer, book *Book) error {
+	if err := setContributors(ctx, q, book.ISBN, book.Credits()); err != nil {
+		return err
+	}
+
+	if err := setGenres(ctx, q, book.ISBN, book.genres()); err != nil {
+		return err
+	}
+
+	return setWork(ctx, q, book)
+}
+
+// add books in db, a book without WorkID starts a work of its own, see
+// claimBook for the publisher it is listed under
+func (m *BookModel) CreateBook(ctx context.Context, book *Book, by *Editor) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback if we encounter an error before commit
+
+	if err := claimBook(ctx, tx, by, book, false); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO `books` (`isbn`,`title`,`author`,`price`,`descriptions`,`genre`,`cover`,`format`,`language`,`publisher_id`) VALUES (?,?,?,?,?,?,NULLIF(?, ''),NULLIF(?, ''),NULLIF(?, ''),NULLIF(?, 0))", &book.ISBN, &book.Title, &book.Author, &book.Price, &book.Descriptions, &book.Genre, &book.Cover, &book.Format, &book.Language, &book.PublisherID)
+	if err != nil {
+		return err
+	}
+
+	if err := saveRelations(ctx, tx, book); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.cache.Invalidate(ctx, TagBooks)
+	return nil
 }
 
-// add books in db
-func (m *BookModel) CreateBook(book *Book) error {
-	_, err := m.db.Exec("INSERT INTO `books` (`isbn`,`title`,`author`,`price`,`descriptions`,`genre`) VALUES (?,?,?,?,?,? )", &book.ISBN, &book.Title, &book.Author, &book.Price, &book.Descriptions, &book.Genre)
-	return err
+// change every field of the book except the isbn, the cover only when
+// book.Cover is set, the book stays in its work when WorkID is 0 and with its
+// publisher when PublisherID is 0, ErrNoRecord if isbn is unknown, ErrNotOwner unless by may write the book
+func (m *BookModel) UpdateBook(ctx context.Context, book *Book, by *Editor) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback if we encounter an error before commit
+
+	if err := claimBook(ctx, tx, by, book, true); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE `books` SET `title` = ?, `author` = ?, `price` = ?, `descriptions` = ?, `genre` = ?, `format` = NULLIF(?, ''), `language` = NULLIF(?, ''), `publisher_id` = NULLIF(?, 0), `cover` = COALESCE(NULLIF(?, ''), `cover`) WHERE `isbn` = ?", book.Title, book.Author, book.Price, book.Descriptions, book.Genre, book.Format, book.Language, book.PublisherID, book.Cover, book.ISBN)
+	if err != nil {
+		return err
+	}
+
+	if err := saveRelations(ctx, tx, book); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.cache.Invalidate(ctx, TagBooks)
+	return nil
 }
 
 // check isbn already exist or not()
-func (m *BookModel) BookExist(ISBN string) bool {
-	var valid int
-	_ = m.db.QueryRow("SELECT 1 FROM `books` WHERE  `isbn` = ?", ISBN).Scan(&valid)
-	return valid > 0
+func (m *BookModel) BookExist(ctx context.Context, ISBN string) (bool, error) {
+	return exists(ctx, m.db, "SELECT 1 FROM `books` WHERE  `isbn` = ?", ISBN)
+}
+
+// single book without the redis cache, ErrNoRecord if isbn is unknown
+func (m *BookModel) FindBook(ctx context.Context, ISBN string) (*Book, error) {
+	book := &Book{}
+	err := m.ScanBookData(m.db.QueryRowContext(ctx, "SELECT "+bookColumns+" FROM `books` WHERE `isbn` = ?", ISBN), book)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return book, nil
 }
 
-func (m *BookModel) GetBookByIsbn(ISBN string) ([]*Book, error) {
-	stmt := fmt.Sprintf("SELECT `isbn`,`title`,`author`,`price`,`descriptions`,`genre` FROM `books` WHERE `ISBN` = '%s' ", ISBN)
-	bk, err := m.Listing(stmt)
-	return bk, err
+// remove a book from the catalog, ErrNoRecord if isbn is unknown, ErrNotOwner
+// unless by may write the book
+func (m *BookModel) DeleteBook(ctx context.Context, ISBN string, by *Editor) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback if we encounter an error before commit
+
+	if err := checkOwner(ctx, tx, by, ISBN); err != nil {
+		return err
+	}
+
+	// the relations first, a failure leaves the book as it was
+	for _, stmt := range []string{
+		"DELETE FROM `book_authors` WHERE `isbn` = ?",
+		"DELETE FROM `book_genres` WHERE `isbn` = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, ISBN); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM `books` WHERE `isbn` = ?", ISBN)
+	if err := affected(result, err, ErrNoRecord); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	m.cache.Invalidate(ctx, TagBooks)
+	return nil
 }
 
-func (m *BookModel) BooksListing() ([]*Book, error) {
-	stmt := "SELECT `isbn`,`title`,`author`,`price`,`descriptions`,`genre` FROM books"
-	Books, err := m.Listing(stmt)
-	return Books, err
+// the book of isbn from the cache, ErrNoRecord if isbn is unknown
+func (m *BookModel) GetBookByIsbn(ctx context.Context, ISBN string) ([]*Book, error) {
+	stmt := "SELECT " + bookColumns + " FROM `books` WHERE `isbn` = ?"
+	return cached(ctx, m.cache, "book:"+ISBN, bookCacheTTL, []string{TagBooks}, func(ctx context.Context) ([]*Book, error) {
+		books, err := m.scanBooks(ctx, stmt, ISBN)
+		if err == nil && len(books) == 0 {
+			// cached as unknown for a while
+			return nil, ErrNoRecord
+		}
+		return books, err
+	})
 }
 
-func (m *BookModel) Listing(stmt string) ([]*Book, error) {
+// every book from the cache
+func (m *BookModel) BooksListing(ctx context.Context) ([]*Book, error) {
+	stmt := "SELECT " + bookColumns + " FROM books"
+	return cached(ctx, m.cache, "books", bookCacheTTL, []string{TagBooks}, func(ctx context.Context) ([]*Book, error) {
+		return m.scanBooks(ctx, stmt)
+	})
+}
 
-	Books := []*Book{}
-	queryBytes, err := json.Marshal(stmt)
+// books the user wrote a (not deleted) review for
+func (m *BookModel) ReviewedBooks(ctx context.Context, uid int64) ([]*Book, error) {
+	stmt := "SELECT " + bookColumns + " FROM `books` WHERE `isbn` IN (SELECT `isbn` FROM `reviews` WHERE `uid` = ? AND `is_deleted` = 0) ORDER BY `title`"
+	rows, err := m.db.QueryContext(ctx, stmt, uid)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	val, err := m.redis.Get(m.ctx, string(queryBytes)).Result()
-	if err == nil {
-		// Deserialize the cached result
-		err = json.Unmarshal([]byte(val), &Books)
-		if err != nil {
+	defer rows.Close()
+
+	books := []*Book{}
+	for rows.Next() {
+		book := new(Book)
+		if err := m.ScanBookData(rows, book); err != nil {
 			return nil, err
 		}
 
-		return Books, err
-	} else if err != redis.Nil {
-		return nil, err
+		books = append(books, book)
 	}
 
-	rows, err := m.db.Query(stmt)
+	return books, rows.Err()
+}
+
+// isbn and last change of a book, for the sitemap
+type BookStamp struct {
+	ISBN      string
+	UpdatedAt time.Time
+}
+
+// every book with its last change, newest first, capped at the 50000 urls a sitemap may list
+func (m *BookModel) BookStamps(ctx context.Context) ([]*BookStamp, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT `isbn`, `updated_at` FROM `books` ORDER BY `updated_at` DESC LIMIT 50000")
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		} else {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	stamps := []*BookStamp{}
+	for rows.Next() {
+		stamp := new(BookStamp)
+		if err := rows.Scan(&stamp.ISBN, &stamp.UpdatedAt); err != nil {
 			return nil, err
 		}
+
+		stamps = append(stamps, stamp)
+	}
+
+	return stamps, rows.Err()
+}
+
+// book with the time it was listed and last changed, for the feeds
+type BookEntry struct {
+	*Book
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// newest books first, filter.Query is ignored
+func (m *BookModel) RecentBooks(ctx context.Context, filter BookFilter, limit int) ([]*BookEntry, error) {
+	stmt := "SELECT " + bookColumns + ",`created_at`,`updated_at` FROM `books` WHERE 1 = 1"
+	args := []any{}
+	if filter.Genre != "" {
+		stmt += " AND `isbn` IN (" + genreBooks + ")"
+		args = append(args, filter.Genre, filter.Genre)
+	}
+
+	if filter.Author != "" {
+		stmt += " AND `author` = ?"
+		args = append(args, filter.Author)
+	}
+
+	rows, err := m.db.QueryContext(ctx, stmt+" ORDER BY `created_at` DESC, `isbn` LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
 	}
 
 	defer rows.Close()
 
+	entries := []*BookEntry{}
 	for rows.Next() {
-		book := new(Book)
-		err := m.ScanBookData(rows, book)
+		e := &BookEntry{Book: new(Book)}
+		err := m.scanBook(rows, e.Book, &e.CreatedAt, &e.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
 
-		Books = append(Books, book)
+		entries = append(entries, e)
 	}
 
-	// Cache the result in Redis for 5 minutes
-	data, err := json.Marshal(Books)
+	return entries, rows.Err()
+}
+
+// genre or author with the number of books carrying it
+type Facet struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// empty fields are not filtered, Query matches title, author or isbn
+type BookFilter struct {
+	Genre  string
+	Author string
+	Query  string
+}
+
+func (m *BookModel) Genres(ctx context.Context) ([]*Facet, error) {
+	return m.facets(ctx, genreClosure+"SELECT g.`name`, COUNT(DISTINCT bg.`isbn`) FROM `genres` g JOIN `closure` c ON c.`ancestor` = g.`id` JOIN `book_genres` bg ON bg.`genre_id` = c.`id` GROUP BY g.`id`, g.`name` ORDER BY g.`name`")
+}
+
+func (m *BookModel) Authors(ctx context.Context) ([]*Facet, error) {
+	return m.facets(ctx, "SELECT `author`, COUNT(*) FROM `books` GROUP BY `author` ORDER BY `author`")
+}
+
+func (m *BookModel) facets(ctx context.Context, stmt string) ([]*Facet, error) {
+	rows, err := m.db.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, err
 	}
 
-	err = m.redis.Set(m.ctx, string(queryBytes), data, 5*time.Minute).Err()
+	defer rows.Close()
+
+	facets := []*Facet{}
+	for rows.Next() {
+		facet := new(Facet)
+		if err := rows.Scan(&facet.Name, &facet.Count); err != nil {
+			return nil, err
+		}
+
+		facets = append(facets, facet)
+	}
+
+	return facets, rows.Err()
+}
+
+// one page of books ordered by title, and the number of books matching the filter
+func (m *BookModel) FilterBooks(ctx context.Context, filter BookFilter, limit, offset int) ([]*Book, int, error) {
+	where := " WHERE 1 = 1"
+	args := []any{}
+	if filter.Genre != "" {
+		where += " AND `isbn` IN (" + genreBooks + ")"
+		args = append(args, filter.Genre, filter.Genre)
+	}
+
+	if filter.Author != "" {
+		where += " AND `author` = ?"
+		args = append(args, filter.Author)
+	}
+
+	if filter.Query != "" {
+		like := "%" + filter.Query + "%"
+		where += " AND (`title` LIKE ? OR `author` LIKE ? OR `isbn` = ?)"
+		args = append(args, like, like, filter.Query)
+	}
+
+	var total int
+	err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM `books`"+where, args...).Scan(&total)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	stmt := "SELECT " + bookColumns + " FROM `books`" + where + " ORDER BY `title`, `isbn` LIMIT ? OFFSET ?"
+	rows, err := m.db.QueryContext(ctx, stmt, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	m.Close()
-	return Books, err
+	defer rows.Close()
+
+	books := []*Book{}
+	for rows.Next() {
+		book := new(Book)
+		if err := m.ScanBookData(rows, book); err != nil {
+			return nil, 0, err
+		}
+
+		books = append(books, book)
+	}
+
+	return books, total, rows.Err()
 }
 
-func (m *BookModel) ScanBookData(rows *sql.Rows, book *Book) error {
-	return rows.Scan(
+func (m *BookModel) ScanBookData(rows rowScanner, book *Book) error {
+	return m.scanBook(rows, book)
+}
+
+// bookColumns followed by the extra columns of the query
+func (m *BookModel) scanBook(row rowScanner, book *Book, extra ...any) error {
+	var cover, format, language sql.NullString
+	var work, publisher sql.NullInt64
+	dest := []any{
 		&book.ISBN,
 		&book.Title,
 		&book.Author,
 		&book.Price,
 		&book.Descriptions,
 		&book.Genre,
-	)
+		&cover,
+		&work,
+		&format,
+		&language,
+		&publisher,
+	}
+
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	book.Cover = cover.String
+	book.WorkID = work.Int64
+	book.Format = format.String
+	book.Language = language.String
+	book.PublisherID = publisher.Int64
+	if book.Cover != "" && m.coverURLs != nil {
+		book.Covers = m.coverURLs(book.Cover)
+	}
+
+	return nil
 }
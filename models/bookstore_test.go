@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBookCRUD(t *testing.T) {
+	eachDB(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		m := Constructor(db, nil, nil)
+		book := &Book{
+			ISBN:         "978-0-00-000001-1",
+			Title:        "Good Omens",
+			Author:       "Terry Pratchett, Neil Gaiman",
+			Price:        9.99,
+			Descriptions: "The end of the world",
+			Genre:        "Fantasy",
+			Genres:       []*BookGenre{{Name: "Fantasy"}, {Name: "Comedy"}},
+			Format:       "paperback",
+			Language:     "en",
+		}
+
+		if err := m.Books.CreateBook(ctx, book, testAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Books.CreateBook(ctx, book, testAdmin); !errors.Is(err, ErrConflict) {
+			t.Errorf("CreateBook of a stored isbn = %v, want a conflict", err)
+		}
+
+		exist, err := m.Books.BookExist(ctx, book.ISBN)
+		if err != nil || !exist {
+			t.Errorf("BookExist = %v, %v, want true", exist, err)
+		}
+
+		found, err := m.Books.FindBook(ctx, book.ISBN)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Title != book.Title || found.Price != book.Price || found.Format != "paperback" || found.WorkID == 0 {
+			t.Errorf("FindBook = %+v, want the created book in a work", found)
+		}
+
+		contributors, err := m.Books.Contributors(ctx, book.ISBN)
+		if err != nil || len(contributors) != 2 || contributors[0].Name != "Terry Pratchett" || contributors[1].Name != "Neil Gaiman" {
+			t.Errorf("Contributors = %v, %v, want both authors in order", contributors, err)
+		}
+
+		genres, err := m.Books.BookGenres(ctx, book.ISBN)
+		if err != nil || len(genres) != 2 {
+			t.Errorf("BookGenres = %v, %v, want Comedy and Fantasy", genres, err)
+		}
+
+		update := *found
+		update.Title = "Good Omens: The Nice and Accurate Prophecies"
+		update.Price = 11
+		if err := m.Books.UpdateBook(ctx, &update, testAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if found, err = m.Books.FindBook(ctx, book.ISBN); err != nil || found.Title != update.Title || found.Price != 11 {
+			t.Errorf("FindBook after the update = %+v, %v, want the new title and price", found, err)
+		}
+
+		// the cover changes only when one is given
+		for _, cover := range []string{"isbn/a/original.png", ""} {
+			update.Cover = cover
+			if err := m.Books.UpdateBook(ctx, &update, testAdmin); err != nil {
+				t.Fatal(err)
+			}
+			if found, err = m.Books.FindBook(ctx, book.ISBN); err != nil || found.Cover != "isbn/a/original.png" {
+				t.Errorf("cover after an update with %q = %q, %v, want isbn/a/original.png", cover, found.Cover, err)
+			}
+		}
+
+		if err := m.Books.UpdateBook(ctx, &Book{ISBN: "978-0-00-000009-7", Title: "x", Author: "x", Genre: "x"}, testAdmin); !errors.Is(err, ErrNotFound) {
+			t.Errorf("UpdateBook of an unknown isbn = %v, want not found", err)
+		}
+		if err := m.Books.DeleteBook(ctx, book.ISBN, &Editor{UID: 5}); !errors.Is(err, ErrForbidden) {
+			t.Errorf("DeleteBook by a user who is no admin = %v, want forbidden", err)
+		}
+
+		if err := m.Books.DeleteBook(ctx, book.ISBN, testAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Books.FindBook(ctx, book.ISBN); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("FindBook of the deleted book = %v, want ErrNoRecord", err)
+		}
+		// the relations went with the book
+		if contributors, _ := m.Books.Contributors(ctx, book.ISBN); len(contributors) != 0 {
+			t.Errorf("Contributors of the deleted book = %v, want none", contributors)
+		}
+		if genres, _ := m.Books.BookGenres(ctx, book.ISBN); len(genres) != 0 {
+			t.Errorf("BookGenres of the deleted book = %v, want none", genres)
+		}
+		if err := m.Books.DeleteBook(ctx, book.ISBN, testAdmin); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("DeleteBook of the deleted book = %v, want ErrNoRecord", err)
+		}
+	})
+}
+
+func TestImportBooks(t *testing.T) {
+	eachDB(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		m := Constructor(db, nil, nil)
+		books := []*Book{
+			{ISBN: "978-0-00-000001-1", Title: "Dune", Author: "Frank Herbert", Price: 9.99, Descriptions: "Desert planet", Genre: "Science Fiction"},
+			{ISBN: "978-0-00-000002-8", Title: "Emma", Author: "Jane Austen", Price: 5, Descriptions: "Matchmaking", Genre: "Romance"},
+		}
+
+		inserted, updated, err := m.Books.ImportBooks(ctx, books, false, testAdmin)
+		if err != nil || inserted != 2 || updated != 0 {
+			t.Fatalf("ImportBooks = %d, %d, %v, want 2 inserted", inserted, updated, err)
+		}
+
+		books[0].Price = 12
+		inserted, updated, err = m.Books.ImportBooks(ctx, books[:1], true, testAdmin)
+		if err != nil || inserted != 0 || updated != 1 {
+			t.Fatalf("ImportBooks with upsert = %d, %d, %v, want 1 updated", inserted, updated, err)
+		}
+
+		listing, err := m.Books.BooksListing(ctx)
+		if err != nil || len(listing) != 2 {
+			t.Fatalf("BooksListing = %v, %v, want both books", listing, err)
+		}
+		if found, _ := m.Books.FindBook(ctx, "978-0-00-000001-1"); found == nil || found.Price != 12 {
+			t.Errorf("FindBook after the upsert = %+v, want the new price", found)
+		}
+
+		// a row of the same genre text keeps the other genres of the book
+		books[1].Genres = []*BookGenre{{Name: "Romance"}, {Name: "Comedy"}}
+		if _, _, err := m.Books.ImportBooks(ctx, books[1:], true, testAdmin); err != nil {
+			t.Fatal(err)
+		}
+		row := *books[1]
+		row.Genres, row.Price = nil, 6
+		if _, _, err := m.Books.ImportBooks(ctx, []*Book{&row}, true, testAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if genres, err := m.Books.BookGenres(ctx, row.ISBN); err != nil || len(genres) != 2 {
+			t.Errorf("BookGenres after an upsert of the same genre = %v, %v, want Comedy and Romance", genres, err)
+		}
+
+		row.Genre = "Classics"
+		if _, _, err := m.Books.ImportBooks(ctx, []*Book{&row}, true, testAdmin); err != nil {
+			t.Fatal(err)
+		}
+		if genres, err := m.Books.BookGenres(ctx, row.ISBN); err != nil || len(genres) != 1 || genres[0].Name != "Classics" {
+			t.Errorf("BookGenres after an upsert of a new genre = %v, %v, want Classics", genres, err)
+		}
+	})
+}
+
+func TestReviewCRUD(t *testing.T) {
+	eachDB(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		m := Constructor(db, nil, nil)
+		const isbn = "978-0-00-000001-1"
+
+		for uid, rating := range map[int64]float32{1: 5, 2: 3} {
+			review := &Review{Isbn: isbn, Title: "Dune", Rating: rating, Price: 9.99, Descriptions: "read it", Uid: uid}
+			if err := m.Review.CreateReview(ctx, review); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		reviews, err := m.Review.GetReviewByIsbn(ctx, isbn)
+		if err != nil || len(reviews) != 2 {
+			t.Fatalf("GetReviewByIsbn = %v, %v, want two reviews", reviews, err)
+		}
+		avg, count, err := m.Review.RatingSummary(ctx, isbn)
+		if err != nil || avg != 4 || count != 2 {
+			t.Errorf("RatingSummary = %v, %d, %v, want 4 of 2", avg, count, err)
+		}
+
+		recent, err := m.Review.RecentReviews(ctx, isbn, 10)
+		if err != nil || len(recent) != 2 {
+			t.Fatalf("RecentReviews = %v, %v", recent, err)
+		}
+		id, uid := recent[0].ID, recent[0].Uid
+
+		if err := m.Review.DeleteReview(ctx, id, uid+10); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("DeleteReview by another user = %v, want ErrNoRecord", err)
+		}
+		if err := m.Review.DeleteReview(ctx, id, uid); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Review.DeleteReview(ctx, id, uid); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("DeleteReview of a deleted review = %v, want ErrNoRecord", err)
+		}
+
+		if mine, err := m.Review.MyReview(ctx, uid); err != nil || len(mine) != 0 {
+			t.Errorf("MyReview after the delete = %v, %v, want none", mine, err)
+		}
+		if reviews, err := m.Review.GetReviewByIsbn(ctx, isbn); err != nil || len(reviews) != 1 {
+			t.Errorf("GetReviewByIsbn after the delete = %v, %v, want one", reviews, err)
+		}
+	})
+}
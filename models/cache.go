@@ -0,0 +1,42 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"test.iamgak.net/cache"
+)
+
+// tags of the cached reads, a write drops every entry of its tags
+const (
+	TagBooks   = "books"
+	TagReviews = "reviews"
+)
+
+const (
+	bookCacheTTL   = 5 * time.Minute
+	reviewCacheTTL = time.Minute
+	// an unknown isbn is asked for again after this
+	negativeCacheTTL = 30 * time.Second
+	// entries of the in-process cache when none is given
+	defaultCacheSize = 1000
+)
+
+// value of key from the cache, or from load and then cached as json for ttl
+func cached[T any](ctx context.Context, c *cache.Loader, key string, ttl time.Duration, tags []string, load func(ctx context.Context) (T, error)) (T, error) {
+	var value T
+	data, err := c.Fetch(ctx, key, ttl, tags, func(ctx context.Context) ([]byte, error) {
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+	})
+	if err != nil {
+		return value, err
+	}
+
+	err = json.Unmarshal(data, &value)
+	return value, err
+}
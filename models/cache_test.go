@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"test.iamgak.net/cache"
+)
+
+var errCacheDown = errors.New("cache down")
+
+// a cache that is down, every call fails
+type failingCache struct{}
+
+func (failingCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errCacheDown
+}
+
+func (failingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return errCacheDown
+}
+
+func (failingCache) Delete(ctx context.Context, keys ...string) error { return errCacheDown }
+
+func (failingCache) Invalidate(ctx context.Context, tags ...string) error { return errCacheDown }
+
+func (failingCache) Flush(ctx context.Context) error { return errCacheDown }
+
+// the reads answer from the database, whether the cache works or not, and
+// see the writes at once
+func TestCachedBooks(t *testing.T) {
+	for name, c := range map[string]cache.Cache{
+		"lru":     cache.NewLRU(100),
+		"failing": failingCache{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := Constructor(testDB(t, SQLite), nil, c)
+			book := &Book{ISBN: "978-0-00-000001-1", Title: "Dune", Author: "Frank Herbert", Price: 9.99, Descriptions: "Desert planet", Genre: "Science Fiction"}
+			if err := m.Books.CreateBook(ctx, book, testAdmin); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 3; i++ {
+				books, err := m.Books.BooksListing(ctx)
+				if err != nil || len(books) != 1 || books[0].Title != "Dune" {
+					t.Fatalf("BooksListing run %d = %v, %v, want Dune", i, books, err)
+				}
+
+				books, err = m.Books.GetBookByIsbn(ctx, book.ISBN)
+				if err != nil || len(books) != 1 || books[0].Author != "Frank Herbert" {
+					t.Fatalf("GetBookByIsbn run %d = %v, %v, want Dune", i, books, err)
+				}
+
+				books, err = m.Books.GetBookByIsbn(ctx, "978-0-00-000009-7")
+				if !errors.Is(err, ErrNoRecord) {
+					t.Fatalf("GetBookByIsbn of an unknown isbn run %d = %v, %v, want ErrNoRecord", i, books, err)
+				}
+			}
+
+			book.Price = 12.5
+			if err := m.Books.UpdateBook(ctx, book, testAdmin); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 3; i++ {
+				books, err := m.Books.GetBookByIsbn(ctx, book.ISBN)
+				if err != nil || len(books) != 1 || books[0].Price != 12.5 {
+					t.Fatalf("GetBookByIsbn after the update run %d = %v, %v, want the new price", i, books, err)
+				}
+
+				books, err = m.Books.BooksListing(ctx)
+				if err != nil || len(books) != 1 || books[0].Price != 12.5 {
+					t.Fatalf("BooksListing after the update run %d = %v, %v, want the new price", i, books, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"test.iamgak.net/database"
+)
+
+// environment variables with the data source names of the servers the tests
+// run against besides SQLite, their database is emptied by every test
+var testDSNs = map[Dialect]string{
+	MySQL:    "MYSQL_TEST_DSN",
+	Postgres: "POSTGRES_TEST_DSN",
+}
+
+// a migrated database of dialect, a SQLite one of its own for the test and
+// the emptied database of MYSQL_TEST_DSN or POSTGRES_TEST_DSN, the test is
+// skipped when that is not set
+func testDB(t *testing.T, dialect Dialect) *DB {
+	t.Helper()
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000"
+	if dialect != SQLite {
+		dsn = os.Getenv(testDSNs[dialect])
+		if dsn == "" {
+			t.Skip(testDSNs[dialect] + " is not set")
+		}
+	}
+
+	// the models scan DATETIME columns into time.Time
+	if dialect == MySQL {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.ParseTime = true
+		dsn = cfg.FormatDSN()
+	}
+
+	db, err := Open(dialect, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fsys, err := database.Migrations(string(dialect))
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := NewMigrator(db, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	// the rows of the test before on a shared server go with its schema
+	if _, err := migrator.Down(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// run test as a sub test on the database of every dialect
+func eachDB(t *testing.T, test func(t *testing.T, db *DB)) {
+	for _, dialect := range Dialects {
+		t.Run(string(dialect), func(t *testing.T) {
+			test(t, testDB(t, dialect))
+		})
+	}
+}
+
+var testAdmin = &Editor{Admin: true}
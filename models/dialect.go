@@ -0,0 +1,245 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Dialect is the SQL of a database, the models write their statements for
+// MySQL and DB rewrites them for the others
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+var Dialects = []Dialect{MySQL, SQLite, Postgres}
+
+// driver name registered with database/sql, the main package imports the drivers
+func (d Dialect) Driver() string {
+	switch d {
+	case SQLite:
+		return "sqlite3"
+	case Postgres:
+		return "postgres"
+	}
+
+	return "mysql"
+}
+
+// DB is the pool of the models, the statements are rewritten for its dialect
+type DB struct {
+	*sql.DB
+	dialect Dialect
+	// rewritten statements by statement, the models use a few hundred
+	stmts sync.Map
+}
+
+// Tx is a transaction of DB with its rewriting
+type Tx struct {
+	*sql.Tx
+	db *DB
+}
+
+func NewDB(db *sql.DB, dialect Dialect) *DB {
+	if dialect == SQLite {
+		// one writer at a time, and ":memory:" is a database per connection
+		db.SetMaxOpenConns(1)
+	}
+
+	return &DB{DB: db, dialect: dialect}
+}
+
+// Open connects to the database of dsn and checks it answers
+func Open(dialect Dialect, dsn string) (*DB, error) {
+	db, err := sql.Open(dialect.Driver(), dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, &Error{Kind: ErrUnavailable, Message: "the database does not answer", Err: err}
+	}
+
+	return NewDB(db, dialect), nil
+}
+
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+// the errors of Exec, Query and Begin are given their kind by dbError
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	result, err := db.DB.ExecContext(ctx, db.rebind(query), db.args(args)...)
+	return result, dbError(err)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := db.DB.QueryContext(ctx, db.rebind(query), db.args(args)...)
+	return rows, dbError(err)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return db.DB.QueryRowContext(ctx, db.rebind(query), db.args(args)...)
+}
+
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, dbError(err)
+	}
+
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+func (db *DB) insertID(ctx context.Context, query string, args ...any) (int64, error) {
+	return insertID(ctx, db, db.dialect, query, args...)
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	result, err := tx.Tx.ExecContext(ctx, tx.db.rebind(query), tx.db.args(args)...)
+	return result, dbError(err)
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := tx.Tx.QueryContext(ctx, tx.db.rebind(query), tx.db.args(args)...)
+	return rows, dbError(err)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.db.rebind(query), tx.db.args(args)...)
+}
+
+func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
+	stmt, err := tx.Tx.PrepareContext(ctx, tx.db.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stmt{Stmt: stmt, db: tx.db}, nil
+}
+
+func (tx *Tx) insertID(ctx context.Context, query string, args ...any) (int64, error) {
+	return insertID(ctx, tx, tx.db.dialect, query, args...)
+}
+
+// Stmt is a prepared statement of Tx
+type Stmt struct {
+	*sql.Stmt
+	db *DB
+}
+
+func (s *Stmt) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
+	return s.Stmt.ExecContext(ctx, s.db.args(args)...)
+}
+
+// id of the row inserted by query, PostgreSQL has no LastInsertId and returns
+// it instead
+func insertID(ctx context.Context, q queryer, dialect Dialect, query string, args ...any) (int64, error) {
+	var id int64
+	if dialect == Postgres {
+		err := q.QueryRowContext(ctx, query+" RETURNING `id`", args...).Scan(&id)
+		return id, dbError(err)
+	}
+
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// " ON DUPLICATE KEY UPDATE" of an INSERT, the columns take the inserted
+// values when a row with the same keys exists
+func (db *DB) upsert(keys []string, columns ...string) string {
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		if db.dialect == MySQL {
+			set[i] = fmt.Sprintf("`%s` = VALUES(`%s`)", c, c)
+		} else {
+			set[i] = fmt.Sprintf("`%s` = excluded.`%s`", c, c)
+		}
+	}
+
+	if db.dialect == MySQL {
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	}
+
+	return " ON CONFLICT (`" + strings.Join(keys, "`, `") + "`) DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// the statement in the dialect of db: INSERT IGNORE for SQLite, and for
+// PostgreSQL double quotes, $1 placeholders, ON CONFLICT DO NOTHING and ILIKE
+// to match case insensitively like MySQL
+func (db *DB) rebind(query string) string {
+	if db.dialect == MySQL {
+		return query
+	}
+
+	if stmt, ok := db.stmts.Load(query); ok {
+		return stmt.(string)
+	}
+
+	stmt := query
+	ignore := strings.HasPrefix(stmt, "INSERT IGNORE ")
+	switch db.dialect {
+	case SQLite:
+		stmt = strings.Replace(stmt, "INSERT IGNORE ", "INSERT OR IGNORE ", 1)
+	case Postgres:
+		if ignore {
+			stmt = "INSERT " + strings.TrimPrefix(stmt, "INSERT IGNORE ") + " ON CONFLICT DO NOTHING"
+		}
+		stmt = strings.ReplaceAll(placeholders(stmt), " LIKE ", " ILIKE ")
+	}
+
+	db.stmts.Store(query, stmt)
+	return stmt
+}
+
+// ? to $1, $2, ... and ` to ", not inside string literals
+func placeholders(query string) string {
+	var b strings.Builder
+	n, literal := 0, false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			literal = !literal
+			b.WriteRune(r)
+		case literal:
+			b.WriteRune(r)
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		case r == '`':
+			b.WriteByte('"')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// times are stored in UTC like the MySQL driver does, SQLite compares them as text
+func (db *DB) args(args []any) []any {
+	if db.dialect != SQLite {
+		return args
+	}
+
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = t.UTC()
+		}
+	}
+
+	return args
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{MySQL, "INSERT IGNORE INTO `genres` (`name`) VALUES (?)", "INSERT IGNORE INTO `genres` (`name`) VALUES (?)"},
+		{MySQL, "SELECT 1 FROM `books` WHERE `title` LIKE ?", "SELECT 1 FROM `books` WHERE `title` LIKE ?"},
+		{SQLite, "INSERT IGNORE INTO `genres` (`name`) VALUES (?)", "INSERT OR IGNORE INTO `genres` (`name`) VALUES (?)"},
+		{SQLite, "SELECT 1 FROM `books` WHERE `title` LIKE ?", "SELECT 1 FROM `books` WHERE `title` LIKE ?"},
+		{Postgres, "INSERT IGNORE INTO `genres` (`name`) VALUES (?)", `INSERT INTO "genres" ("name") VALUES ($1) ON CONFLICT DO NOTHING`},
+		{Postgres, "SELECT 1 FROM `books` WHERE `title` LIKE ? AND `isbn` = ?", `SELECT 1 FROM "books" WHERE "title" ILIKE $1 AND "isbn" = $2`},
+		{Postgres, "UPDATE `users` SET `login_token` = NULL WHERE `id` = ?", `UPDATE "users" SET "login_token" = NULL WHERE "id" = $1`},
+	}
+
+	for _, tt := range tests {
+		db := &DB{dialect: tt.dialect}
+		// the second run comes from the statements already rewritten
+		for run := 0; run < 2; run++ {
+			if got := db.rebind(tt.query); got != tt.want {
+				t.Errorf("%s rebind(%q) = %q, want %q", tt.dialect, tt.query, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT `id` FROM `users` WHERE `email` = ?", `SELECT "id" FROM "users" WHERE "email" = $1`},
+		{"INSERT INTO `t` (`a`,`b`,`c`) VALUES (?,?,?)", `INSERT INTO "t" ("a","b","c") VALUES ($1,$2,$3)`},
+		// literals are left as they are
+		{"SELECT '?', '`x`' WHERE `a` = ?", "SELECT '?', '`x`' WHERE \"a\" = $1"},
+		{"UPDATE `t` SET `a` = 'it''s ?' WHERE `id` = ?", `UPDATE "t" SET "a" = 'it''s ?' WHERE "id" = $1`},
+		{"SELECT `name` FROM `authors` WHERE `name` = 'Orwell'", `SELECT "name" FROM "authors" WHERE "name" = 'Orwell'`},
+	}
+
+	for _, tt := range tests {
+		if got := placeholders(tt.query); got != tt.want {
+			t.Errorf("placeholders(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		keys    []string
+		columns []string
+		want    string
+	}{
+		{MySQL, []string{"isbn"}, []string{"title"}, " ON DUPLICATE KEY UPDATE `title` = VALUES(`title`)"},
+		{MySQL, []string{"isbn"}, []string{"title", "price"}, " ON DUPLICATE KEY UPDATE `title` = VALUES(`title`), `price` = VALUES(`price`)"},
+		{SQLite, []string{"isbn"}, []string{"title", "price"}, " ON CONFLICT (`isbn`) DO UPDATE SET `title` = excluded.`title`, `price` = excluded.`price`"},
+		{Postgres, []string{"publisher_id", "uid"}, []string{"role"}, " ON CONFLICT (`publisher_id`, `uid`) DO UPDATE SET `role` = excluded.`role`"},
+	}
+
+	for _, tt := range tests {
+		db := &DB{dialect: tt.dialect}
+		if got := db.upsert(tt.keys, tt.columns...); got != tt.want {
+			t.Errorf("%s upsert(%v, %v) = %q, want %q", tt.dialect, tt.keys, tt.columns, got, tt.want)
+		}
+	}
+}
+
+// SQLite understands the statements of every dialect, RETURNING and $1 as well
+func TestInsertID(t *testing.T) {
+	ctx := context.Background()
+	for _, dialect := range Dialects {
+		t.Run(string(dialect), func(t *testing.T) {
+			pool, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "ids.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+
+			db := NewDB(pool, dialect)
+			if _, err := pool.ExecContext(ctx, "CREATE TABLE `names` (`id` INTEGER PRIMARY KEY AUTOINCREMENT, `name` TEXT UNIQUE)"); err != nil {
+				t.Fatal(err)
+			}
+
+			for want, name := range []string{"a", "b", "c"} {
+				id, err := db.insertID(ctx, "INSERT INTO `names` (`name`) VALUES (?)", name)
+				if err != nil || id != int64(want+1) {
+					t.Errorf("insertID of %s = %d, %v, want %d", name, id, err, want+1)
+				}
+			}
+
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			if id, err := tx.insertID(ctx, "INSERT INTO `names` (`name`) VALUES (?)", "d"); err != nil || id != 4 {
+				t.Errorf("insertID in a transaction = %d, %v, want 4", id, err)
+			}
+
+			if _, err := tx.insertID(ctx, "INSERT INTO `names` (`name`) VALUES (?)", "a"); !errors.Is(err, ErrConflict) {
+				t.Errorf("insertID of a taken name = %v, want a conflict", err)
+			}
+		})
+	}
+}
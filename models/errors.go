@@ -1,10 +1,110 @@
 package models
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"net"
+	"strings"
 )
 
-var ErrNoRecord = errors.New("models: no matching record found")
-var NoEnvFile = errors.New("models: no matching record found")
-var ErrIncorrectPassword = errors.New("models: incorrect password")
-var ErrUserNotFound = errors.New("models: no such user exist")
+// kinds of the errors of the models, every Error is one of them so callers
+// can branch with errors.Is(err, ErrNotFound) whatever the exact error is
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrConflict    = errors.New("conflict")
+	ErrValidation  = errors.New("invalid")
+	ErrForbidden   = errors.New("forbidden")
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// Error is an error of the models of one Kind, Field names the field at fault
+// of validation errors and Err is the cause when there is one
+type Error struct {
+	Kind    error
+	Message string
+	Field   string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return "models: " + e.Message + ": " + e.Err.Error()
+	}
+	return "models: " + e.Message
+}
+
+// errors.Is matches the kind and the cause
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+var ErrNoRecord = &Error{Kind: ErrNotFound, Message: "no matching record found"}
+var NoEnvFile = &Error{Kind: ErrNotFound, Message: "no .env file found"}
+var ErrIncorrectPassword = &Error{Kind: ErrValidation, Field: "password", Message: "incorrect password"}
+var ErrUserNotFound = &Error{Kind: ErrNotFound, Message: "no such user exist"}
+var ErrInvalidParent = &Error{Kind: ErrValidation, Field: "parent_id", Message: "parent genre is unknown or below the genre"}
+var ErrNotOwner = &Error{Kind: ErrForbidden, Message: "the book belongs to another publisher"}
+var ErrPublisherRequired = &Error{Kind: ErrValidation, Field: "publisher_id", Message: "member of several publishers, publisher_id is required"}
+var ErrLastOwner = &Error{Kind: ErrConflict, Message: "a publisher needs at least one owner"}
+var ErrDirtyMigration = &Error{Kind: ErrConflict, Message: "a migration failed half way, repair the schema and run migrate force"}
+var ErrMigrationLocked = &Error{Kind: ErrUnavailable, Message: "another migration run holds the lock"}
+
+// the error of the database with its kind: a duplicate key is a conflict, a
+// lost connection or a request out of time unavailable, the others stay as
+// they are
+func dbError(err error) error {
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, sql.ErrNoRows):
+		return err
+	case duplicateKey(err):
+		return &Error{Kind: ErrConflict, Message: "the record already exists", Err: err}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled), errors.As(err, &netErr):
+		return &Error{Kind: ErrUnavailable, Message: "the database is unavailable", Err: err}
+	}
+
+	return err
+}
+
+// whether query finds a row, the errors other than no rows are returned
+func exists(ctx context.Context, q queryer, query string, args ...any) (bool, error) {
+	var one int
+	err := q.QueryRowContext(ctx, query, args...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, dbError(err)
+}
+
+// err of the statement, missing when it matched no row
+func affected(result sql.Result, err error, missing error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return missing
+	}
+
+	return nil
+}
+
+// the unique key errors of MySQL, SQLite and PostgreSQL, the drivers are only
+// known to the main package
+func duplicateKey(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "Duplicate entry") ||
+		strings.Contains(msg, "UNIQUE constraint failed") ||
+		strings.Contains(msg, "duplicate key value violates unique constraint")
+}
@@ -0,0 +1,358 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// isbns of the genres with the slug or name and of all their descendants
+const genreBooks = "SELECT bg.`isbn` FROM `book_genres` bg WHERE bg.`genre_id` IN (" +
+	"WITH RECURSIVE `tree` (`id`) AS (SELECT `id` FROM `genres` WHERE `slug` = ? OR `name` = ? " +
+	"UNION ALL SELECT g.`id` FROM `genres` g JOIN `tree` t ON g.`parent_id` = t.`id`) SELECT `id` FROM `tree`)"
+
+// pairs of every genre (ancestor) with itself and each of its descendants (id)
+const genreClosure = "WITH RECURSIVE `closure` (`ancestor`, `id`) AS (SELECT `id`, `id` FROM `genres` " +
+	"UNION ALL SELECT c.`ancestor`, g.`id` FROM `closure` c JOIN `genres` g ON g.`parent_id` = c.`id`) "
+
+// every genre with the number of distinct books filed under it or a descendant
+const genreCounts = genreClosure + "SELECT g.`id`, COALESCE(g.`parent_id`, 0), g.`name`, g.`slug`, COUNT(DISTINCT bg.`isbn`) FROM `genres` g " +
+	"JOIN `closure` c ON c.`ancestor` = g.`id` LEFT JOIN `book_genres` bg ON bg.`genre_id` = c.`id` " +
+	"GROUP BY g.`id`, g.`parent_id`, g.`name`, g.`slug`"
+
+type Genre struct {
+	ID       int64  `json:"id"`
+	ParentID int64  `json:"parent_id,omitempty"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	// books of the genre and its descendants
+	Books    int      `json:"books"`
+	Children []*Genre `json:"children,omitempty"`
+}
+
+// genre of a book, given by slug or by name
+type BookGenre struct {
+	Name string `json:"name"`
+	Slug string `json:"slug,omitempty"`
+}
+
+// the whole genre tree, it is small enough to load at once
+type Taxonomy struct {
+	Roots []*Genre
+	ByID  map[int64]*Genre
+}
+
+type GenreModel struct {
+	db *DB
+}
+
+// Slugify makes the url name of a genre, "Science Fiction" -> "science-fiction"
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	return b.String()
+}
+
+// genres of the book, the Book.Genre text when none are given
+func (book *Book) genres() []*BookGenre {
+	if len(book.Genres) > 0 {
+		return book.Genres
+	}
+
+	if strings.TrimSpace(book.Genre) == "" {
+		return nil
+	}
+
+	return []*BookGenre{{Name: strings.TrimSpace(book.Genre)}}
+}
+
+// genre of the slug, or of the name, created at the top of the tree when unknown
+func resolveGenre(ctx context.Context, q queryer, genre *BookGenre) (int64, error) {
+	var id int64
+	var err error
+	if genre.Slug != "" {
+		err = q.QueryRowContext(ctx, "SELECT `id` FROM `genres` WHERE `slug` = ?", genre.Slug).Scan(&id)
+	} else {
+		err = q.QueryRowContext(ctx, "SELECT `id` FROM `genres` WHERE `name` = ? ORDER BY `id` LIMIT 1", genre.Name).Scan(&id)
+	}
+	if err != sql.ErrNoRows {
+		return id, err
+	}
+
+	name, slug := genre.Name, genre.Slug
+	if name == "" {
+		name = slug
+	}
+	if slug == "" {
+		if slug, err = uniqueSlug(ctx, q, Slugify(name)); err != nil {
+			return 0, err
+		}
+	}
+
+	return q.insertID(ctx, "INSERT INTO `genres` (`name`,`slug`) VALUES (?,?)", name, slug)
+}
+
+// slug, or slug-2, slug-3, ... when it is taken
+func uniqueSlug(ctx context.Context, q queryer, slug string) (string, error) {
+	if slug == "" {
+		slug = "genre"
+	}
+
+	candidate := slug
+	for i := 2; ; i++ {
+		var exist int
+		err := q.QueryRowContext(ctx, "SELECT 1 FROM `genres` WHERE `slug` = ?", candidate).Scan(&exist)
+		if err == sql.ErrNoRows {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		candidate = slug + "-" + strconv.Itoa(i)
+	}
+}
+
+// replace the genres of the book
+func setGenres(ctx context.Context, q queryer, ISBN string, genres []*BookGenre) error {
+	_, err := q.ExecContext(ctx, "DELETE FROM `book_genres` WHERE `isbn` = ?", ISBN)
+	if err != nil {
+		return err
+	}
+
+	for _, genre := range genres {
+		id, err := resolveGenre(ctx, q, genre)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.ExecContext(ctx, "INSERT IGNORE INTO `book_genres` (`isbn`,`genre_id`) VALUES (?,?)", ISBN, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// genres the book is filed under
+func (m *BookModel) BookGenres(ctx context.Context, ISBN string) ([]*BookGenre, error) {
+	return bookGenres(ctx, m.db, ISBN)
+}
+
+// the stored genres of an update of the book that carries only the Book.Genre
+// text, so rewriting the book keeps them. None when the text changed, the
+// book is filed under the new one then.
+func keptGenres(ctx context.Context, tx *Tx, book *Book) ([]*BookGenre, error) {
+	var genre string
+	err := tx.QueryRowContext(ctx, "SELECT `genre` FROM `books` WHERE `isbn` = ?", book.ISBN).Scan(&genre)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(genre), strings.TrimSpace(book.Genre)) {
+		return nil, nil
+	}
+
+	return bookGenres(ctx, tx, book.ISBN)
+}
+
+// *DB or *Tx
+func bookGenres(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}, ISBN string) ([]*BookGenre, error) {
+	stmt := "SELECT g.`name`, g.`slug` FROM `book_genres` bg JOIN `genres` g ON g.`id` = bg.`genre_id` WHERE bg.`isbn` = ? ORDER BY g.`name`"
+	rows, err := q.QueryContext(ctx, stmt, ISBN)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*BookGenre{}
+	for rows.Next() {
+		genre := &BookGenre{}
+		if err := rows.Scan(&genre.Name, &genre.Slug); err != nil {
+			return nil, err
+		}
+		genres = append(genres, genre)
+	}
+
+	return genres, rows.Err()
+}
+
+func (m *GenreModel) Taxonomy(ctx context.Context) (*Taxonomy, error) {
+	rows, err := m.db.QueryContext(ctx, genreCounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	t := &Taxonomy{Roots: []*Genre{}, ByID: map[int64]*Genre{}}
+	for rows.Next() {
+		g := &Genre{}
+		if err := rows.Scan(&g.ID, &g.ParentID, &g.Name, &g.Slug, &g.Books); err != nil {
+			return nil, err
+		}
+		t.ByID[g.ID] = g
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, g := range t.ByID {
+		if parent, ok := t.ByID[g.ParentID]; ok {
+			parent.Children = append(parent.Children, g)
+		} else {
+			t.Roots = append(t.Roots, g)
+		}
+	}
+
+	sortGenres(t.Roots)
+	return t, nil
+}
+
+func sortGenres(genres []*Genre) {
+	sort.Slice(genres, func(i, j int) bool {
+		return genres[i].Name < genres[j].Name
+	})
+
+	for _, g := range genres {
+		sortGenres(g.Children)
+	}
+}
+
+// genre of the slug, nil if unknown
+func (t *Taxonomy) Find(slug string) *Genre {
+	for _, g := range t.ByID {
+		if g.Slug == slug {
+			return g
+		}
+	}
+
+	return nil
+}
+
+// ancestors of the genre from the top of the tree, the genre included
+func (t *Taxonomy) Path(g *Genre) []*Genre {
+	path := []*Genre{}
+	for ; g != nil; g = t.ByID[g.ParentID] {
+		path = append([]*Genre{{ID: g.ID, ParentID: g.ParentID, Name: g.Name, Slug: g.Slug, Books: g.Books}}, path...)
+	}
+
+	return path
+}
+
+// whether genre id is parent itself or one of its ancestors
+func (t *Taxonomy) below(parent, id int64) bool {
+	for g := t.ByID[parent]; g != nil; g = t.ByID[g.ParentID] {
+		if g.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// new genre below parent (0 for the top of the tree), the slug is made from
+// the name when empty
+func (m *GenreModel) CreateGenre(ctx context.Context, genre *Genre) error {
+	t, err := m.Taxonomy(ctx)
+	if err != nil {
+		return err
+	}
+
+	if genre.ParentID != 0 && t.ByID[genre.ParentID] == nil {
+		return ErrInvalidParent
+	}
+
+	if genre.Slug == "" {
+		if genre.Slug, err = uniqueSlug(ctx, m.db, Slugify(genre.Name)); err != nil {
+			return err
+		}
+	}
+
+	genre.ID, err = m.db.insertID(ctx, "INSERT INTO `genres` (`parent_id`,`name`,`slug`) VALUES (NULLIF(?, 0),?,?)", genre.ParentID, genre.Name, genre.Slug)
+	return err
+}
+
+// rename or move a genre, it can not end up below itself
+func (m *GenreModel) UpdateGenre(ctx context.Context, genre *Genre) error {
+	t, err := m.Taxonomy(ctx)
+	if err != nil {
+		return err
+	}
+
+	if t.ByID[genre.ID] == nil {
+		return ErrNoRecord
+	}
+
+	if genre.ParentID != 0 && (t.ByID[genre.ParentID] == nil || t.below(genre.ParentID, genre.ID)) {
+		return ErrInvalidParent
+	}
+
+	if genre.Slug == "" {
+		genre.Slug = t.ByID[genre.ID].Slug
+	}
+
+	_, err = m.db.ExecContext(ctx, "UPDATE `genres` SET `parent_id` = NULLIF(?, 0), `name` = ?, `slug` = ? WHERE `id` = ?", genre.ParentID, genre.Name, genre.Slug, genre.ID)
+	return err
+}
+
+// remove a genre, its children and books move up to its parent
+func (m *GenreModel) DeleteGenre(ctx context.Context, id int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback if we encounter an error before commit
+
+	var parent sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT `parent_id` FROM `genres` WHERE `id` = ?", id).Scan(&parent)
+	if err == sql.ErrNoRows {
+		return ErrNoRecord
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE `genres` SET `parent_id` = ? WHERE `parent_id` = ?", parent, id)
+	if err != nil {
+		return err
+	}
+
+	if parent.Valid {
+		_, err = tx.ExecContext(ctx, "INSERT IGNORE INTO `book_genres` (`isbn`,`genre_id`) SELECT bg.`isbn`, g.`id` FROM `book_genres` bg JOIN `genres` g ON g.`id` = ? WHERE bg.`genre_id` = ?", parent.Int64, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range []string{
+		"DELETE FROM `book_genres` WHERE `genre_id` = ?",
+		"DELETE FROM `genres` WHERE `id` = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
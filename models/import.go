@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+)
+
+// rows written per transaction during a bulk import
+const ImportBatchSize = 500
+
+type ImportRowError struct {
+	Row    int               `json:"row"`
+	ISBN   string            `json:"isbn"`
+	Errors map[string]string `json:"errors"`
+}
+
+type ImportReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Upsert   bool              `json:"upsert"`
+	Total    int               `json:"total"`
+	Inserted int               `json:"inserted"`
+	Updated  int               `json:"updated"`
+	Skipped  int               `json:"skipped"`
+	Errors   []*ImportRowError `json:"errors"`
+}
+
+// ImportBooks writes already validated books in batches, each batch in its own
+// transaction. With upsert an existing isbn is updated instead of rejected.
+// Every book is checked against by like CreateBook and UpdateBook do.
+func (m *BookModel) ImportBooks(ctx context.Context, books []*Book, upsert bool, by *Editor) (inserted, updated int, err error) {
+	// the batches before a failed one are in
+	defer func() {
+		if inserted+updated > 0 {
+			m.cache.Invalidate(ctx, TagBooks)
+		}
+	}()
+
+	for start := 0; start < len(books); start += ImportBatchSize {
+		end := start + ImportBatchSize
+		if end > len(books) {
+			end = len(books)
+		}
+
+		ins, upd, err := m.importBatch(ctx, books[start:end], upsert, by)
+		if err != nil {
+			return inserted, updated, err
+		}
+
+		inserted += ins
+		updated += upd
+	}
+
+	return inserted, updated, nil
+}
+
+func (m *BookModel) importBatch(ctx context.Context, books []*Book, upsert bool, by *Editor) (inserted, updated int, err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback() // Rollback if we encounter an error before commit
+
+	stmt := "INSERT INTO `books` (`isbn`,`title`,`author`,`price`,`descriptions`,`genre`,`format`,`language`,`publisher_id`) VALUES (?,?,?,?,?,?,NULLIF(?, ''),NULLIF(?, ''),NULLIF(?, 0))"
+	if upsert {
+		stmt += m.db.upsert([]string{"isbn"}, "title", "author", "price", "descriptions", "genre", "format", "language", "publisher_id")
+	}
+
+	insert, err := tx.PrepareContext(ctx, stmt)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer insert.Close()
+
+	for _, book := range books {
+		exist, err := bookExistTx(ctx, tx, book.ISBN)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if err := claimBook(ctx, tx, by, book, exist); err != nil {
+			return 0, 0, err
+		}
+
+		// a csv row has a single genre column
+		if exist && len(book.Genres) == 0 {
+			genres, err := keptGenres(ctx, tx, book)
+			if err != nil {
+				return 0, 0, err
+			}
+			if len(genres) > 0 {
+				kept := *book
+				kept.Genres = genres
+				book = &kept
+			}
+		}
+
+		_, err = insert.ExecContext(ctx, book.ISBN, book.Title, book.Author, book.Price, book.Descriptions, book.Genre, book.Format, book.Language, book.PublisherID)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if err := saveRelations(ctx, tx, book); err != nil {
+			return 0, 0, err
+		}
+
+		if exist {
+			updated++
+		} else {
+			inserted++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return inserted, updated, nil
+}
+
+func bookExistTx(ctx context.Context, tx *Tx, ISBN string) (bool, error) {
+	return exists(ctx, tx, "SELECT 1 FROM `books` WHERE `isbn` = ?", ISBN)
+}
@@ -1,23 +1,73 @@
 package models
 
 import (
-	"context"
-	"database/sql"
+	"errors"
+	"sync"
 
 	"github.com/redis/go-redis/v9"
+	"test.iamgak.net/cache"
 )
 
+// Init holds every model, the models only borrow the db pool and the redis
+// client, Init owns them and Close is the one place closing them
 type Init struct {
-	Books  BookModel
-	Users  UserModel
-	Review ReviewModel
+	Books      BookRepository
+	Users      UserRepository
+	Review     ReviewRepository
+	Authors    AuthorModel
+	Genres     GenreModel
+	Works      WorkModel
+	Publishers PublisherModel
+	Recommend  RecommendModel
+	Lists      ListModel
+	// cache of the models, for other cached responses as well
+	Cache *cache.Loader
+
+	db        *DB
+	redis     *redis.Client
+	closeOnce sync.Once
+	closeErr  error
 }
 
-func Constructor(db *sql.DB, rd *redis.Client) *Init {
-	ctx, cancel := context.WithCancel(context.Background())
+// Constructor takes over db and rd, close them with Init.Close and not on
+// their own. rd is nil without redis, c (an in-process LRU when nil) caches
+// the reads of books and reviews and keeps the results of the jobs.
+func Constructor(db *DB, rd *redis.Client, c cache.Cache) *Init {
+	if c == nil {
+		c = cache.NewLRU(defaultCacheSize)
+	}
+
+	loader := &cache.Loader{Cache: c, NotFound: ErrNoRecord, NegativeTTL: negativeCacheTTL}
 	return &Init{
-		Books:  BookModel{db: db, redis: rd, ctx: ctx, cancel: cancel},
-		Users:  UserModel{db: db, redis: rd, ctx: ctx, cancel: cancel},
-		Review: ReviewModel{db: db, redis: rd, ctx: ctx, cancel: cancel},
+		Books:      &BookModel{db: db, cache: loader},
+		Users:      &UserModel{db: db},
+		Review:     &ReviewModel{db: db, cache: loader},
+		Authors:    AuthorModel{db: db},
+		Genres:     GenreModel{db: db},
+		Works:      WorkModel{db: db},
+		Publishers: PublisherModel{db: db},
+		Recommend:  RecommendModel{db: db, cache: c},
+		Lists:      ListModel{db: db, redis: rd, cache: c},
+		Cache:      loader,
+		db:         db,
+		redis:      rd,
 	}
 }
+
+// Close closes redis and then the db pool, call it once the server and the
+// jobs have stopped, later calls return the result of the first
+func (m *Init) Close() error {
+	m.closeOnce.Do(func() {
+		var errs []error
+		if m.redis != nil {
+			errs = append(errs, m.redis.Close())
+		}
+		if m.db != nil {
+			errs = append(errs, m.db.Close())
+		}
+
+		m.closeErr = errors.Join(errs...)
+	})
+
+	return m.closeErr
+}
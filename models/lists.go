@@ -0,0 +1,181 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"test.iamgak.net/cache"
+	"test.iamgak.net/recommend"
+)
+
+// lists of the home page
+const (
+	ListTrending    = "trending"
+	ListBestsellers = "bestsellers"
+	ListNew         = "new"
+)
+
+const (
+	// book views are counted per hour, views:2006010215 (utc)
+	viewKey    = "views:"
+	viewLayout = "2006010215"
+	// views older than this are dropped by redis
+	viewsKept = 8 * 24 * time.Hour
+	listKey   = "lists:"
+)
+
+// list name -> genre slug ("" for every genre) -> books
+type ListSet map[string]map[string][]recommend.Scored
+
+type ListModel struct {
+	db *DB
+	// views are counted in redis, without it the trending list goes by reviews
+	redis *redis.Client
+	cache cache.Cache
+}
+
+// count a view of the book page for the trending list
+func (m *ListModel) RecordView(ctx context.Context, ISBN string) error {
+	if m.redis == nil {
+		return nil
+	}
+
+	key := viewKey + time.Now().UTC().Format(viewLayout)
+	pipe := m.redis.Pipeline()
+	pipe.ZIncrBy(ctx, key, 1, ISBN)
+	pipe.Expire(ctx, key, viewsKept)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// views since the given time, one event per book and hour
+func (m *ListModel) ViewEvents(ctx context.Context, since time.Time) ([]recommend.Event, error) {
+	if m.redis == nil {
+		return []recommend.Event{}, nil
+	}
+
+	hours := []time.Time{}
+	for h := since.UTC().Truncate(time.Hour); !h.After(time.Now()); h = h.Add(time.Hour) {
+		hours = append(hours, h)
+	}
+
+	pipe := m.redis.Pipeline()
+	cmds := make([]*redis.ZSliceCmd, len(hours))
+	for i, h := range hours {
+		cmds[i] = pipe.ZRangeWithScores(ctx, viewKey+h.Format(viewLayout), 0, -1)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	events := []recommend.Event{}
+	for i, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			isbn, _ := z.Member.(string)
+			events = append(events, recommend.Event{ISBN: isbn, At: hours[i].Add(30 * time.Minute), Weight: z.Score})
+		}
+	}
+
+	return events, nil
+}
+
+// (not deleted) reviews written since the given time
+func (m *ListModel) ReviewEvents(ctx context.Context, since time.Time) ([]recommend.Event, error) {
+	return m.events(ctx, "SELECT `isbn`, `created_at`, 1 FROM `reviews` WHERE `is_deleted` = 0 AND `created_at` >= ?", since)
+}
+
+// sales since the given time, weighted by the copies sold
+func (m *ListModel) SaleEvents(ctx context.Context, since time.Time) ([]recommend.Event, error) {
+	return m.events(ctx, "SELECT `isbn`, `sold_at`, `quantity` FROM `sales` WHERE `sold_at` >= ?", since)
+}
+
+// books listed since the given time
+func (m *ListModel) NewBooks(ctx context.Context, since time.Time) ([]recommend.Event, error) {
+	return m.events(ctx, "SELECT `isbn`, `created_at`, 1 FROM `books` WHERE `created_at` >= ?", since)
+}
+
+func (m *ListModel) events(ctx context.Context, stmt string, args ...any) ([]recommend.Event, error) {
+	rows, err := m.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []recommend.Event{}
+	for rows.Next() {
+		var e recommend.Event
+		if err := rows.Scan(&e.ISBN, &e.At, &e.Weight); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// isbn -> slugs of the genres of the book and of every ancestor, a book filed
+// under cyberpunk is in the science-fiction and fiction lists as well
+func (m *ListModel) GenreSlugs(ctx context.Context) (map[string][]string, error) {
+	rows, err := m.db.QueryContext(ctx, genreClosure+"SELECT DISTINCT bg.`isbn`, g.`slug` FROM `genres` g JOIN `closure` c ON c.`ancestor` = g.`id` JOIN `book_genres` bg ON bg.`genre_id` = c.`id`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slugs := map[string][]string{}
+	for rows.Next() {
+		var isbn, slug string
+		if err := rows.Scan(&isbn, &slug); err != nil {
+			return nil, err
+		}
+		slugs[isbn] = append(slugs[isbn], slug)
+	}
+
+	return slugs, rows.Err()
+}
+
+// write every list to the cache, they expire after ttl unless the job runs again
+func (m *ListModel) SaveLists(ctx context.Context, lists ListSet, ttl time.Duration) error {
+	for name, genres := range lists {
+		for genre, list := range genres {
+			data, err := json.Marshal(list)
+			if err != nil {
+				return err
+			}
+
+			if err := m.cache.Set(ctx, listKey+listName(name, genre), data, ttl); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// list of the last job run, overall for the genre "", empty when the genre
+// has no books or the job did not run yet
+func (m *ListModel) List(ctx context.Context, name, genre string) ([]recommend.Scored, error) {
+	val, err := m.cache.Get(ctx, listKey+listName(name, genre))
+	if errors.Is(err, cache.ErrMiss) {
+		return []recommend.Scored{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []recommend.Scored
+	err = json.Unmarshal(val, &list)
+	return list, err
+}
+
+func listName(name, genre string) string {
+	if genre == "" {
+		return name
+	}
+
+	return name + ":" + genre
+}
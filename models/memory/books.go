@@ -0,0 +1,789 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"test.iamgak.net/models"
+)
+
+// the most books BookStamps lists, like the sitemap query
+const maxStamps = 50000
+
+func (s *Store) SetCoverURLs(fn func(cover string) map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.coverURLs = fn
+}
+
+// copy of the columns of the stored book, as scanned from the books table
+func (s *Store) read(b *book) *models.Book {
+	c := &models.Book{
+		ISBN:         b.ISBN,
+		Title:        b.Title,
+		Author:       b.Author,
+		Price:        b.Price,
+		Descriptions: b.Descriptions,
+		Genre:        b.Genre,
+		Cover:        b.Cover,
+		WorkID:       b.WorkID,
+		Format:       b.Format,
+		Language:     b.Language,
+		PublisherID:  b.PublisherID,
+	}
+	if c.Cover != "" && s.coverURLs != nil {
+		c.Covers = s.coverURLs(c.Cover)
+	}
+
+	return c
+}
+
+func (s *Store) readAll(books []*book) []*models.Book {
+	list := make([]*models.Book, len(books))
+	for i, b := range books {
+		list[i] = s.read(b)
+	}
+
+	return list
+}
+
+// the books matching keep in isbn order, the order of the primary key
+func (d *data) find(keep func(b *book) bool) []*book {
+	books := []*book{}
+	for _, b := range d.books {
+		if keep(b) {
+			books = append(books, b)
+		}
+	}
+
+	sort.Slice(books, func(i, j int) bool {
+		return books[i].ISBN < books[j].ISBN
+	})
+	return books
+}
+
+func byTitle(books []*book) {
+	sort.SliceStable(books, func(i, j int) bool {
+		return books[i].Title < books[j].Title
+	})
+}
+
+// publisher of a stored book, 0 when it has none, ErrNoRecord if isbn is unknown
+func (d *data) ownerOf(ISBN string) (int64, error) {
+	b, ok := d.books[ISBN]
+	if !ok {
+		return 0, models.ErrNoRecord
+	}
+
+	return b.PublisherID, nil
+}
+
+// ErrNotOwner unless by may write the books of publisher, books without a
+// publisher are left to the admins
+func (d *data) canWrite(by *models.Editor, publisher int64) error {
+	if by.Admin {
+		return nil
+	}
+
+	if _, ok := d.members[publisher][by.UID]; publisher == 0 || !ok {
+		return models.ErrNotOwner
+	}
+
+	return nil
+}
+
+func (d *data) checkOwner(by *models.Editor, ISBN string) error {
+	publisher, err := d.ownerOf(ISBN)
+	if err != nil {
+		return err
+	}
+
+	return d.canWrite(by, publisher)
+}
+
+// settle Book.PublisherID like the MySQL models: a stored book keeps its
+// publisher unless PublisherID moves it, a new book of a member goes to their
+// publisher, and one of anybody else is listed without a publisher
+func (d *data) claimBook(by *models.Editor, b *models.Book, stored bool) error {
+	switch {
+	case stored:
+		current, err := d.ownerOf(b.ISBN)
+		if err != nil {
+			return err
+		}
+
+		if err := d.canWrite(by, current); err != nil {
+			return err
+		}
+
+		if b.PublisherID == 0 || b.PublisherID == current {
+			b.PublisherID = current
+			return nil
+		}
+	case b.PublisherID == 0:
+		if by.Admin {
+			return nil
+		}
+
+		var publishers []int64
+		for id, members := range d.members {
+			if _, ok := members[by.UID]; ok {
+				publishers = append(publishers, id)
+			}
+		}
+
+		if len(publishers) > 1 {
+			return models.ErrPublisherRequired
+		}
+		if len(publishers) == 1 {
+			b.PublisherID = publishers[0]
+		}
+		return nil
+	}
+
+	return d.canWrite(by, b.PublisherID)
+}
+
+// write the columns of b and its credits, genres and work, a stored book
+// keeps the time it was listed and its cover unless b has a new one
+func (d *data) save(b *models.Book) {
+	stored, exist := d.books[b.ISBN]
+	now := time.Now()
+
+	r := &book{Book: *b, created: now, updated: now}
+	r.Covers, r.Contributors, r.Genres, r.Editions, r.Series = nil, nil, nil, nil, nil
+	if exist {
+		if r.Cover == "" {
+			r.Cover = stored.Cover
+		}
+		r.created = stored.created
+	}
+
+	seen := map[credit]bool{}
+	for _, c := range b.Credits() {
+		// book_authors has one row per author and role
+		cr := credit{authorID: d.resolveAuthor(c.Name), role: c.Role}
+		if !seen[cr] {
+			seen[cr] = true
+			r.credits = append(r.credits, cr)
+		}
+	}
+
+	for _, g := range genres(b) {
+		id := d.resolveGenre(g)
+		if !contains(r.genres, id) {
+			r.genres = append(r.genres, id)
+		}
+	}
+
+	// a book without a work keeps its own, or starts one
+	if b.WorkID == 0 && exist && stored.WorkID != 0 {
+		b.WorkID = stored.WorkID
+	} else if b.WorkID == 0 {
+		b.WorkID = d.nextID()
+		d.works[b.WorkID] = &work{title: b.Title}
+	}
+
+	r.WorkID = b.WorkID
+	d.books[b.ISBN] = r
+}
+
+// the genres of the book, the Book.Genre text when none are given
+func genres(b *models.Book) []*models.BookGenre {
+	if len(b.Genres) > 0 {
+		return b.Genres
+	}
+
+	if strings.TrimSpace(b.Genre) == "" {
+		return nil
+	}
+
+	return []*models.BookGenre{{Name: strings.TrimSpace(b.Genre)}}
+}
+
+// author of the name, created when unknown
+func (d *data) resolveAuthor(name string) int64 {
+	name = strings.Join(strings.Fields(name), " ")
+	key := strings.ToLower(name)
+	if id, ok := d.authors[key]; ok {
+		return id
+	}
+
+	id := d.nextID()
+	d.authors[key] = id
+	d.names[id] = name
+	return id
+}
+
+// genre of the slug, or of the name, created when unknown
+func (d *data) resolveGenre(g *models.BookGenre) int64 {
+	for _, stored := range d.genres {
+		if g.Slug != "" && same(stored.slug, g.Slug) || g.Slug == "" && same(stored.name, g.Name) {
+			return stored.id
+		}
+	}
+
+	name, slug := g.Name, g.Slug
+	if name == "" {
+		name = slug
+	}
+	if slug == "" {
+		slug = d.uniqueSlug(models.Slugify(name))
+	}
+
+	id := d.nextID()
+	d.genres = append(d.genres, &genre{id: id, name: name, slug: slug})
+	return id
+}
+
+// slug, or slug-2, slug-3, ... when it is taken
+func (d *data) uniqueSlug(slug string) string {
+	if slug == "" {
+		slug = "genre"
+	}
+
+	candidate := slug
+	for i := 2; d.slugTaken(candidate); i++ {
+		candidate = slug + "-" + strconv.Itoa(i)
+	}
+
+	return candidate
+}
+
+func (d *data) slugTaken(slug string) bool {
+	for _, g := range d.genres {
+		if same(g.slug, slug) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// whether the book is filed under the genre of the slug or name
+func (d *data) filedUnder(b *book, slugOrName string) bool {
+	for _, g := range d.genres {
+		if (same(g.slug, slugOrName) || same(g.name, slugOrName)) && contains(b.genres, g.id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrDuplicate if the isbn is taken
+func (s *Store) CreateBook(ctx context.Context, book *models.Book, by *models.Editor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.data.claimBook(by, book, false); err != nil {
+		return err
+	}
+
+	if _, ok := s.data.books[book.ISBN]; ok {
+		return ErrDuplicate
+	}
+
+	s.data.save(book)
+	return nil
+}
+
+func (s *Store) UpdateBook(ctx context.Context, book *models.Book, by *models.Editor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.data.claimBook(by, book, true); err != nil {
+		return err
+	}
+
+	s.data.save(book)
+	return nil
+}
+
+func (s *Store) DeleteBook(ctx context.Context, ISBN string, by *models.Editor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.data.checkOwner(by, ISBN); err != nil {
+		return err
+	}
+
+	delete(s.data.books, ISBN)
+	return nil
+}
+
+// batches of models.ImportBatchSize, a failed batch is rolled back and the
+// ones before it stay in
+func (s *Store) ImportBooks(ctx context.Context, books []*models.Book, upsert bool, by *models.Editor) (inserted, updated int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for start := 0; start < len(books); start += models.ImportBatchSize {
+		end := min(start+models.ImportBatchSize, len(books))
+
+		saved := s.data.clone()
+		ins, upd, err := s.data.importBatch(books[start:end], upsert, by)
+		if err != nil {
+			s.data = saved
+			return inserted, updated, err
+		}
+
+		inserted += ins
+		updated += upd
+	}
+
+	return inserted, updated, nil
+}
+
+func (d *data) importBatch(books []*models.Book, upsert bool, by *models.Editor) (inserted, updated int, err error) {
+	for _, b := range books {
+		_, exist := d.books[b.ISBN]
+		if err := d.claimBook(by, b, exist); err != nil {
+			return 0, 0, err
+		}
+
+		if exist && !upsert {
+			return 0, 0, ErrDuplicate
+		}
+
+		// a csv row has a single genre column
+		if stored, ok := d.books[b.ISBN]; ok && len(b.Genres) == 0 && strings.EqualFold(strings.TrimSpace(stored.Genre), strings.TrimSpace(b.Genre)) {
+			kept := *b
+			for _, g := range d.genres {
+				if contains(stored.genres, g.id) {
+					kept.Genres = append(kept.Genres, &models.BookGenre{Name: g.name, Slug: g.slug})
+				}
+			}
+			b = &kept
+		}
+
+		d.save(b)
+		if exist {
+			updated++
+		} else {
+			inserted++
+		}
+	}
+
+	return inserted, updated, nil
+}
+
+func (s *Store) BookExist(ctx context.Context, ISBN string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.data.books[ISBN]
+	return ok, nil
+}
+
+func (s *Store) FindBook(ctx context.Context, ISBN string) (*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.data.books[ISBN]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return s.read(b), nil
+}
+
+// the book of isbn, ErrNoRecord if isbn is unknown
+func (s *Store) GetBookByIsbn(ctx context.Context, ISBN string) ([]*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.data.books[ISBN]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return []*models.Book{s.read(b)}, nil
+}
+
+// the known books of isbns in the order of isbns
+func (s *Store) BooksByISBN(ctx context.Context, isbns []string) ([]*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	books := []*models.Book{}
+	for _, isbn := range isbns {
+		if b, ok := s.data.books[isbn]; ok {
+			books = append(books, s.read(b))
+		}
+	}
+
+	return books, nil
+}
+
+func (s *Store) BooksListing(ctx context.Context) ([]*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.readAll(s.data.find(func(*book) bool { return true })), nil
+}
+
+// credits of the book in order
+func (s *Store) Contributors(ctx context.Context, ISBN string) ([]*models.Contributor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	contributors := []*models.Contributor{}
+	if b, ok := s.data.books[ISBN]; ok {
+		for _, c := range b.credits {
+			contributors = append(contributors, &models.Contributor{AuthorID: c.authorID, Name: s.data.names[c.authorID], Role: c.role})
+		}
+	}
+
+	return contributors, nil
+}
+
+// genres the book is filed under, by name
+func (s *Store) BookGenres(ctx context.Context, ISBN string) ([]*models.BookGenre, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filed := []*models.BookGenre{}
+	if b, ok := s.data.books[ISBN]; ok {
+		for _, g := range s.data.genres {
+			if contains(b.genres, g.id) {
+				filed = append(filed, &models.BookGenre{Name: g.name, Slug: g.slug})
+			}
+		}
+	}
+
+	sort.SliceStable(filed, func(i, j int) bool {
+		return filed[i].Name < filed[j].Name
+	})
+	return filed, nil
+}
+
+// the books matching the genre and author of the filter, and its query as
+// FilterBooks does
+func (d *data) matches(b *book, filter models.BookFilter) bool {
+	if filter.Genre != "" && !d.filedUnder(b, filter.Genre) {
+		return false
+	}
+
+	if filter.Author != "" && !same(b.Author, filter.Author) {
+		return false
+	}
+
+	if q := strings.ToLower(filter.Query); q != "" {
+		return strings.Contains(strings.ToLower(b.Title), q) || strings.Contains(strings.ToLower(b.Author), q) || same(b.ISBN, filter.Query)
+	}
+
+	return true
+}
+
+// one page of books ordered by title, and the number of books matching the filter
+func (s *Store) FilterBooks(ctx context.Context, filter models.BookFilter, limit, offset int) ([]*models.Book, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	books := s.data.find(func(b *book) bool { return s.data.matches(b, filter) })
+	byTitle(books)
+
+	total := len(books)
+	books = books[min(offset, total):min(offset+limit, total)]
+	return s.readAll(books), total, nil
+}
+
+// newest books first, filter.Query is ignored
+func (s *Store) RecentBooks(ctx context.Context, filter models.BookFilter, limit int) ([]*models.BookEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filter.Query = ""
+	books := s.data.find(func(b *book) bool { return s.data.matches(b, filter) })
+	sort.SliceStable(books, func(i, j int) bool {
+		return books[i].created.After(books[j].created)
+	})
+
+	entries := []*models.BookEntry{}
+	for _, b := range books[:min(limit, len(books))] {
+		entries = append(entries, &models.BookEntry{Book: s.read(b), CreatedAt: b.created, UpdatedAt: b.updated})
+	}
+
+	return entries, nil
+}
+
+// books the user wrote a (not deleted) review for
+func (s *Store) ReviewedBooks(ctx context.Context, uid int64) ([]*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reviewed := map[string]bool{}
+	for _, r := range s.data.reviews {
+		if r.review.Uid == uid && !r.deleted {
+			reviewed[r.review.Isbn] = true
+		}
+	}
+
+	books := s.data.find(func(b *book) bool { return reviewed[b.ISBN] })
+	byTitle(books)
+	return s.readAll(books), nil
+}
+
+// every book with its last change, newest first
+func (s *Store) BookStamps(ctx context.Context) ([]*models.BookStamp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	books := s.data.find(func(*book) bool { return true })
+	sort.SliceStable(books, func(i, j int) bool {
+		return books[i].updated.After(books[j].updated)
+	})
+
+	stamps := []*models.BookStamp{}
+	for _, b := range books[:min(maxStamps, len(books))] {
+		stamps = append(stamps, &models.BookStamp{ISBN: b.ISBN, UpdatedAt: b.updated})
+	}
+
+	return stamps, nil
+}
+
+// genres with the number of their books, ordered by name
+func (s *Store) Genres(ctx context.Context) ([]*models.Facet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	facets := []*models.Facet{}
+	for _, g := range s.data.genres {
+		facet := &models.Facet{Name: g.name}
+		for _, b := range s.data.books {
+			if contains(b.genres, g.id) {
+				facet.Count++
+			}
+		}
+
+		if facet.Count > 0 {
+			facets = append(facets, facet)
+		}
+	}
+
+	return sortFacets(facets), nil
+}
+
+// Book.Author texts with the number of their books, ordered by name
+func (s *Store) Authors(ctx context.Context) ([]*models.Facet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]*models.Facet{}
+	facets := []*models.Facet{}
+	for _, b := range s.data.books {
+		key := strings.ToLower(b.Author)
+		if counts[key] == nil {
+			counts[key] = &models.Facet{Name: b.Author}
+			facets = append(facets, counts[key])
+		}
+		counts[key].Count++
+	}
+
+	return sortFacets(facets), nil
+}
+
+func sortFacets(facets []*models.Facet) []*models.Facet {
+	sort.SliceStable(facets, func(i, j int) bool {
+		return facets[i].Name < facets[j].Name
+	})
+
+	return facets
+}
+
+// bibliography of the author, once per role, ordered by title
+func (s *Store) AuthorBooks(ctx context.Context, authorID int64) ([]*models.AuthorBook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	books := s.data.find(func(*book) bool { return true })
+	byTitle(books)
+
+	entries := []*models.AuthorBook{}
+	for _, b := range books {
+		for _, c := range b.credits {
+			if c.authorID == authorID {
+				entries = append(entries, &models.AuthorBook{Book: s.read(b), Role: c.role})
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// the other editions of the work of isbn
+func (s *Store) Editions(ctx context.Context, ISBN string) ([]*models.Book, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.data.books[ISBN]
+	if !ok {
+		return []*models.Book{}, nil
+	}
+
+	editions := s.data.find(func(e *book) bool { return e.WorkID == b.WorkID && e.ISBN != ISBN })
+	sort.SliceStable(editions, func(i, j int) bool {
+		if editions[i].Language != editions[j].Language {
+			return editions[i].Language < editions[j].Language
+		}
+		return editions[i].Format < editions[j].Format
+	})
+
+	return s.readAll(editions), nil
+}
+
+// works of the series in order, each with all its editions
+func (s *Store) SeriesWorks(ctx context.Context, seriesID int64) ([]*models.Work, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	books := s.data.find(func(b *book) bool {
+		w := s.data.works[b.WorkID]
+		return w != nil && w.seriesID == seriesID && seriesID != 0
+	})
+
+	// works without a position come last
+	sort.SliceStable(books, func(i, j int) bool {
+		wi, wj := s.data.works[books[i].WorkID], s.data.works[books[j].WorkID]
+		if (wi.position == 0) != (wj.position == 0) {
+			return wj.position == 0
+		}
+		if wi.position != wj.position {
+			return wi.position < wj.position
+		}
+		return books[i].WorkID < books[j].WorkID
+	})
+
+	works := []*models.Work{}
+	for _, b := range books {
+		if n := len(works); n == 0 || works[n-1].ID != b.WorkID {
+			w := s.data.works[b.WorkID]
+			works = append(works, &models.Work{ID: b.WorkID, Title: w.title, SeriesID: seriesID, SeriesPosition: w.position})
+		}
+
+		last := works[len(works)-1]
+		last.Editions = append(last.Editions, s.read(b))
+	}
+
+	return works, nil
+}
+
+// series of the work of isbn with the neighbouring works, nil when the work
+// is not part of a series
+func (s *Store) SeriesPlace(ctx context.Context, ISBN string) (*models.SeriesPlace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.data.books[ISBN]
+	if !ok {
+		return nil, nil
+	}
+
+	w := s.data.works[b.WorkID]
+	if w == nil || s.data.series[w.seriesID] == nil {
+		return nil, nil
+	}
+
+	series := s.data.series[w.seriesID]
+	place := &models.SeriesPlace{ID: series.ID, Name: series.Name, Total: series.Total, Position: w.position}
+	if w.position == 0 {
+		return place, nil
+	}
+
+	place.Previous = s.neighbour(series.ID, w.position, -1)
+	place.Next = s.neighbour(series.ID, w.position, 1)
+	return place, nil
+}
+
+// first edition of the nearest placed work before (dir -1) or after (dir 1) position
+func (s *Store) neighbour(seriesID int64, position, dir int) *models.Book {
+	var nearest int64
+	best := 0
+	for id, w := range s.data.works {
+		if w.seriesID != seriesID || w.position == 0 || (w.position-position)*dir <= 0 {
+			continue
+		}
+
+		// ties between works at the same place go to the lowest id
+		d := (w.position - position) * dir
+		if nearest == 0 || d < best || d == best && id < nearest {
+			nearest, best = id, d
+		}
+	}
+
+	editions := s.data.find(func(b *book) bool { return nearest != 0 && b.WorkID == nearest })
+	if len(editions) == 0 {
+		return nil
+	}
+
+	return s.read(editions[0])
+}
+
+// ErrNoRecord if isbn is unknown
+func (s *Store) RecordSale(ctx context.Context, sale *models.Sale) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.books[sale.ISBN]; !ok {
+		return models.ErrNoRecord
+	}
+
+	if sale.SoldAt.IsZero() {
+		sale.SoldAt = time.Now()
+	}
+
+	c := *sale
+	s.data.sales = append(s.data.sales, &c)
+	return nil
+}
+
+// every book of the publisher with units sold, revenue and the (not deleted)
+// reviews since the given time
+func (s *Store) PublisherTitles(ctx context.Context, publisherID int64, since time.Time) ([]*models.TitleStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	books := s.data.find(func(b *book) bool { return b.PublisherID == publisherID && publisherID != 0 })
+	byTitle(books)
+
+	titles := []*models.TitleStats{}
+	for _, b := range books {
+		title := &models.TitleStats{Book: s.read(b)}
+		for _, sale := range s.data.sales {
+			if sale.ISBN == b.ISBN && !sale.SoldAt.Before(since) {
+				title.UnitsSold += sale.Quantity
+				title.Revenue += sale.Amount
+			}
+		}
+
+		var sum float64
+		for _, r := range s.data.reviews {
+			if r.review.Isbn == b.ISBN && !r.deleted && !r.created.Before(since) {
+				title.Reviews++
+				sum += float64(r.review.Rating)
+			}
+		}
+		if title.Reviews > 0 {
+			title.Rating = sum / float64(title.Reviews)
+		}
+
+		titles = append(titles, title)
+	}
+
+	return titles, nil
+}
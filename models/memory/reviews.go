@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"test.iamgak.net/models"
+)
+
+func (s *Store) CreateReview(ctx context.Context, r *models.Review) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.reviews = append(s.data.reviews, &review{id: s.data.nextID(), review: *r, created: time.Now()})
+	return nil
+}
+
+// soft delete, models.ErrNoRecord unless the review is of uid and not deleted yet
+func (s *Store) DeleteReview(ctx context.Context, id, uid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, r := range s.data.reviews {
+		if r.id == id && r.review.Uid == uid && !r.deleted {
+			c := *r
+			c.deleted = true
+			s.data.reviews[i] = &c
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+// the reviews that are not deleted and match keep, oldest first
+func (s *Store) reviews(keep func(r *models.Review) bool) []*models.Review {
+	reviews := []*models.Review{}
+	for _, r := range s.data.reviews {
+		if !r.deleted && keep(&r.review) {
+			c := r.review
+			reviews = append(reviews, &c)
+		}
+	}
+
+	return reviews
+}
+
+func (s *Store) ReviewListing(ctx context.Context) ([]*models.Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reviews(func(*models.Review) bool { return true }), nil
+}
+
+func (s *Store) MyReview(ctx context.Context, uid int64) ([]*models.Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reviews(func(r *models.Review) bool { return r.Uid == uid }), nil
+}
+
+func (s *Store) GetReviewByIsbn(ctx context.Context, isbn string) ([]*models.Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reviews(func(r *models.Review) bool { return r.Isbn == isbn }), nil
+}
+
+// reviews of every edition of the work of isbn
+func (s *Store) GetWorkReviews(ctx context.Context, isbn string) ([]*models.Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var work int64
+	if b, ok := s.data.books[isbn]; ok {
+		work = b.WorkID
+	}
+
+	return s.reviews(func(r *models.Review) bool {
+		if r.Isbn == isbn {
+			return true
+		}
+
+		b, ok := s.data.books[r.Isbn]
+		return ok && work != 0 && b.WorkID == work
+	}), nil
+}
+
+// average rating and number of reviews of a book
+func (s *Store) RatingSummary(ctx context.Context, isbn string) (float64, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reviews := s.reviews(func(r *models.Review) bool { return r.Isbn == isbn })
+	if len(reviews) == 0 {
+		return 0, 0, nil
+	}
+
+	var sum float64
+	for _, r := range reviews {
+		sum += float64(r.Rating)
+	}
+
+	return sum / float64(len(reviews)), len(reviews), nil
+}
+
+// newest reviews of a book first
+func (s *Store) RecentReviews(ctx context.Context, isbn string, limit int) ([]*models.ReviewEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := []*models.ReviewEntry{}
+	for _, r := range s.data.reviews {
+		if !r.deleted && r.review.Isbn == isbn {
+			c := r.review
+			entries = append(entries, &models.ReviewEntry{Review: &c, ID: r.id, CreatedAt: r.created})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].CreatedAt.Equal(entries[j].CreatedAt) {
+			return entries[i].CreatedAt.After(entries[j].CreatedAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+
+	return entries[:min(limit, len(entries))], nil
+}
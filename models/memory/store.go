@@ -0,0 +1,175 @@
+// Package memory keeps books, users and reviews in the memory of the process
+// with the semantics of the MySQL models: reviews are soft deleted, isbns and
+// emails are unique, reset links and activity entries are superseded by newer
+// ones, and the books are checked against their publisher. It backs the
+// handlers in tests and local runs without a database.
+package memory
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"test.iamgak.net/models"
+)
+
+// ErrDuplicate is returned for an isbn or email that is already taken, it is
+// a models.ErrConflict like the duplicate keys of the databases
+var ErrDuplicate = &models.Error{Kind: models.ErrConflict, Message: "duplicate entry"}
+
+// Store implements models.BookRepository, models.UserRepository and
+// models.ReviewRepository, the zero value is not usable, see New
+type Store struct {
+	mu        sync.Mutex
+	data      *data
+	coverURLs func(cover string) map[string]string
+	// bcrypt cost of the stored passwords
+	cost int
+}
+
+var (
+	_ models.BookRepository   = (*Store)(nil)
+	_ models.UserRepository   = (*Store)(nil)
+	_ models.ReviewRepository = (*Store)(nil)
+)
+
+// the tables, records are replaced and never changed in place so that a
+// shallow copy (see clone) is a snapshot an import batch can roll back to
+type data struct {
+	books   map[string]*book
+	authors map[string]int64 // by name
+	names   map[int64]string // by id
+	genres  []*genre         // in id order
+	works   map[int64]*work
+	series  map[int64]*models.Series
+	members map[int64]map[int64]string // publisher, uid, role
+	sales   []*models.Sale
+	users   map[int64]*user
+	resets  []*reset
+	log     []*logEntry
+	reviews []*review
+	lastID  int64
+}
+
+type book struct {
+	models.Book
+	credits []credit
+	genres  []int64
+	created time.Time
+	updated time.Time
+}
+
+type credit struct {
+	authorID int64
+	role     string
+}
+
+type genre struct {
+	id   int64
+	name string
+	slug string
+}
+
+type work struct {
+	title    string
+	seriesID int64
+	position int
+}
+
+type user struct {
+	id         int64
+	email      string
+	password   []byte
+	active     bool
+	activation string
+	loginToken string
+	role       string
+}
+
+type reset struct {
+	uid        int64
+	uri        string
+	superseded bool
+	created    time.Time
+}
+
+type logEntry struct {
+	uid        int64
+	activity   string
+	superseded bool
+}
+
+type review struct {
+	id      int64
+	review  models.Review
+	deleted bool
+	created time.Time
+}
+
+// New returns an empty store, the passwords are hashed with the lowest
+// bcrypt cost, it is meant for tests and local runs
+func New() *Store {
+	return &Store{
+		cost: 4,
+		data: &data{
+			books:   map[string]*book{},
+			authors: map[string]int64{},
+			names:   map[int64]string{},
+			works:   map[int64]*work{},
+			series:  map[int64]*models.Series{},
+			members: map[int64]map[int64]string{},
+			users:   map[int64]*user{},
+		},
+	}
+}
+
+// Models returns the models of the handlers with the books, users and reviews
+// kept in s, the other models still need the database
+func Models(s *Store) *models.Init {
+	m := models.Constructor(nil, nil, nil)
+	m.Books = s
+	m.Users = s
+	m.Review = s
+	return m
+}
+
+func (d *data) clone() *data {
+	c := *d
+	c.books = copyMap(d.books)
+	c.authors = copyMap(d.authors)
+	c.names = copyMap(d.names)
+	c.works = copyMap(d.works)
+	c.series = copyMap(d.series)
+	c.members = map[int64]map[int64]string{}
+	for id, members := range d.members {
+		c.members[id] = copyMap(members)
+	}
+	c.users = copyMap(d.users)
+	// appended to only, a longer slice of the same array leaves the copy as it was
+	c.genres = d.genres[:len(d.genres):len(d.genres)]
+	c.sales = d.sales[:len(d.sales):len(d.sales)]
+	c.resets = d.resets[:len(d.resets):len(d.resets)]
+	c.log = d.log[:len(d.log):len(d.log)]
+	c.reviews = d.reviews[:len(d.reviews):len(d.reviews)]
+	return &c
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
+
+// ids of every table come from one sequence, they only have to be unique
+func (d *data) nextID() int64 {
+	d.lastID++
+	return d.lastID
+}
+
+// MySQL compares the text columns case insensitively
+func same(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
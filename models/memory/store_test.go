@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+// the series and publishers routes need the database, these set them up in
+// the store directly
+
+func (s *Store) createSeries(name string, total int) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.data.nextID()
+	s.data.series[id] = &models.Series{ID: id, Name: name, Total: total}
+	return id
+}
+
+// the work at position (0 for none) in the series (0 for none)
+func (s *Store) placeWork(t *testing.T, workID, seriesID int64, position int) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.data.works[workID]
+	if !ok {
+		t.Fatalf("no work %d", workID)
+	}
+
+	s.data.works[workID] = &work{title: w.title, seriesID: seriesID, position: position}
+}
+
+// uid a member of the publisher with role, owner or editor
+func (s *Store) addMember(publisherID, uid int64, role string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := copyMap(s.data.members[publisherID])
+	members[uid] = role
+	s.data.members[publisherID] = members
+}
+
+var admin = &models.Editor{Admin: true}
+
+func createBook(t *testing.T, s *Store, book *models.Book, by *models.Editor) *models.Book {
+	t.Helper()
+	if err := s.CreateBook(context.Background(), book, by); err != nil {
+		t.Fatalf("CreateBook(%s): %v", book.ISBN, err)
+	}
+
+	return book
+}
+
+func TestSeriesPlace(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	first := createBook(t, s, &models.Book{ISBN: "978-0-00-000001-1", Title: "Dune", Author: "Frank Herbert", Genre: "Science Fiction"}, admin)
+	second := createBook(t, s, &models.Book{ISBN: "978-0-00-000002-8", Title: "Dune Messiah", Author: "Frank Herbert", Genre: "Science Fiction"}, admin)
+	third := createBook(t, s, &models.Book{ISBN: "978-0-00-000003-5", Title: "Children of Dune", Author: "Frank Herbert", Genre: "Science Fiction"}, admin)
+
+	series := s.createSeries("Dune Chronicles", 6)
+	s.placeWork(t, first.WorkID, series, 1)
+	s.placeWork(t, second.WorkID, series, 2)
+	s.placeWork(t, third.WorkID, series, 3)
+
+	place, err := s.SeriesPlace(ctx, second.ISBN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place == nil || place.Position != 2 || place.Total != 6 {
+		t.Fatalf("SeriesPlace = %+v, want position 2 of 6", place)
+	}
+	if place.Previous == nil || place.Previous.ISBN != first.ISBN {
+		t.Errorf("Previous = %+v, want %s", place.Previous, first.ISBN)
+	}
+	if place.Next == nil || place.Next.ISBN != third.ISBN {
+		t.Errorf("Next = %+v, want %s", place.Next, third.ISBN)
+	}
+
+	works, err := s.SeriesWorks(ctx, series)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{}
+	for _, w := range works {
+		titles = append(titles, w.Title)
+	}
+	if len(titles) != 3 || titles[0] != "Dune" || titles[2] != "Children of Dune" {
+		t.Errorf("SeriesWorks = %v, want the three works in order", titles)
+	}
+
+	if place, _ := s.SeriesPlace(ctx, "978-0-00-000009-7"); place != nil {
+		t.Errorf("SeriesPlace of an unknown isbn = %+v, want nil", place)
+	}
+}
+
+func TestPublisherBooks(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	const publisher = 7
+	member := &models.Editor{UID: 10}
+	other := &models.Editor{UID: 11}
+	s.addMember(publisher, member.UID, models.PublisherOwner)
+
+	book := createBook(t, s, &models.Book{ISBN: "978-0-00-000001-1", Title: "Dune", Author: "Frank Herbert", Genre: "Fiction"}, member)
+	if book.PublisherID != publisher {
+		t.Fatalf("PublisherID = %d, want the publisher of the member %d", book.PublisherID, publisher)
+	}
+
+	update := *book
+	update.Price = 12
+	if err := s.UpdateBook(ctx, &update, other); !errors.Is(err, models.ErrNotOwner) {
+		t.Errorf("UpdateBook by a non member = %v, want ErrNotOwner", err)
+	}
+	if err := s.DeleteBook(ctx, book.ISBN, other); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("DeleteBook by a non member = %v, want a forbidden error", err)
+	}
+	if err := s.UpdateBook(ctx, &update, member); err != nil {
+		t.Errorf("UpdateBook by the member: %v", err)
+	}
+	if err := s.DeleteBook(ctx, book.ISBN, member); err != nil {
+		t.Errorf("DeleteBook by the member: %v", err)
+	}
+	if err := s.DeleteBook(ctx, book.ISBN, admin); !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("DeleteBook of a deleted book = %v, want a not found error", err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	uid, err := s.InsertUser(ctx, "reader@example.com", "secret12", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.InsertUser(ctx, "READER@example.com", "secret12", "other"); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("InsertUser of a taken email = %v, want a conflict", err)
+	}
+
+	if _, err := s.Login(ctx, &models.UserLogin{Email: "reader@example.com", Password: "secret12"}); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("Login before the activation = %v, want ErrUserNotFound", err)
+	}
+	if err := s.AccountActivate(ctx, "unknown"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("AccountActivate of an unknown token = %v, want ErrNoRecord", err)
+	}
+	if err := s.AccountActivate(ctx, "token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Login(ctx, &models.UserLogin{Email: "reader@example.com", Password: "wrong"}); !errors.Is(err, models.ErrIncorrectPassword) {
+		t.Errorf("Login with a wrong password = %v, want ErrIncorrectPassword", err)
+	}
+	got, err := s.Login(ctx, &models.UserLogin{Email: " reader@example.com", Password: "secret12"})
+	if err != nil || got != uid {
+		t.Errorf("Login = %d, %v, want %d", got, err, uid)
+	}
+
+	for name, err := range map[string]error{
+		"Activate":      s.Activate(ctx, 99),
+		"SetRole":       s.SetRole(ctx, 99, "admin"),
+		"SetLoginToken": s.SetLoginToken(ctx, "token", 99),
+		"Logout":        s.Logout(ctx, 99),
+	} {
+		if !errors.Is(err, models.ErrUserNotFound) {
+			t.Errorf("%s of an unknown uid = %v, want ErrUserNotFound", name, err)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"test.iamgak.net/models"
+)
+
+// ErrDuplicate if the email is taken, the user is inactive until the hashed
+// activation token is used
+func (s *Store) InsertUser(ctx context.Context, email, password, hashed string) (int64, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.userByEmail(email) != nil {
+		return 0, ErrDuplicate
+	}
+
+	u := &user{id: s.data.nextID(), email: email, password: hash, activation: hashed, role: "user"}
+	s.data.users[u.id] = u
+	return u.id, nil
+}
+
+func (d *data) userByEmail(email string) *user {
+	for _, u := range d.users {
+		if same(u.email, email) {
+			return u
+		}
+	}
+
+	return nil
+}
+
+// change the user of uid, models.ErrUserNotFound when uid is unknown
+func (d *data) updateUser(uid int64, change func(u *user)) error {
+	u, ok := d.users[uid]
+	if !ok {
+		return models.ErrUserNotFound
+	}
+
+	c := *u
+	change(&c)
+	d.users[uid] = &c
+	return nil
+}
+
+func (s *Store) SetLoginToken(ctx context.Context, token string, uid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.updateUser(uid, func(u *user) { u.loginToken = token })
+}
+
+func (s *Store) Logout(ctx context.Context, uid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.updateUser(uid, func(u *user) { u.loginToken = "" })
+}
+
+// ErrUserNotFound unless the email is of an active user, ErrIncorrectPassword
+// for a wrong password
+func (s *Store) Login(ctx context.Context, creds *models.UserLogin) (int64, error) {
+	s.mu.Lock()
+	u := s.data.userByEmail(strings.TrimSpace(creds.Email))
+	s.mu.Unlock()
+
+	if u == nil || !u.active {
+		return 0, models.ErrUserNotFound
+	}
+
+	err := bcrypt.CompareHashAndPassword(u.password, []byte(creds.Password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, models.ErrIncorrectPassword
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return u.id, nil
+}
+
+// uid of the email, 0 when unknown
+func (s *Store) EmailExist(ctx context.Context, email string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u := s.data.userByEmail(email); u != nil {
+		return u.id, nil
+	}
+
+	return 0, nil
+}
+
+// uid of the login token, models.ErrUserNotFound when unknown
+func (s *Store) ValidUser(ctx context.Context, token string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.data.users {
+		if token != "" && u.loginToken == token {
+			return u.id, nil
+		}
+	}
+
+	return 0, models.ErrUserNotFound
+}
+
+// whether uri is the activation token of an inactive user
+func (s *Store) ValidURI(ctx context.Context, uri string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.data.users {
+		if uri != "" && u.activation == uri && !u.active {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// models.ErrNoRecord if no user has the activation token
+func (s *Store) AccountActivate(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.data.users {
+		if token != "" && u.activation == token {
+			return s.data.updateUser(u.id, func(u *user) {
+				u.activation = ""
+				u.active = true
+			})
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+// new reset link of the user, the earlier ones are superseded
+func (s *Store) ForgetPassword(ctx context.Context, uid int64, uri string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.supersedeResets(uid)
+	s.data.resets = append(s.data.resets, &reset{uid: uid, uri: uri, created: time.Now()})
+	return nil
+}
+
+func (d *data) supersedeResets(uid int64) {
+	for i, r := range d.resets {
+		if r.uid == uid && !r.superseded {
+			d.resets[i] = &reset{uid: r.uid, uri: r.uri, superseded: true, created: r.created}
+		}
+	}
+}
+
+// uid of a reset link that is not superseded, models.ErrNoRecord otherwise
+func (s *Store) ForgetPasswordUri(ctx context.Context, uri string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.data.resets {
+		if r.uri == uri && !r.superseded {
+			return r.uid, nil
+		}
+	}
+
+	return 0, models.ErrNoRecord
+}
+
+// change the password, every reset link of the user is superseded
+func (s *Store) NewPassword(ctx context.Context, newPassword string, id int64) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), s.cost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.data.updateUser(id, func(u *user) { u.password = hash })
+	s.data.supersedeResets(id)
+	s.mu.Unlock()
+
+	return s.ActivityLog(ctx, "password_changed", id)
+}
+
+// the latest entry of an activity supersedes the earlier ones of the user
+func (s *Store) ActivityLog(ctx context.Context, activity string, uid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, a := range s.data.log {
+		if a.uid == uid && a.activity == activity && !a.superseded {
+			c := *a
+			c.superseded = true
+			s.data.log[i] = &c
+		}
+	}
+
+	s.data.log = append(s.data.log, &logEntry{uid: uid, activity: activity})
+	return nil
+}
+
+// Activity returns the activities of the user that are not superseded, oldest
+// first, to check what a request logged
+func (s *Store) Activity(uid int64) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []string{}
+	for _, a := range s.data.log {
+		if a.uid == uid && !a.superseded {
+			list = append(list, a.activity)
+		}
+	}
+
+	return list
+}
+
+// false for an unknown uid
+func (s *Store) IsAdmin(ctx context.Context, uid int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.data.users[uid]
+	return ok && u.role == "admin", nil
+}
+
+func (s *Store) Activate(ctx context.Context, uid int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.updateUser(uid, func(u *user) {
+		u.activation = ""
+		u.active = true
+	})
+}
+
+// SetRole changes the role of the user, "admin" or "user", ErrUserNotFound if
+// uid is unknown
+func (s *Store) SetRole(ctx context.Context, uid int64, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.updateUser(uid, func(u *user) { u.role = role })
+}
+
+// reset links superseded or created before before, and with logins every
+// login token
+func (s *Store) PurgeTokens(ctx context.Context, before time.Time, logins bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	resets := []*reset{}
+	for _, r := range s.data.resets {
+		if r.superseded || r.created.Before(before) {
+			purged++
+			continue
+		}
+		resets = append(resets, r)
+	}
+	s.data.resets = resets
+
+	if logins {
+		for _, u := range s.data.users {
+			if u.loginToken != "" {
+				purged++
+				s.data.updateUser(u.id, func(u *user) { u.loginToken = "" })
+			}
+		}
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,459 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one version of the schema, Up applies it and Down reverts it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration with its row in schema_migrations, Migration
+// is nil for a version applied by a newer binary
+type MigrationStatus struct {
+	*Migration
+	Version   int64
+	Applied   bool
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// 000001_create_book_schema.up.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ReadMigrations reads the migrations of fsys in version order, every version
+// needs an up file, a missing down file reverts nothing
+func ReadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion, ups := map[int64]*Migration{}, map[int64]bool{}
+	for _, e := range entries {
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, mig.Name, match[2])
+		}
+
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			mig.Up, ups[version] = string(body), true
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if !ups[mig.Version] {
+			return nil, fmt.Errorf("migration %06d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies the migrations to db and keeps the applied versions in
+// schema_migrations. A run holds a lock of the database so two servers
+// starting together do not both migrate: GET_LOCK in MySQL, an advisory lock
+// in PostgreSQL and the write lock of the file in SQLite (it waits for the
+// busy timeout of the DSN instead of LockTimeout).
+type Migrator struct {
+	db         *DB
+	migrations []*Migration
+	// time to wait for the lock held by another run
+	LockTimeout time.Duration
+}
+
+func NewMigrator(db *DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := ReadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations, LockTimeout: time.Minute}, nil
+}
+
+// the bookkeeping statements run on a single connection, Status uses the pool
+type migrationConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// key of the PostgreSQL advisory lock, the lock is per database
+const migrationLockID = 7261826
+
+// fn runs on one connection holding the lock, in SQLite inside a transaction
+// that is rolled back when fn fails
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch m.db.dialect {
+	case MySQL:
+		var got sql.NullInt64
+		seconds := int(math.Ceil(m.LockTimeout.Seconds()))
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(CONCAT(DATABASE(), '.schema_migrations'), ?)", seconds).Scan(&got); err != nil {
+			return err
+		}
+		if got.Int64 != 1 {
+			return ErrMigrationLocked
+		}
+		defer conn.QueryRowContext(context.Background(), "SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.schema_migrations'))").Scan(&got)
+	case Postgres:
+		deadline := time.Now().Add(m.LockTimeout)
+		for {
+			var got bool
+			if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockID).Scan(&got); err != nil {
+				return err
+			}
+			if got {
+				break
+			}
+			if time.Now().After(deadline) {
+				return ErrMigrationLocked
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+	case SQLite:
+		if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+			return fmt.Errorf("%w: %v", ErrMigrationLocked, err)
+		}
+		defer func() {
+			if err != nil {
+				conn.ExecContext(context.Background(), "ROLLBACK")
+				return
+			}
+			_, err = conn.ExecContext(ctx, "COMMIT")
+		}()
+	}
+
+	if err := m.createTable(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) createTable(ctx context.Context, conn migrationConn) error {
+	stmt := "CREATE TABLE IF NOT EXISTS `schema_migrations` (`version` bigint NOT NULL PRIMARY KEY, `dirty` smallint NOT NULL DEFAULT 0, `applied_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP)"
+	_, err := conn.ExecContext(ctx, m.db.rebind(stmt))
+	return err
+}
+
+// rows of schema_migrations by version
+func (m *Migrator) applied(ctx context.Context, conn migrationConn) (map[int64]*MigrationStatus, error) {
+	rows, err := conn.QueryContext(ctx, m.db.rebind("SELECT `version`, `dirty`, `applied_at` FROM `schema_migrations`"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]*MigrationStatus{}
+	for rows.Next() {
+		s := &MigrationStatus{Applied: true}
+		if err := rows.Scan(&s.Version, &s.Dirty, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[s.Version] = s
+	}
+
+	return applied, rows.Err()
+}
+
+// the applied versions for Up and Down, which refuse to run after a failed one
+func (m *Migrator) clean(ctx context.Context, conn migrationConn) (map[int64]*MigrationStatus, error) {
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range applied {
+		if s.Dirty {
+			return nil, fmt.Errorf("version %d: %w", s.Version, ErrDirtyMigration)
+		}
+	}
+
+	return applied, nil
+}
+
+// Up applies n pending migrations in version order, every pending one when n
+// is 0 or less, and returns the applied ones
+func (m *Migrator) Up(ctx context.Context, n int) ([]*Migration, error) {
+	done := []*Migration{}
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.clean(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if applied[mig.Version] != nil {
+				continue
+			}
+			if n > 0 && len(done) == n {
+				break
+			}
+
+			if err := m.run(ctx, conn, mig, true); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the n latest applied migrations, every one when n is 0 or
+// less, and returns the reverted ones
+func (m *Migrator) Down(ctx context.Context, n int) ([]*Migration, error) {
+	done := []*Migration{}
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := m.clean(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if applied[mig.Version] == nil {
+				continue
+			}
+			if n > 0 && len(done) == n {
+				break
+			}
+
+			if err := m.run(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// run applies or reverts mig. Its row is dirty while the statements run, so a
+// failure that MySQL can not roll back (DDL commits on its own) stops the next
+// runs until the schema is repaired by hand and the version is forced
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, mig *Migration, up bool) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("migration %06d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}()
+
+	if m.db.dialect == Postgres {
+		if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				conn.ExecContext(context.Background(), "ROLLBACK")
+				return
+			}
+			_, err = conn.ExecContext(ctx, "COMMIT")
+		}()
+	}
+
+	body, mark, clean := mig.Down, "UPDATE `schema_migrations` SET `dirty` = 1 WHERE `version` = ?", "DELETE FROM `schema_migrations` WHERE `version` = ?"
+	if up {
+		body, mark, clean = mig.Up, "INSERT INTO `schema_migrations` (`version`, `dirty`) VALUES (?, 1)", "UPDATE `schema_migrations` SET `dirty` = 0 WHERE `version` = ?"
+	}
+
+	if _, err := conn.ExecContext(ctx, m.db.rebind(mark), mig.Version); err != nil {
+		return err
+	}
+
+	for _, stmt := range m.statements(body) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, m.db.rebind(clean), mig.Version)
+	return err
+}
+
+// the statements of a migration file, the MySQL driver runs one statement per
+// call, the SQLite and PostgreSQL drivers run the file at once
+func (m *Migrator) statements(body string) []string {
+	if m.db.dialect != MySQL {
+		if strings.TrimSpace(body) == "" {
+			return nil
+		}
+		return []string{body}
+	}
+
+	return splitStatements(body)
+}
+
+// statements separated by ";" without the comments, ";" inside quotes does
+// not separate
+func splitStatements(body string) []string {
+	var (
+		stmts []string
+		b     strings.Builder
+		quote byte
+	)
+
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		b.Reset()
+	}
+
+	// the bytes looked at are ASCII, they are never part of a UTF-8 sequence
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			b.WriteByte(c)
+			if c == '\\' && i+1 < len(body) {
+				i++
+				b.WriteByte(body[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			b.WriteByte(c)
+		case c == '#' || strings.HasPrefix(body[i:], "--"):
+			if end := strings.IndexByte(body[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(body)
+			}
+			b.WriteByte('\n')
+		case strings.HasPrefix(body[i:], "/*"):
+			if end := strings.Index(body[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(body)
+			}
+			b.WriteByte(' ')
+		case c == ';':
+			flush()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	flush()
+
+	return stmts
+}
+
+// Status lists every migration and every applied version in version order
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	if err := m.createTable(ctx, m.db.DB); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx, m.db.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*MigrationStatus{}
+	for _, mig := range m.migrations {
+		s := applied[mig.Version]
+		if s == nil {
+			s = &MigrationStatus{Version: mig.Version}
+		}
+		s.Migration = mig
+		delete(applied, mig.Version)
+		list = append(list, s)
+	}
+
+	// applied by a newer binary
+	for _, s := range applied {
+		list = append(list, s)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Force records the schema at version without running anything: the
+// migrations up to it are applied, the later ones are not and none is dirty.
+// For a schema repaired by hand after a failed migration, or loaded from a
+// dump, version 0 forgets every migration
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	known := version == 0
+	for _, mig := range m.migrations {
+		known = known || mig.Version == version
+	}
+	if !known {
+		return fmt.Errorf("migration %d: %w", version, ErrNoRecord)
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		if _, err := conn.ExecContext(ctx, m.db.rebind("DELETE FROM `schema_migrations` WHERE `version` > ?"), version); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, m.db.rebind("UPDATE `schema_migrations` SET `dirty` = 0 WHERE `dirty` = 1")); err != nil {
+			return err
+		}
+
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if mig.Version > version || applied[mig.Version] != nil {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, m.db.rebind("INSERT INTO `schema_migrations` (`version`, `dirty`) VALUES (?, 0)"), mig.Version); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
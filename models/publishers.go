@@ -0,0 +1,257 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// roles of publisher_members, owners manage the members as well as the books
+const (
+	PublisherOwner  = "owner"
+	PublisherEditor = "editor"
+)
+
+type Publisher struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// role of the logged in user, when listing their publishers
+	Role string `json:"role,omitempty"`
+}
+
+type Member struct {
+	UID   int64  `json:"uid"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// who writes a book, admins may write every book and the members of a
+// publisher the books of that publisher
+type Editor struct {
+	UID   int64
+	Admin bool
+}
+
+type PublisherModel struct {
+	db *DB
+}
+
+// publisher_id of a stored book, 0 when it has none, ErrNoRecord if isbn is unknown
+func ownerOf(ctx context.Context, q queryer, ISBN string) (int64, error) {
+	var publisher sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT `publisher_id` FROM `books` WHERE `isbn` = ?", ISBN).Scan(&publisher)
+	if err == sql.ErrNoRows {
+		return 0, ErrNoRecord
+	}
+
+	return publisher.Int64, err
+}
+
+// ErrNotOwner unless by may write the books of publisher, books without a
+// publisher are left to the admins
+func canWrite(ctx context.Context, q queryer, by *Editor, publisher int64) error {
+	if by.Admin {
+		return nil
+	}
+
+	if publisher == 0 {
+		return ErrNotOwner
+	}
+
+	var valid int
+	err := q.QueryRowContext(ctx, "SELECT 1 FROM `publisher_members` WHERE `publisher_id` = ? AND `uid` = ?", publisher, by.UID).Scan(&valid)
+	if err == sql.ErrNoRows {
+		return ErrNotOwner
+	}
+
+	return err
+}
+
+// check by may write the stored book of isbn, ErrNoRecord if isbn is unknown
+func checkOwner(ctx context.Context, q queryer, by *Editor, ISBN string) error {
+	publisher, err := ownerOf(ctx, q, ISBN)
+	if err != nil {
+		return err
+	}
+
+	return canWrite(ctx, q, by, publisher)
+}
+
+// check by may write book and settle Book.PublisherID: a stored book keeps its
+// publisher unless PublisherID moves it, a new book of a member goes to their
+// publisher, and one of anybody else is listed without a publisher
+func claimBook(ctx context.Context, q queryer, by *Editor, book *Book, stored bool) error {
+	switch {
+	case stored:
+		current, err := ownerOf(ctx, q, book.ISBN)
+		if err != nil {
+			return err
+		}
+
+		if err := canWrite(ctx, q, by, current); err != nil {
+			return err
+		}
+
+		if book.PublisherID == 0 || book.PublisherID == current {
+			book.PublisherID = current
+			return nil
+		}
+	case book.PublisherID == 0:
+		if by.Admin {
+			return nil
+		}
+
+		var n int
+		err := q.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(MIN(`publisher_id`), 0) FROM `publisher_members` WHERE `uid` = ?", by.UID).Scan(&n, &book.PublisherID)
+		if err != nil {
+			return err
+		}
+
+		if n > 1 {
+			return ErrPublisherRequired
+		}
+		return nil
+	}
+
+	return canWrite(ctx, q, by, book.PublisherID)
+}
+
+func (m *PublisherModel) PublisherExist(ctx context.Context, id int64) (bool, error) {
+	return exists(ctx, m.db, "SELECT 1 FROM `publishers` WHERE `id` = ?", id)
+}
+
+// new publisher with owner as its first member
+func (m *PublisherModel) CreatePublisher(ctx context.Context, publisher *Publisher, owner int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rollback if we encounter an error before commit
+
+	publisher.ID, err = tx.insertID(ctx, "INSERT INTO `publishers` (`name`) VALUES (?)", publisher.Name)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO `publisher_members` (`publisher_id`,`uid`,`role`) VALUES (?,?,?)", publisher.ID, owner, PublisherOwner)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ErrNoRecord if id is unknown
+func (m *PublisherModel) GetPublisher(ctx context.Context, id int64) (*Publisher, error) {
+	publisher := &Publisher{}
+	err := m.db.QueryRowContext(ctx, "SELECT `id`, `name` FROM `publishers` WHERE `id` = ?", id).Scan(&publisher.ID, &publisher.Name)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return publisher, nil
+}
+
+// publishers the user is a member of, with their role
+func (m *PublisherModel) UserPublishers(ctx context.Context, uid int64) ([]*Publisher, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT p.`id`, p.`name`, pm.`role` FROM `publishers` p JOIN `publisher_members` pm ON pm.`publisher_id` = p.`id` WHERE pm.`uid` = ? ORDER BY p.`name`", uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	publishers := []*Publisher{}
+	for rows.Next() {
+		publisher := &Publisher{}
+		if err := rows.Scan(&publisher.ID, &publisher.Name, &publisher.Role); err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, publisher)
+	}
+
+	return publishers, rows.Err()
+}
+
+// role of the user in the publisher, empty when not a member
+func (m *PublisherModel) MemberRole(ctx context.Context, publisherID, uid int64) (string, error) {
+	var role string
+	err := m.db.QueryRowContext(ctx, "SELECT `role` FROM `publisher_members` WHERE `publisher_id` = ? AND `uid` = ?", publisherID, uid).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return role, err
+}
+
+func (m *PublisherModel) Members(ctx context.Context, publisherID int64) ([]*Member, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT u.`id`, u.`email`, pm.`role` FROM `publisher_members` pm JOIN `users` u ON u.`id` = pm.`uid` WHERE pm.`publisher_id` = ? ORDER BY pm.`role` DESC, u.`email`", publisherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*Member{}
+	for rows.Next() {
+		member := &Member{}
+		if err := rows.Scan(&member.UID, &member.Email, &member.Role); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// add the user to the publisher or change their role, ErrLastOwner when the
+// only owner would become an editor
+func (m *PublisherModel) SetMember(ctx context.Context, publisherID, uid int64, role string) error {
+	if role != PublisherOwner {
+		if err := m.keepOwner(ctx, publisherID, uid); err != nil {
+			return err
+		}
+	}
+
+	_, err := m.db.ExecContext(ctx, "INSERT INTO `publisher_members` (`publisher_id`,`uid`,`role`) VALUES (?,?,?)"+m.db.upsert([]string{"publisher_id", "uid"}, "role"), publisherID, uid, role)
+	return err
+}
+
+// ErrNoRecord if the user is not a member, ErrLastOwner for the only owner
+func (m *PublisherModel) RemoveMember(ctx context.Context, publisherID, uid int64) error {
+	if err := m.keepOwner(ctx, publisherID, uid); err != nil {
+		return err
+	}
+
+	result, err := m.db.ExecContext(ctx, "DELETE FROM `publisher_members` WHERE `publisher_id` = ? AND `uid` = ?", publisherID, uid)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// ErrLastOwner when uid is the only owner left in the publisher
+func (m *PublisherModel) keepOwner(ctx context.Context, publisherID, uid int64) error {
+	var others, self int
+	stmt := "SELECT COALESCE(SUM(CASE WHEN `uid` <> ? THEN 1 ELSE 0 END), 0), COALESCE(SUM(CASE WHEN `uid` = ? THEN 1 ELSE 0 END), 0) FROM `publisher_members` WHERE `publisher_id` = ? AND `role` = ?"
+	err := m.db.QueryRowContext(ctx, stmt, uid, uid, publisherID, PublisherOwner).Scan(&others, &self)
+	if err != nil {
+		return err
+	}
+
+	if self > 0 && others == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"test.iamgak.net/cache"
+	"test.iamgak.net/recommend"
+)
+
+// cache keys of the lists written by the recommendations job
+const (
+	recommendUserKey    = "recommend:user:"
+	recommendSimilarKey = "recommend:similar:"
+	recommendPopularKey = "recommend:popular"
+)
+
+// isbn and feature of every genre (g12) and credited author (a7) of a book
+const bookFeatures = "SELECT `isbn`, CONCAT('g', `genre_id`) AS `feature` FROM `book_genres` UNION ALL SELECT `isbn`, CONCAT('a', `author_id`) FROM `book_authors`"
+
+// lists computed by the recommendations job
+type Recommendations struct {
+	Users   map[int64][]recommend.Scored
+	Similar recommend.Neighbours
+	Popular []recommend.Scored
+}
+
+type RecommendModel struct {
+	db    *DB
+	cache cache.Cache
+}
+
+// every (not deleted) rating, a reader who reviewed a book twice counts once
+// with the mean of the ratings
+func (m *RecommendModel) Ratings(ctx context.Context) ([]recommend.Rating, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT `uid`, `isbn`, AVG(`rating`) FROM `reviews` WHERE `is_deleted` = 0 GROUP BY `uid`, `isbn`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []recommend.Rating{}
+	for rows.Next() {
+		var r recommend.Rating
+		if err := rows.Scan(&r.UID, &r.ISBN, &r.Score); err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, r)
+	}
+
+	return ratings, rows.Err()
+}
+
+// isbn -> genre and author features of every book
+func (m *RecommendModel) BookFeatures(ctx context.Context) (map[string][]string, error) {
+	rows, err := m.db.QueryContext(ctx, bookFeatures)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	features := map[string][]string{}
+	for rows.Next() {
+		var isbn, feature string
+		if err := rows.Scan(&isbn, &feature); err != nil {
+			return nil, err
+		}
+		features[isbn] = append(features[isbn], feature)
+	}
+
+	return features, rows.Err()
+}
+
+// write every list to the cache, they expire after ttl unless the job runs again
+func (m *RecommendModel) SaveRecommendations(ctx context.Context, recs *Recommendations, ttl time.Duration) error {
+	set := func(key string, list []recommend.Scored) error {
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+
+		return m.cache.Set(ctx, key, data, ttl)
+	}
+
+	for uid, list := range recs.Users {
+		if err := set(recommendUserKey+strconv.FormatInt(uid, 10), list); err != nil {
+			return err
+		}
+	}
+
+	for isbn, list := range recs.Similar {
+		if err := set(recommendSimilarKey+isbn, list); err != nil {
+			return err
+		}
+	}
+
+	return set(recommendPopularKey, recs.Popular)
+}
+
+// list of the last job run for the reader, nil when the reader had no ratings then
+func (m *RecommendModel) ForUser(ctx context.Context, uid int64) ([]recommend.Scored, error) {
+	return m.list(ctx, recommendUserKey+strconv.FormatInt(uid, 10))
+}
+
+// list of the last job run for the book, nil when the book was not known then
+func (m *RecommendModel) Similar(ctx context.Context, ISBN string) ([]recommend.Scored, error) {
+	return m.list(ctx, recommendSimilarKey+ISBN)
+}
+
+// best rated books of the last job run, for readers without ratings
+func (m *RecommendModel) Popular(ctx context.Context) ([]recommend.Scored, error) {
+	return m.list(ctx, recommendPopularKey)
+}
+
+func (m *RecommendModel) list(ctx context.Context, key string) ([]recommend.Scored, error) {
+	val, err := m.cache.Get(ctx, key)
+	if errors.Is(err, cache.ErrMiss) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list []recommend.Scored
+	err = json.Unmarshal(val, &list)
+	return list, err
+}
+
+// books sharing the most genres and authors with the book of isbn, straight
+// from the db for books newer than the last job run
+func (m *RecommendModel) SimilarByMetadata(ctx context.Context, ISBN string, limit int) ([]recommend.Scored, error) {
+	stmt := "SELECT f.`isbn`, COUNT(*) AS `shared` FROM (" + bookFeatures + ") f JOIN (" + bookFeatures + ") t ON t.`feature` = f.`feature` AND t.`isbn` = ?" +
+		" WHERE f.`isbn` <> ? GROUP BY f.`isbn` ORDER BY `shared` DESC, f.`isbn` LIMIT ?"
+	rows, err := m.db.QueryContext(ctx, stmt, ISBN, ISBN, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []recommend.Scored{}
+	for rows.Next() {
+		var s recommend.Scored
+		if err := rows.Scan(&s.ISBN, &s.Score); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+
+	return list, rows.Err()
+}
+
+// books of the isbns in the same order, unknown isbns are left out
+func (m *BookModel) BooksByISBN(ctx context.Context, isbns []string) ([]*Book, error) {
+	if len(isbns) == 0 {
+		return []*Book{}, nil
+	}
+
+	args := make([]any, len(isbns))
+	for i, isbn := range isbns {
+		args[i] = isbn
+	}
+
+	stmt := "SELECT " + bookColumns + " FROM `books` WHERE `isbn` IN (?" + strings.Repeat(",?", len(isbns)-1) + ")"
+	found, err := m.scanBooks(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byISBN := map[string]*Book{}
+	for _, book := range found {
+		byISBN[book.ISBN] = book
+	}
+
+	books := []*Book{}
+	for _, isbn := range isbns {
+		if book, ok := byISBN[isbn]; ok {
+			books = append(books, book)
+		}
+	}
+
+	return books, nil
+}
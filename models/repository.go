@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// BookRepository is what the handlers need of the books, BookModel keeps them
+// in MySQL and memory.Store in the memory of the process
+type BookRepository interface {
+	// fills Book.Covers of every book read
+	SetCoverURLs(fn func(cover string) map[string]string)
+	CreateBook(ctx context.Context, book *Book, by *Editor) error
+	UpdateBook(ctx context.Context, book *Book, by *Editor) error
+	DeleteBook(ctx context.Context, ISBN string, by *Editor) error
+	ImportBooks(ctx context.Context, books []*Book, upsert bool, by *Editor) (inserted, updated int, err error)
+	BookExist(ctx context.Context, ISBN string) (bool, error)
+	FindBook(ctx context.Context, ISBN string) (*Book, error)
+	GetBookByIsbn(ctx context.Context, ISBN string) ([]*Book, error)
+	BooksByISBN(ctx context.Context, isbns []string) ([]*Book, error)
+	BooksListing(ctx context.Context) ([]*Book, error)
+	Contributors(ctx context.Context, ISBN string) ([]*Contributor, error)
+	BookGenres(ctx context.Context, ISBN string) ([]*BookGenre, error)
+	FilterBooks(ctx context.Context, filter BookFilter, limit, offset int) ([]*Book, int, error)
+	RecentBooks(ctx context.Context, filter BookFilter, limit int) ([]*BookEntry, error)
+	ReviewedBooks(ctx context.Context, uid int64) ([]*Book, error)
+	BookStamps(ctx context.Context) ([]*BookStamp, error)
+	Genres(ctx context.Context) ([]*Facet, error)
+	Authors(ctx context.Context) ([]*Facet, error)
+	AuthorBooks(ctx context.Context, authorID int64) ([]*AuthorBook, error)
+	Editions(ctx context.Context, ISBN string) ([]*Book, error)
+	SeriesWorks(ctx context.Context, seriesID int64) ([]*Work, error)
+	SeriesPlace(ctx context.Context, ISBN string) (*SeriesPlace, error)
+	RecordSale(ctx context.Context, sale *Sale) error
+	PublisherTitles(ctx context.Context, publisherID int64, since time.Time) ([]*TitleStats, error)
+}
+
+type UserRepository interface {
+	// hashed is the activation token of the new (inactive) user
+	InsertUser(ctx context.Context, email, password, hashed string) (int64, error)
+	AccountActivate(ctx context.Context, token string) error
+	ValidURI(ctx context.Context, uri string) (bool, error)
+	EmailExist(ctx context.Context, email string) (int64, error)
+	Login(ctx context.Context, creds *UserLogin) (int64, error)
+	SetLoginToken(ctx context.Context, token string, uid int64) error
+	ValidUser(ctx context.Context, token string) (int64, error)
+	Logout(ctx context.Context, uid int64) error
+	ForgetPassword(ctx context.Context, uid int64, uri string) error
+	ForgetPasswordUri(ctx context.Context, uri string) (int64, error)
+	NewPassword(ctx context.Context, newPassword string, id int64) error
+	ActivityLog(ctx context.Context, activity string, uid int64) error
+	IsAdmin(ctx context.Context, uid int64) (bool, error)
+	// the command line tools
+	Activate(ctx context.Context, uid int64) error
+	SetRole(ctx context.Context, uid int64, role string) error
+	PurgeTokens(ctx context.Context, before time.Time, logins bool) (int64, error)
+}
+
+type ReviewRepository interface {
+	CreateReview(ctx context.Context, review *Review) error
+	// soft delete, the review stays in the db
+	DeleteReview(ctx context.Context, id, uid int64) error
+	ReviewListing(ctx context.Context) ([]*Review, error)
+	MyReview(ctx context.Context, uid int64) ([]*Review, error)
+	GetReviewByIsbn(ctx context.Context, isbn string) ([]*Review, error)
+	GetWorkReviews(ctx context.Context, isbn string) ([]*Review, error)
+	RatingSummary(ctx context.Context, isbn string) (float64, int, error)
+	RecentReviews(ctx context.Context, isbn string, limit int) ([]*ReviewEntry, error)
+}
+
+var (
+	_ BookRepository   = (*BookModel)(nil)
+	_ UserRepository   = (*UserModel)(nil)
+	_ ReviewRepository = (*ReviewModel)(nil)
+)
@@ -3,8 +3,9 @@ package models
 import (
 	"context"
 	"database/sql"
-	"github.com/redis/go-redis/v9"
-	"strconv"
+	"time"
+
+	"test.iamgak.net/cache"
 )
 
 type Review struct {
@@ -17,38 +18,48 @@ type Review struct {
 }
 
 type ReviewModel struct {
-	db     *sql.DB
-	redis  *redis.Client
-	ctx    context.Context
-	cancel context.CancelFunc
+	db    *DB
+	cache *cache.Loader
 }
 
 // create new review
-func (m *ReviewModel) CreateReview(review *Review) error {
-	_, err := m.db.Exec("INSERT INTO `reviews` (`isbn`,`price`,`title`,`rating`,`descriptions`,`uid`) VALUES (?,?,?,?,?,? )", &review.Isbn, &review.Price, &review.Title, &review.Rating, &review.Descriptions, &review.Uid)
-	return err
+func (m *ReviewModel) CreateReview(ctx context.Context, review *Review) error {
+	_, err := m.db.ExecContext(ctx, "INSERT INTO `reviews` (`isbn`,`price`,`title`,`rating`,`descriptions`,`uid`) VALUES (?,?,?,?,?,? )", &review.Isbn, &review.Price, &review.Title, &review.Rating, &review.Descriptions, &review.Uid)
+	if err != nil {
+		return err
+	}
+
+	m.cache.Invalidate(ctx, TagReviews)
+	return nil
 }
 
-func (m *ReviewModel) DeleteReview(id, uid int64) error {
-	_, err := m.db.Exec("UPDATE `reviews` SET is_deleted = 1 WHERE  `id` = ? AND uid = ? ", id, uid)
-	return err
+// ErrNoRecord unless the review is one of uid that is not deleted yet
+func (m *ReviewModel) DeleteReview(ctx context.Context, id, uid int64) error {
+	result, err := m.db.ExecContext(ctx, "UPDATE `reviews` SET is_deleted = 1 WHERE  `id` = ? AND uid = ? AND is_deleted = 0", id, uid)
+	if err := affected(result, err, ErrNoRecord); err != nil {
+		return err
+	}
+
+	m.cache.Invalidate(ctx, TagReviews)
+	return nil
 }
 
-func (m *ReviewModel) ReviewListing() ([]*Review, error) {
+func (m *ReviewModel) ReviewListing(ctx context.Context) ([]*Review, error) {
 	stmt := "SELECT isbn, title, rating, price, descriptions, uid FROM `reviews` WHERE is_deleted = 0"
-	reviews, err := m.Listing(stmt)
-	return reviews, err
+	return cached(ctx, m.cache, "reviews", reviewCacheTTL, []string{TagReviews}, func(ctx context.Context) ([]*Review, error) {
+		return m.Listing(ctx, stmt)
+	})
 }
 
 // if user logged it will show its review
-func (m *ReviewModel) MyReview(uid int64) ([]*Review, error) {
-	stmt := "SELECT isbn, title, rating, price, descriptions, uid FROM reviews WHERE is_deleted = 0 AND  uid = '" + strconv.Itoa(int(uid)) + "'"
-	reviews, err := m.Listing(stmt)
+func (m *ReviewModel) MyReview(ctx context.Context, uid int64) ([]*Review, error) {
+	stmt := "SELECT isbn, title, rating, price, descriptions, uid FROM reviews WHERE is_deleted = 0 AND  uid = ?"
+	reviews, err := m.Listing(ctx, stmt, uid)
 	return reviews, err
 }
 
-func (m *ReviewModel) Listing(stmt string) ([]*Review, error) {
-	rows, err := m.db.Query(stmt)
+func (m *ReviewModel) Listing(ctx context.Context, stmt string, args ...any) ([]*Review, error) {
+	rows, err := m.db.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -72,10 +83,20 @@ func (m *ReviewModel) Listing(stmt string) ([]*Review, error) {
 	return reviews, err
 }
 
-func (m *ReviewModel) GetReviewByIsbn(isbn string) ([]*Review, error) {
-	stmt := "SELECT isbn, title, rating, price, descriptions, uid FROM reviews WHERE isbn = '" + isbn + "' AND is_deleted = 0"
-	review, err := m.Listing(stmt)
-	return review, err
+func (m *ReviewModel) GetReviewByIsbn(ctx context.Context, isbn string) ([]*Review, error) {
+	stmt := "SELECT isbn, title, rating, price, descriptions, uid FROM reviews WHERE isbn = ? AND is_deleted = 0"
+	return cached(ctx, m.cache, "reviews:"+isbn, reviewCacheTTL, []string{TagReviews}, func(ctx context.Context) ([]*Review, error) {
+		return m.Listing(ctx, stmt, isbn)
+	})
+}
+
+// reviews of every edition of the work of isbn
+func (m *ReviewModel) GetWorkReviews(ctx context.Context, isbn string) ([]*Review, error) {
+	stmt := "SELECT isbn, title, rating, price, descriptions, uid FROM reviews WHERE (isbn = ? OR isbn IN (SELECT `isbn` FROM `books` WHERE `work_id` = (SELECT `work_id` FROM `books` WHERE `isbn` = ?))) AND is_deleted = 0"
+	// the editions of the work change with the books
+	return cached(ctx, m.cache, "reviews:work:"+isbn, reviewCacheTTL, []string{TagReviews, TagBooks}, func(ctx context.Context) ([]*Review, error) {
+		return m.Listing(ctx, stmt, isbn, isbn)
+	})
 }
 
 func (m *ReviewModel) ScanReviewData(rows *sql.Rows) (*Review, error) {
@@ -89,3 +110,46 @@ func (m *ReviewModel) ScanReviewData(rows *sql.Rows) (*Review, error) {
 		&review.Uid)
 	return review, err
 }
+
+// average rating and number of reviews of a book
+func (m *ReviewModel) RatingSummary(ctx context.Context, isbn string) (float64, int, error) {
+	var avg sql.NullFloat64
+	var count int
+	err := m.db.QueryRowContext(ctx, "SELECT AVG(rating), COUNT(*) FROM reviews WHERE isbn = ? AND is_deleted = 0", isbn).Scan(&avg, &count)
+	return avg.Float64, count, err
+}
+
+// review with its id and time, for the feeds
+type ReviewEntry struct {
+	*Review
+	ID        int64
+	CreatedAt time.Time
+}
+
+// newest reviews of a book first
+func (m *ReviewModel) RecentReviews(ctx context.Context, isbn string, limit int) ([]*ReviewEntry, error) {
+	stmt := "SELECT id, isbn, title, rating, price, descriptions, uid, created_at FROM reviews WHERE isbn = ? AND is_deleted = 0 ORDER BY created_at DESC, id DESC LIMIT ?"
+	rows, err := m.db.QueryContext(ctx, stmt, isbn, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []*ReviewEntry{}
+	for rows.Next() {
+		// created_at is nullable in reviews
+		var created sql.NullTime
+		e := &ReviewEntry{Review: new(Review)}
+		err := rows.Scan(&e.ID, &e.Isbn, &e.Title, &e.Rating, &e.Price, &e.Descriptions, &e.Uid, &created)
+		if err != nil {
+			return nil, err
+		}
+
+		e.CreatedAt = created.Time
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
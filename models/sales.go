@@ -0,0 +1,68 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type Sale struct {
+	ISBN     string  `json:"isbn"`
+	Quantity int     `json:"quantity"`
+	Amount   float64 `json:"amount"`
+	// now when zero
+	SoldAt time.Time `json:"sold_at"`
+}
+
+// a title of the publisher dashboard with its sales and reviews
+type TitleStats struct {
+	*Book
+	UnitsSold int     `json:"units_sold"`
+	Revenue   float64 `json:"revenue"`
+	Reviews   int     `json:"reviews"`
+	Rating    float64 `json:"rating"`
+}
+
+// ErrNoRecord if isbn is unknown
+func (m *BookModel) RecordSale(ctx context.Context, sale *Sale) error {
+	ok, err := m.BookExist(ctx, sale.ISBN)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoRecord
+	}
+
+	if sale.SoldAt.IsZero() {
+		sale.SoldAt = time.Now()
+	}
+
+	_, err = m.db.ExecContext(ctx, "INSERT INTO `sales` (`isbn`,`quantity`,`amount`,`sold_at`) VALUES (?,?,?,?)", sale.ISBN, sale.Quantity, sale.Amount, sale.SoldAt)
+	return err
+}
+
+// every book of the publisher with units sold, revenue and the (not deleted)
+// reviews since the given time
+func (m *BookModel) PublisherTitles(ctx context.Context, publisherID int64, since time.Time) ([]*TitleStats, error) {
+	// sales and reviews carry an isbn as well, the derived table keeps bookColumns unambiguous
+	stmt := "SELECT " + bookColumns + ", `units`, `revenue`, `reviews`, `rating` FROM (" +
+		"SELECT b.*, COALESCE(s.`units`, 0) AS `units`, COALESCE(s.`revenue`, 0) AS `revenue`, COALESCE(r.`reviews`, 0) AS `reviews`, COALESCE(r.`rating`, 0) AS `rating` FROM `books` b" +
+		" LEFT JOIN (SELECT `isbn`, SUM(`quantity`) AS `units`, SUM(`amount`) AS `revenue` FROM `sales` WHERE `sold_at` >= ? GROUP BY `isbn`) s ON s.`isbn` = b.`isbn`" +
+		" LEFT JOIN (SELECT `isbn`, COUNT(*) AS `reviews`, AVG(`rating`) AS `rating` FROM `reviews` WHERE `is_deleted` = 0 AND `created_at` >= ? GROUP BY `isbn`) r ON r.`isbn` = b.`isbn`" +
+		" WHERE b.`publisher_id` = ?) t ORDER BY `title`, `isbn`"
+	rows, err := m.db.QueryContext(ctx, stmt, since, since, publisherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := []*TitleStats{}
+	for rows.Next() {
+		title := &TitleStats{Book: &Book{}}
+		if err := m.scanBook(rows, title.Book, &title.UnitsSold, &title.Revenue, &title.Reviews, &title.Rating); err != nil {
+			return nil, err
+		}
+		titles = append(titles, title)
+	}
+
+	return titles, rows.Err()
+}
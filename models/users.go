@@ -3,12 +3,16 @@ package models
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"strings"
+	"time"
 
-	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// roles of users.role, admins run the catalog tools
+var Roles = []string{"user", "admin"}
+
 type UserLogin struct {
 	Email    string
 	Password string
@@ -31,60 +35,51 @@ type UserNewPassword struct {
 
 // to use main db that initialised in main.go
 type UserModel struct {
-	db     *sql.DB
-	redis  *redis.Client
-	ctx    context.Context
-	cancel context.CancelFunc
+	db *DB
 }
 
-func (m *UserModel) InsertUser(email, password, hashed string) (int64, error) {
+func (m *UserModel) InsertUser(ctx context.Context, email, password, hashed string) (int64, error) {
 	HashedPassword, err := m.GeneratePassword(password)
 	if err != nil {
 		return 0, err
 	}
 
-	result, err := m.db.Exec("INSERT INTO users(`email`,`password`,`activation_token`) VALUES (?, ?,? )", email, string(HashedPassword), hashed)
-	if err != nil {
-		return 0, err
-	}
-	return result.LastInsertId()
+	return m.db.insertID(ctx, "INSERT INTO users(`email`,`password`,`activation_token`) VALUES (?, ?,? )", email, string(HashedPassword), hashed)
 }
 
-func (m *UserModel) SetLoginToken(token string, uid int64) error {
-	_, err := m.db.Exec("UPDATE `users` SET `login_token` = ? WHERE `id` = ?", token, uid)
-	return err
+// ErrUserNotFound if uid is unknown
+func (m *UserModel) SetLoginToken(ctx context.Context, token string, uid int64) error {
+	result, err := m.db.ExecContext(ctx, "UPDATE `users` SET `login_token` = ? WHERE `id` = ?", token, uid)
+	return affected(result, err, ErrUserNotFound)
 }
 
-// logout
-func (m *UserModel) Logout(uid int64) error {
-	_, err := m.db.Exec("UPDATE `users` SET `login_token` = NULL WHERE `id` = ?", uid)
-	if err != nil {
-		return err
-	}
-	return nil
+// logout, ErrUserNotFound if uid is unknown
+func (m *UserModel) Logout(ctx context.Context, uid int64) error {
+	result, err := m.db.ExecContext(ctx, "UPDATE `users` SET `login_token` = NULL WHERE `id` = ?", uid)
+	return affected(result, err, ErrUserNotFound)
 }
 
-func (m *UserModel) Login(creds *UserLogin) (int64, error) {
+func (m *UserModel) Login(ctx context.Context, creds *UserLogin) (int64, error) {
 	var databasePassword string
 	var uid int64
 
 	// Begin a transaction (optional)
-	tx, err := m.db.Begin()
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
 	defer tx.Rollback() // Rollback if we encounter an error before commit
 
 	// Query for active user by email
-	err = tx.QueryRow("SELECT password, id FROM `users` WHERE `active` = 1 AND `email` = ?", strings.TrimSpace(creds.Email)).
+	err = tx.QueryRowContext(ctx, "SELECT password, id FROM `users` WHERE `active` = 1 AND `email` = ?", strings.TrimSpace(creds.Email)).
 		Scan(&databasePassword, &uid)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			// No user found with the given email
 			return 0, ErrUserNotFound
 		}
 		// Other database error
-		return 0, err
+		return 0, dbError(err)
 	}
 
 	// Compare passwords
@@ -107,65 +102,80 @@ func (m *UserModel) Login(creds *UserLogin) (int64, error) {
 	return uid, nil
 }
 
-func (m *UserModel) EmailExist(email string) int64 {
+// id of the user with the email, 0 if nobody registered it
+func (m *UserModel) EmailExist(ctx context.Context, email string) (int64, error) {
 	var uid int64
-	_ = m.db.QueryRow("SELECT `id` FROM `users` WHERE  `email` = ?", email).Scan(&uid)
-	return uid
-}
+	err := m.db.QueryRowContext(ctx, "SELECT `id` FROM `users` WHERE  `email` = ?", email).Scan(&uid)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
 
-func (m *UserModel) ValidUser(token string) (int64, error) {
-	var id int64
-	err := m.db.QueryRow("SELECT `id` FROM `users` WHERE `login_token` = ? ", token).Scan(&id)
-	return id, err
+	return uid, dbError(err)
 }
 
-func (m *UserModel) ValidURI(uri string) bool {
-	var exists int64
-	query := "SELECT 1 FROM users WHERE activation_token = ? AND active = 0"
-	err := m.db.QueryRow(query, uri).Scan(&exists)
-	if err != nil {
-		return false
+// user of the login token, ErrUserNotFound if it is unknown or logged out
+func (m *UserModel) ValidUser(ctx context.Context, token string) (int64, error) {
+	var id int64
+	err := m.db.QueryRowContext(ctx, "SELECT `id` FROM `users` WHERE `login_token` = ? ", token).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrUserNotFound
 	}
 
-	return exists > 0
+	return id, dbError(err)
 }
 
-func (m *UserModel) AccountActivate(token string) error {
-	_, err := m.db.Exec("UPDATE `users` SET `activation_token` = NULL, `active` = 1 WHERE `activation_token` = ? ", token)
-	return err
+func (m *UserModel) ValidURI(ctx context.Context, uri string) (bool, error) {
+	return exists(ctx, m.db, "SELECT 1 FROM users WHERE activation_token = ? AND active = 0", uri)
+}
+
+// ErrNoRecord if no user has the activation token
+func (m *UserModel) AccountActivate(ctx context.Context, token string) error {
+	result, err := m.db.ExecContext(ctx, "UPDATE `users` SET `activation_token` = NULL, `active` = 1 WHERE `activation_token` = ? ", token)
+	return affected(result, err, ErrNoRecord)
 }
 
-func (m *UserModel) ForgetPassword(uid int64, uri string) error {
-	_, _ = m.db.Exec("UPDATE `forget_passw` SET `superseded` = 1 WHERE `uid` = ?", uid)
-	_, err := m.db.Exec("INSERT INTO `forget_passw` (`uid`,`uri`,`superseded`) VALUES(?,?,0) ", uid, uri)
+func (m *UserModel) ForgetPassword(ctx context.Context, uid int64, uri string) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE `forget_passw` SET `superseded` = 1 WHERE `uid` = ?", uid)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, "INSERT INTO `forget_passw` (`uid`,`uri`,`superseded`) VALUES(?,?,0) ", uid, uri)
 	return err
 }
 
-func (m *UserModel) ForgetPasswordUri(uri string) (int64, error) {
+// user of the reset link, ErrNoRecord if it is unknown or superseded
+func (m *UserModel) ForgetPasswordUri(ctx context.Context, uri string) (int64, error) {
 	var result int64
-	err := m.db.QueryRow("SELECT uid FROM `forget_passw` WHERE `uri` = ? AND `superseded` = 0", uri).Scan(&result)
+	err := m.db.QueryRowContext(ctx, "SELECT uid FROM `forget_passw` WHERE `uri` = ? AND `superseded` = 0", uri).Scan(&result)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNoRecord
+	}
 	if err != nil {
-		return 0, err
+		return 0, dbError(err)
 	}
 
 	return result, nil
 }
 
-func (m *UserModel) NewPassword(newPassword string, id int64) error {
+func (m *UserModel) NewPassword(ctx context.Context, newPassword string, id int64) error {
 	newHashedPassword, err := m.GeneratePassword(newPassword)
 	if err != nil {
 		return err
 	}
 
 	stmt := "UPDATE users SET password = ? WHERE id = ?"
-	_, err = m.db.Exec(stmt, string(newHashedPassword), id)
+	_, err = m.db.ExecContext(ctx, stmt, string(newHashedPassword), id)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, "UPDATE `forget_passw` SET `superseded` =1 WHERE `uid` = ?", id)
 	if err != nil {
 		return err
 	}
 
-	_, _ = m.db.Exec("UPDATE `forget_passw` SET `superseded` =1 WHERE `uid` = ?", id)
-	m.ActivityLog("password_changed", id)
-	return nil
+	return m.ActivityLog(ctx, "password_changed", id)
 }
 
 func (m *UserModel) GeneratePassword(newPassword string) ([]byte, error) {
@@ -173,7 +183,62 @@ func (m *UserModel) GeneratePassword(newPassword string) ([]byte, error) {
 	return newHashedPassword, err
 }
 
-func (m *UserModel) ActivityLog(activity string, uid int64) {
-	_, _ = m.db.Exec("UPDATE `user_log` SET superseded = 1 WHERE activity = ? AND uid = ?", activity, uid)
-	_, _ = m.db.Exec("INSERT INTO `user_log` SET  activity = ? , uid = ?, superseded = 0", activity, uid)
+func (m *UserModel) ActivityLog(ctx context.Context, activity string, uid int64) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE `user_log` SET superseded = 1 WHERE activity = ? AND uid = ?", activity, uid)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.ExecContext(ctx, "INSERT INTO `user_log` (`activity`,`uid`,`superseded`) VALUES (?,?,0)", activity, uid)
+	return err
+}
+
+// admin only routes like bulk book import
+func (m *UserModel) IsAdmin(ctx context.Context, uid int64) (bool, error) {
+	var role string
+	err := m.db.QueryRowContext(ctx, "SELECT `role` FROM `users` WHERE `id` = ?", uid).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, dbError(err)
+	}
+
+	return role == "admin", nil
+}
+
+// activate without the activation token, for the command line, ErrUserNotFound
+// if uid is unknown
+func (m *UserModel) Activate(ctx context.Context, uid int64) error {
+	result, err := m.db.ExecContext(ctx, "UPDATE `users` SET `activation_token` = NULL, `active` = 1 WHERE `id` = ?", uid)
+	return affected(result, err, ErrUserNotFound)
+}
+
+// role is one of Roles, ErrUserNotFound if uid is unknown
+func (m *UserModel) SetRole(ctx context.Context, uid int64, role string) error {
+	result, err := m.db.ExecContext(ctx, "UPDATE `users` SET `role` = ? WHERE `id` = ?", role, uid)
+	return affected(result, err, ErrUserNotFound)
+}
+
+// PurgeTokens deletes the reset links that are superseded or older than
+// before, with logins every login token is dropped as well and every user
+// has to log in again. It returns the number of links and tokens removed.
+func (m *UserModel) PurgeTokens(ctx context.Context, before time.Time, logins bool) (int64, error) {
+	result, err := m.db.ExecContext(ctx, "DELETE FROM `forget_passw` WHERE `superseded` = 1 OR `created_at` < ?", before)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil || !logins {
+		return purged, err
+	}
+
+	result, err = m.db.ExecContext(ctx, "UPDATE `users` SET `login_token` = NULL WHERE `login_token` IS NOT NULL")
+	if err != nil {
+		return purged, err
+	}
+
+	n, err := result.RowsAffected()
+	return purged + n, err
 }
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserCRUD(t *testing.T) {
+	eachDB(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		m := Constructor(db, nil, nil)
+		users := m.Users
+
+		uid, err := users.InsertUser(ctx, "reader@example.com", "secret12", "activation")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := users.InsertUser(ctx, "READER@example.com", "secret12", "other"); !errors.Is(err, ErrConflict) {
+			t.Errorf("InsertUser of a taken email = %v, want a conflict", err)
+		}
+		if got, err := users.EmailExist(ctx, "reader@example.com"); err != nil || got != uid {
+			t.Errorf("EmailExist = %d, %v, want %d", got, err, uid)
+		}
+
+		creds := &UserLogin{Email: "reader@example.com", Password: "secret12"}
+		if _, err := users.Login(ctx, creds); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("Login before the activation = %v, want ErrUserNotFound", err)
+		}
+		if valid, err := users.ValidURI(ctx, "activation"); err != nil || !valid {
+			t.Errorf("ValidURI = %v, %v, want true", valid, err)
+		}
+		if err := users.AccountActivate(ctx, "unknown"); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("AccountActivate of an unknown token = %v, want ErrNoRecord", err)
+		}
+		if err := users.AccountActivate(ctx, "activation"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := users.Login(ctx, &UserLogin{Email: creds.Email, Password: "wrong"}); !errors.Is(err, ErrIncorrectPassword) {
+			t.Errorf("Login with a wrong password = %v, want ErrIncorrectPassword", err)
+		}
+		if got, err := users.Login(ctx, creds); err != nil || got != uid {
+			t.Fatalf("Login = %d, %v, want %d", got, err, uid)
+		}
+
+		if err := users.SetLoginToken(ctx, "login-token", uid); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := users.ValidUser(ctx, "login-token"); err != nil || got != uid {
+			t.Errorf("ValidUser = %d, %v, want %d", got, err, uid)
+		}
+		// the same role again is not an unknown user
+		for i := 0; i < 2; i++ {
+			if err := users.SetRole(ctx, uid, "admin"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if admin, err := users.IsAdmin(ctx, uid); err != nil || !admin {
+			t.Errorf("IsAdmin = %v, %v, want true", admin, err)
+		}
+		if err := users.Logout(ctx, uid); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := users.ValidUser(ctx, "login-token"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("ValidUser after the logout = %v, want ErrUserNotFound", err)
+		}
+
+		for name, err := range map[string]error{
+			"Activate":      users.Activate(ctx, 99),
+			"SetRole":       users.SetRole(ctx, 99, "admin"),
+			"SetLoginToken": users.SetLoginToken(ctx, "token", 99),
+			"Logout":        users.Logout(ctx, 99),
+		} {
+			if !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("%s of an unknown uid = %v, want ErrUserNotFound", name, err)
+			}
+		}
+	})
+}
+
+func TestPasswordReset(t *testing.T) {
+	eachDB(t, func(t *testing.T, db *DB) {
+		ctx := context.Background()
+		m := Constructor(db, nil, nil)
+		users := m.Users
+
+		uid, err := users.InsertUser(ctx, "reader@example.com", "secret12", "activation")
+		if err == nil {
+			err = users.Activate(ctx, uid)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, uri := range []string{"first", "second"} {
+			if err := users.ForgetPassword(ctx, uid, uri); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := users.ForgetPasswordUri(ctx, "first"); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("ForgetPasswordUri of a superseded link = %v, want ErrNoRecord", err)
+		}
+		if got, err := users.ForgetPasswordUri(ctx, "second"); err != nil || got != uid {
+			t.Fatalf("ForgetPasswordUri = %d, %v, want %d", got, err, uid)
+		}
+
+		if err := users.NewPassword(ctx, "changed1", uid); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := users.ForgetPasswordUri(ctx, "second"); !errors.Is(err, ErrNoRecord) {
+			t.Errorf("ForgetPasswordUri after the change = %v, want ErrNoRecord", err)
+		}
+		if got, err := users.Login(ctx, &UserLogin{Email: "reader@example.com", Password: "changed1"}); err != nil || got != uid {
+			t.Errorf("Login with the new password = %d, %v, want %d", got, err, uid)
+		}
+
+		n, err := users.PurgeTokens(ctx, time.Now().Add(time.Hour), false)
+		if err != nil || n != 2 {
+			t.Errorf("PurgeTokens = %d, %v, want the two links", n, err)
+		}
+	})
+}
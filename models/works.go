@@ -0,0 +1,223 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// edition formats of books.format
+var Formats = []string{"hardcover", "paperback", "ebook", "audiobook"}
+
+// a work is what the editions (hardcover, paperback, e-book, translations)
+// have in common, it can be part of a series
+type Work struct {
+	ID             int64   `json:"id"`
+	Title          string  `json:"title"`
+	SeriesID       int64   `json:"series_id,omitempty"`
+	SeriesPosition int     `json:"series_position,omitempty"`
+	Editions       []*Book `json:"editions,omitempty"`
+}
+
+type Series struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// planned number of works, 0 when open ended
+	Total int     `json:"total,omitempty"`
+	Works []*Work `json:"works,omitempty"`
+}
+
+// "book 3 of 7", with the first edition of the works before and after
+type SeriesPlace struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Total    int    `json:"total,omitempty"`
+	Previous *Book  `json:"previous,omitempty"`
+	Next     *Book  `json:"next,omitempty"`
+}
+
+type WorkModel struct {
+	db *DB
+}
+
+// put the book in Book.WorkID, or keep it in its work, a book without one
+// starts a work of its own
+func setWork(ctx context.Context, q queryer, book *Book) error {
+	if book.WorkID == 0 {
+		var work sql.NullInt64
+		err := q.QueryRowContext(ctx, "SELECT `work_id` FROM `books` WHERE `isbn` = ?", book.ISBN).Scan(&work)
+		if err != nil {
+			return err
+		}
+
+		if work.Valid {
+			book.WorkID = work.Int64
+			return nil
+		}
+
+		book.WorkID, err = q.insertID(ctx, "INSERT INTO `works` (`title`) VALUES (?)", book.Title)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := q.ExecContext(ctx, "UPDATE `books` SET `work_id` = ? WHERE `isbn` = ?", book.WorkID, book.ISBN)
+	return err
+}
+
+func (m *WorkModel) WorkExist(ctx context.Context, id int64) (bool, error) {
+	return exists(ctx, m.db, "SELECT 1 FROM `works` WHERE `id` = ?", id)
+}
+
+func (m *WorkModel) SeriesExist(ctx context.Context, id int64) (bool, error) {
+	return exists(ctx, m.db, "SELECT 1 FROM `series` WHERE `id` = ?", id)
+}
+
+// title and place in a series (SeriesID 0 takes it out), ErrNoRecord if id is unknown
+func (m *WorkModel) UpdateWork(ctx context.Context, work *Work) error {
+	ok, err := m.WorkExist(ctx, work.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoRecord
+	}
+
+	_, err = m.db.ExecContext(ctx, "UPDATE `works` SET `title` = ?, `series_id` = NULLIF(?, 0), `series_position` = NULLIF(?, 0) WHERE `id` = ?", work.Title, work.SeriesID, work.SeriesPosition, work.ID)
+	return err
+}
+
+func (m *WorkModel) CreateSeries(ctx context.Context, series *Series) error {
+	var err error
+	series.ID, err = m.db.insertID(ctx, "INSERT INTO `series` (`name`,`total`) VALUES (?,NULLIF(?, 0))", series.Name, series.Total)
+	return err
+}
+
+// ErrNoRecord if id is unknown
+func (m *WorkModel) UpdateSeries(ctx context.Context, series *Series) error {
+	ok, err := m.SeriesExist(ctx, series.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNoRecord
+	}
+
+	_, err = m.db.ExecContext(ctx, "UPDATE `series` SET `name` = ?, `total` = NULLIF(?, 0) WHERE `id` = ?", series.Name, series.Total, series.ID)
+	return err
+}
+
+// series with name and total, ErrNoRecord if id is unknown
+func (m *WorkModel) GetSeries(ctx context.Context, id int64) (*Series, error) {
+	series := &Series{}
+	var total sql.NullInt64
+	err := m.db.QueryRowContext(ctx, "SELECT `id`, `name`, `total` FROM `series` WHERE `id` = ?", id).Scan(&series.ID, &series.Name, &total)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	series.Total = int(total.Int64)
+	return series, nil
+}
+
+// the other editions of the work of isbn
+func (m *BookModel) Editions(ctx context.Context, ISBN string) ([]*Book, error) {
+	stmt := "SELECT " + bookColumns + " FROM `books` WHERE `work_id` = (SELECT `work_id` FROM `books` WHERE `isbn` = ?) AND `isbn` <> ? ORDER BY `language`, `format`, `isbn`"
+	return m.scanBooks(ctx, stmt, ISBN, ISBN)
+}
+
+// works of the series in order, each with all its editions
+func (m *BookModel) SeriesWorks(ctx context.Context, seriesID int64) ([]*Work, error) {
+	// works has a title as well, the derived table keeps bookColumns unambiguous
+	stmt := "SELECT " + bookColumns + ", `work_title`, COALESCE(`work_position`, 0) FROM (" +
+		"SELECT b.*, w.`title` AS `work_title`, w.`series_position` AS `work_position` FROM `books` b JOIN `works` w ON w.`id` = b.`work_id` WHERE w.`series_id` = ?" +
+		") e ORDER BY `work_position` IS NULL, `work_position`, `work_id`, `isbn`"
+	rows, err := m.db.QueryContext(ctx, stmt, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	works := []*Work{}
+	for rows.Next() {
+		book := &Book{}
+		work := &Work{SeriesID: seriesID}
+		if err := m.scanBook(rows, book, &work.Title, &work.SeriesPosition); err != nil {
+			return nil, err
+		}
+
+		if n := len(works); n > 0 && works[n-1].ID == book.WorkID {
+			work = works[n-1]
+		} else {
+			work.ID = book.WorkID
+			works = append(works, work)
+		}
+		work.Editions = append(work.Editions, book)
+	}
+
+	return works, rows.Err()
+}
+
+// series of the work of isbn with the neighbouring works, nil when the work
+// is not part of a series
+func (m *BookModel) SeriesPlace(ctx context.Context, ISBN string) (*SeriesPlace, error) {
+	place := &SeriesPlace{}
+	var position, total sql.NullInt64
+	stmt := "SELECT s.`id`, s.`name`, s.`total`, w.`series_position` FROM `books` b JOIN `works` w ON w.`id` = b.`work_id` JOIN `series` s ON s.`id` = w.`series_id` WHERE b.`isbn` = ?"
+	err := m.db.QueryRowContext(ctx, stmt, ISBN).Scan(&place.ID, &place.Name, &total, &position)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	place.Total = int(total.Int64)
+	place.Position = int(position.Int64)
+	if !position.Valid {
+		return place, nil
+	}
+
+	if place.Previous, err = m.neighbour(ctx, place.ID, place.Position, "<", "DESC"); err != nil {
+		return nil, err
+	}
+
+	if place.Next, err = m.neighbour(ctx, place.ID, place.Position, ">", "ASC"); err != nil {
+		return nil, err
+	}
+
+	return place, nil
+}
+
+// first edition of the nearest work before (<, DESC) or after (>, ASC) position
+func (m *BookModel) neighbour(ctx context.Context, seriesID int64, position int, cmp, order string) (*Book, error) {
+	stmt := "SELECT " + bookColumns + " FROM `books` WHERE `work_id` = (SELECT `id` FROM `works` WHERE `series_id` = ? AND `series_position` " + cmp + " ? ORDER BY `series_position` " + order + " LIMIT 1) ORDER BY `isbn` LIMIT 1"
+	books, err := m.scanBooks(ctx, stmt, seriesID, position)
+	if err != nil || len(books) == 0 {
+		return nil, err
+	}
+
+	return books[0], nil
+}
+
+func (m *BookModel) scanBooks(ctx context.Context, stmt string, args ...any) ([]*Book, error) {
+	rows, err := m.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := []*Book{}
+	for rows.Next() {
+		book := &Book{}
+		if err := m.scanBook(rows, book); err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, rows.Err()
+}
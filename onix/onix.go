@@ -0,0 +1,469 @@
+// Package onix reads ONIX for Books 3.0 product feeds and maps every product
+// to a models.Book. Only the reference tag names (<Product>, <ProductIdentifier>
+// ...) are understood, short tags (<product>, <productidentifier>) are not.
+package onix
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"test.iamgak.net/models"
+)
+
+var ErrRelease = errors.New("onix: only ONIX 3.0 messages are supported")
+
+// code lists used by the mapping, see the EDItEUR ONIX code lists
+const (
+	notificationUpdate = "04" // block update, only the supplied blocks change
+	notificationDelete = "05"
+	idTypeISBN13       = "15" // list 5
+	titleTypeDistinct  = "01" // list 15
+	titleElementProd   = "01" // list 149, product level
+	roleAuthor         = "A01"
+	textMainDesc       = "03" // list 153
+	textShortDesc      = "02"
+	priceTypeRRP       = "01" // list 58, recommended retail price excluding tax
+)
+
+// consumer price types of list 58 a book is priced with, the preferred first:
+// RRP and fixed retail price including tax, then both excluding tax. Trade
+// prices (05 and on) are not what readers pay.
+var retailPriceTypes = []string{"02", "04", priceTypeRRP, "03"}
+
+type Feed struct {
+	Release string
+	Sender  string
+	Records []*Record
+}
+
+// one ONIX <Product> mapped to a book
+type Record struct {
+	Reference    string
+	Notification string
+	// NotificationType 05, the book should be removed
+	Delete bool
+	// NotificationType 04, blank fields of Book keep their stored value
+	Partial bool
+	// Book.Price is a retail price in the currency of Parse, without one a
+	// full record has no price to store
+	Priced bool
+	Book   *models.Book
+	// also set as Book.Contributors
+	Contributors []*models.Contributor
+	Subjects     []string
+	// element paths of the product that have no place in models.Book
+	Unmapped []string
+}
+
+type message struct {
+	Release  string    `xml:"release,attr"`
+	Sender   string    `xml:"Header>Sender>SenderName"`
+	Products []product `xml:"Product"`
+}
+
+type product struct {
+	RecordReference   string              `xml:"RecordReference"`
+	NotificationType  string              `xml:"NotificationType"`
+	Identifiers       []productIdentifier `xml:"ProductIdentifier"`
+	DescriptiveDetail descriptiveDetail   `xml:"DescriptiveDetail"`
+	CollateralDetail  collateralDetail    `xml:"CollateralDetail"`
+	ProductSupply     []productSupply     `xml:"ProductSupply"`
+	Other             []anyElement        `xml:",any"`
+}
+
+type productIdentifier struct {
+	Type  string `xml:"ProductIDType"`
+	Value string `xml:"IDValue"`
+}
+
+type descriptiveDetail struct {
+	TitleDetails []titleDetail `xml:"TitleDetail"`
+	Contributors []contributor `xml:"Contributor"`
+	Subjects     []subject     `xml:"Subject"`
+	Other        []anyElement  `xml:",any"`
+}
+
+type titleDetail struct {
+	Type     string         `xml:"TitleType"`
+	Elements []titleElement `xml:"TitleElement"`
+}
+
+type titleElement struct {
+	Level              string `xml:"TitleElementLevel"`
+	TitleText          string `xml:"TitleText"`
+	TitlePrefix        string `xml:"TitlePrefix"`
+	TitleWithoutPrefix string `xml:"TitleWithoutPrefix"`
+	Subtitle           string `xml:"Subtitle"`
+}
+
+type contributor struct {
+	Roles          []string `xml:"ContributorRole"`
+	PersonName     string   `xml:"PersonName"`
+	NamesBeforeKey string   `xml:"NamesBeforeKey"`
+	KeyNames       string   `xml:"KeyNames"`
+	CorporateName  string   `xml:"CorporateName"`
+}
+
+type subject struct {
+	MainSubject *struct{} `xml:"MainSubject"`
+	Scheme      string    `xml:"SubjectSchemeIdentifier"`
+	Code        string    `xml:"SubjectCode"`
+	HeadingText string    `xml:"SubjectHeadingText"`
+}
+
+type collateralDetail struct {
+	TextContents []textContent `xml:"TextContent"`
+	Other        []anyElement  `xml:",any"`
+}
+
+type textContent struct {
+	Type string `xml:"TextType"`
+	Text string `xml:"Text"`
+}
+
+type productSupply struct {
+	SupplyDetails []supplyDetail `xml:"SupplyDetail"`
+	Other         []anyElement   `xml:",any"`
+}
+
+type supplyDetail struct {
+	Prices []price      `xml:"Price"`
+	Other  []anyElement `xml:",any"`
+}
+
+type price struct {
+	Type     string `xml:"PriceType"`
+	Amount   string `xml:"PriceAmount"`
+	Currency string `xml:"CurrencyCode"`
+}
+
+type anyElement struct {
+	XMLName xml.Name
+}
+
+// defaults of the message header for the prices that leave them out
+type defaults struct {
+	currency  string
+	priceType string
+}
+
+// Parse reads the whole message, products are decoded one at a time so large
+// feeds are not held twice in memory. The price of a book is its retail price
+// in currency of the most preferred type, see retailPriceTypes. A price
+// without <CurrencyCode> or <PriceType> has the one of the header
+// (<DefaultCurrencyCode>, <DefaultPriceType>), or is in currency and an RRP.
+func Parse(r io.Reader, currency string) (*Feed, error) {
+	dec := xml.NewDecoder(r)
+	feed := &Feed{}
+	seenRoot := false
+	header := defaults{currency: currency, priceType: priceTypeRRP}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("onix: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "ONIXMessage":
+			seenRoot = true
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "release" {
+					feed.Release = attr.Value
+				}
+			}
+			if !strings.HasPrefix(feed.Release, "3.") {
+				return nil, ErrRelease
+			}
+		case "SenderName":
+			var name string
+			if err := dec.DecodeElement(&name, &start); err != nil {
+				return nil, fmt.Errorf("onix: %w", err)
+			}
+			feed.Sender = strings.TrimSpace(name)
+		case "DefaultCurrencyCode", "DefaultPriceType":
+			var value string
+			if err := dec.DecodeElement(&value, &start); err != nil {
+				return nil, fmt.Errorf("onix: %w", err)
+			}
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			if start.Name.Local == "DefaultCurrencyCode" {
+				header.currency = value
+			} else {
+				header.priceType = value
+			}
+		case "Product":
+			var p product
+			if err := dec.DecodeElement(&p, &start); err != nil {
+				return nil, fmt.Errorf("onix: product %d: %w", len(feed.Records)+1, err)
+			}
+			feed.Records = append(feed.Records, p.record(currency, header))
+		}
+	}
+
+	if !seenRoot {
+		return nil, errors.New("onix: missing ONIXMessage root element")
+	}
+
+	return feed, nil
+}
+
+func (p *product) record(currency string, header defaults) *Record {
+	rec := &Record{
+		Reference:    strings.TrimSpace(p.RecordReference),
+		Notification: strings.TrimSpace(p.NotificationType),
+		Book:         &models.Book{},
+	}
+
+	rec.Delete = rec.Notification == notificationDelete
+	rec.Partial = rec.Notification == notificationUpdate
+
+	for _, id := range p.Identifiers {
+		if strings.TrimSpace(id.Type) == idTypeISBN13 {
+			rec.Book.ISBN = strings.TrimSpace(id.Value)
+			break
+		}
+	}
+	for _, id := range p.Identifiers {
+		if strings.TrimSpace(id.Type) != idTypeISBN13 {
+			rec.Unmapped = append(rec.Unmapped, "ProductIdentifier/"+strings.TrimSpace(id.Type))
+		}
+	}
+
+	rec.Book.Title = p.DescriptiveDetail.title()
+	rec.Contributors, rec.Book.Author = p.DescriptiveDetail.authors()
+	rec.Book.Contributors = rec.Contributors
+	rec.Subjects, rec.Book.Genre = p.DescriptiveDetail.subjects()
+	rec.Book.Descriptions = p.CollateralDetail.description()
+	rec.Book.Price, rec.Priced = p.price(currency, header)
+
+	rec.Unmapped = append(rec.Unmapped, names("", p.Other)...)
+	rec.Unmapped = append(rec.Unmapped, names("DescriptiveDetail/", p.DescriptiveDetail.Other)...)
+	rec.Unmapped = append(rec.Unmapped, names("CollateralDetail/", p.CollateralDetail.Other)...)
+	for _, supply := range p.ProductSupply {
+		rec.Unmapped = append(rec.Unmapped, names("ProductSupply/", supply.Other)...)
+		for _, detail := range supply.SupplyDetails {
+			rec.Unmapped = append(rec.Unmapped, names("ProductSupply/SupplyDetail/", detail.Other)...)
+			for _, pr := range detail.Prices {
+				if code := pr.currency(header); !strings.EqualFold(code, currency) {
+					rec.Unmapped = append(rec.Unmapped, "ProductSupply/SupplyDetail/Price/"+code)
+				}
+			}
+		}
+	}
+	rec.Unmapped = unique(rec.Unmapped)
+
+	return rec
+}
+
+// distinctive title at product level, falls back to the first title found
+func (d *descriptiveDetail) title() string {
+	var fallback string
+	for _, detail := range d.TitleDetails {
+		for _, el := range detail.Elements {
+			text := el.text()
+			if text == "" {
+				continue
+			}
+			if fallback == "" {
+				fallback = text
+			}
+			if strings.TrimSpace(detail.Type) == titleTypeDistinct && strings.TrimSpace(el.Level) == titleElementProd {
+				return text
+			}
+		}
+	}
+
+	return fallback
+}
+
+func (el *titleElement) text() string {
+	if t := strings.TrimSpace(el.TitleText); t != "" {
+		return t
+	}
+
+	return strings.TrimSpace(strings.TrimSpace(el.TitlePrefix) + " " + strings.TrimSpace(el.TitleWithoutPrefix))
+}
+
+// ONIX contributor roles (list 17) of models.ContributorRoles, the other
+// roles are credited as authors
+var contributorRoles = map[string]string{
+	"A01": models.RoleAuthor,
+	"A12": models.RoleIllustrator,
+	"B01": models.RoleEditor,
+	"B06": models.RoleTranslator,
+}
+
+// every contributor with its roles, and the author field (A01 roles, or
+// everyone if no author)
+func (d *descriptiveDetail) authors() ([]*models.Contributor, string) {
+	all := []string{}
+	authors := []string{}
+	credits := []*models.Contributor{}
+	for _, c := range d.Contributors {
+		name := c.name()
+		if name == "" {
+			continue
+		}
+
+		all = append(all, name)
+		roles := map[string]bool{}
+		for _, code := range c.Roles {
+			code = strings.TrimSpace(code)
+			if code == roleAuthor {
+				authors = append(authors, name)
+			}
+
+			role, ok := contributorRoles[code]
+			if !ok {
+				role = models.RoleAuthor
+			}
+			if !roles[role] {
+				roles[role] = true
+				credits = append(credits, &models.Contributor{Name: name, Role: role})
+			}
+		}
+
+		if len(c.Roles) == 0 {
+			credits = append(credits, &models.Contributor{Name: name, Role: models.RoleAuthor})
+		}
+	}
+
+	if len(authors) == 0 {
+		authors = all
+	}
+
+	return credits, strings.Join(authors, ", ")
+}
+
+func (c *contributor) name() string {
+	switch {
+	case strings.TrimSpace(c.PersonName) != "":
+		return strings.TrimSpace(c.PersonName)
+	case strings.TrimSpace(c.KeyNames) != "":
+		return strings.TrimSpace(strings.TrimSpace(c.NamesBeforeKey) + " " + strings.TrimSpace(c.KeyNames))
+	}
+
+	return strings.TrimSpace(c.CorporateName)
+}
+
+// all subject headings (or codes), the main subject becomes the genre
+func (d *descriptiveDetail) subjects() ([]string, string) {
+	all := []string{}
+	genre := ""
+	for _, s := range d.Subjects {
+		heading := strings.TrimSpace(s.HeadingText)
+		if heading == "" {
+			heading = strings.TrimSpace(s.Code)
+		}
+		if heading == "" {
+			continue
+		}
+
+		all = append(all, heading)
+		if s.MainSubject != nil && genre == "" {
+			genre = heading
+		}
+	}
+
+	if genre == "" && len(all) > 0 {
+		genre = all[0]
+	}
+
+	return all, genre
+}
+
+// main description, or the short one
+func (c *collateralDetail) description() string {
+	var short string
+	for _, t := range c.TextContents {
+		switch strings.TrimSpace(t.Type) {
+		case textMainDesc:
+			return strings.TrimSpace(t.Text)
+		case textShortDesc:
+			short = strings.TrimSpace(t.Text)
+		}
+	}
+
+	return short
+}
+
+// the amount of the retail price in currency of the most preferred type, see
+// retailPriceTypes, false when the product has none
+func (p *product) price(currency string, header defaults) (float32, bool) {
+	best, amount := len(retailPriceTypes), float32(0)
+	for _, supply := range p.ProductSupply {
+		for _, detail := range supply.SupplyDetails {
+			for _, pr := range detail.Prices {
+				if !strings.EqualFold(pr.currency(header), currency) {
+					continue
+				}
+
+				priceType := strings.TrimSpace(pr.Type)
+				if priceType == "" {
+					priceType = header.priceType
+				}
+				rank := slices.Index(retailPriceTypes, priceType)
+				if rank < 0 || rank >= best {
+					continue
+				}
+
+				a, err := strconv.ParseFloat(strings.TrimSpace(pr.Amount), 32)
+				if err == nil && a >= 0 {
+					best, amount = rank, float32(a)
+				}
+			}
+		}
+	}
+
+	return amount, best < len(retailPriceTypes)
+}
+
+// the currency code of the price, a price without one is in the default
+// currency of the message
+func (pr *price) currency(header defaults) string {
+	if code := strings.TrimSpace(pr.Currency); code != "" {
+		return strings.ToUpper(code)
+	}
+
+	return strings.ToUpper(header.currency)
+}
+
+func names(prefix string, elements []anyElement) []string {
+	out := []string{}
+	for _, el := range elements {
+		out = append(out, prefix+el.XMLName.Local)
+	}
+
+	return out
+}
+
+func unique(in []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
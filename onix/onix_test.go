@@ -0,0 +1,146 @@
+package onix
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"test.iamgak.net/models"
+)
+
+func parse(t *testing.T, header, products string) *Feed {
+	t.Helper()
+	feed, err := Parse(strings.NewReader(`<ONIXMessage release="3.0"><Header><Sender><SenderName> Penguin </SenderName></Sender>`+header+`</Header>`+products+`</ONIXMessage>`), "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return feed
+}
+
+func TestParseRelease(t *testing.T) {
+	if _, err := Parse(strings.NewReader(`<ONIXMessage release="2.1"></ONIXMessage>`), "USD"); !errors.Is(err, ErrRelease) {
+		t.Errorf("Parse of ONIX 2.1 = %v, want ErrRelease", err)
+	}
+	if _, err := Parse(strings.NewReader(`<catalog></catalog>`), "USD"); err == nil {
+		t.Error("Parse without ONIXMessage = nil, want an error")
+	}
+	if _, err := Parse(strings.NewReader(`<ONIXMessage release="3.0"><Product>`), "USD"); err == nil {
+		t.Error("Parse of a cut message = nil, want an error")
+	}
+}
+
+func TestParseProduct(t *testing.T) {
+	feed := parse(t, "", `<Product>
+  <RecordReference>ref-1</RecordReference>
+  <NotificationType>03</NotificationType>
+  <ProductIdentifier><ProductIDType>03</ProductIDType><IDValue>9780451524935</IDValue></ProductIdentifier>
+  <ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>978-0-451-52493-5</IDValue></ProductIdentifier>
+  <DescriptiveDetail>
+    <ProductForm>BC</ProductForm>
+    <TitleDetail><TitleType>10</TitleType><TitleElement><TitleElementLevel>01</TitleElementLevel><TitleText>Animal Farm (Signet)</TitleText></TitleElement></TitleDetail>
+    <TitleDetail><TitleType>01</TitleType><TitleElement><TitleElementLevel>01</TitleElementLevel><TitlePrefix>The</TitlePrefix><TitleWithoutPrefix>Animal Farm</TitleWithoutPrefix></TitleElement></TitleDetail>
+    <Contributor><ContributorRole>A01</ContributorRole><NamesBeforeKey>George</NamesBeforeKey><KeyNames>Orwell</KeyNames></Contributor>
+    <Contributor><ContributorRole>B01</ContributorRole><ContributorRole>A01</ContributorRole><PersonName>Aldous Huxley</PersonName></Contributor>
+    <Contributor><ContributorRole>B06</ContributorRole><CorporateName>Translators Ltd</CorporateName></Contributor>
+    <Subject><SubjectSchemeIdentifier>10</SubjectSchemeIdentifier><SubjectCode>FIC019000</SubjectCode></Subject>
+    <Subject><MainSubject/><SubjectHeadingText>Political Satire</SubjectHeadingText></Subject>
+  </DescriptiveDetail>
+  <CollateralDetail>
+    <TextContent><TextType>02</TextType><Text>Short</Text></TextContent>
+    <TextContent><TextType>03</TextType><Text> A farm is taken over by its animals. </Text></TextContent>
+  </CollateralDetail>
+</Product>`)
+
+	if feed.Sender != "Penguin" || feed.Release != "3.0" || len(feed.Records) != 1 {
+		t.Fatalf("feed = %+v", feed)
+	}
+
+	rec := feed.Records[0]
+	book := rec.Book
+	if rec.Reference != "ref-1" || rec.Delete || rec.Partial {
+		t.Errorf("record = %+v", rec)
+	}
+	if book.ISBN != "978-0-451-52493-5" || book.Title != "The Animal Farm" || book.Genre != "Political Satire" || book.Descriptions != "A farm is taken over by its animals." {
+		t.Errorf("book = %+v", book)
+	}
+	if book.Author != "George Orwell, Aldous Huxley" {
+		t.Errorf("author = %q", book.Author)
+	}
+
+	want := []models.Contributor{
+		{Name: "George Orwell", Role: models.RoleAuthor},
+		{Name: "Aldous Huxley", Role: models.RoleEditor},
+		{Name: "Aldous Huxley", Role: models.RoleAuthor},
+		{Name: "Translators Ltd", Role: models.RoleTranslator},
+	}
+	if len(book.Contributors) != len(want) {
+		t.Fatalf("contributors = %d, want %d", len(book.Contributors), len(want))
+	}
+	for i, c := range book.Contributors {
+		if *c != want[i] {
+			t.Errorf("contributor %d = %+v, want %+v", i, *c, want[i])
+		}
+	}
+
+	if len(rec.Subjects) != 2 || rec.Subjects[0] != "FIC019000" {
+		t.Errorf("subjects = %v", rec.Subjects)
+	}
+	if strings.Join(rec.Unmapped, " ") != "DescriptiveDetail/ProductForm ProductIdentifier/03" {
+		t.Errorf("unmapped = %v", rec.Unmapped)
+	}
+}
+
+func TestParseNotification(t *testing.T) {
+	feed := parse(t, "", `<Product><NotificationType>05</NotificationType></Product><Product><NotificationType>04</NotificationType></Product>`)
+	if !feed.Records[0].Delete || feed.Records[0].Partial {
+		t.Errorf("05 = %+v, want a delete", feed.Records[0])
+	}
+	if feed.Records[1].Delete || !feed.Records[1].Partial {
+		t.Errorf("04 = %+v, want a block update", feed.Records[1])
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	price := func(priceType, amount, currency string) string {
+		s := "<Price>"
+		if priceType != "" {
+			s += "<PriceType>" + priceType + "</PriceType>"
+		}
+		s += "<PriceAmount>" + amount + "</PriceAmount>"
+		if currency != "" {
+			s += "<CurrencyCode>" + currency + "</CurrencyCode>"
+		}
+		return s + "</Price>"
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		prices string
+		want   float32
+		priced bool
+	}{
+		{"store currency", "", price("02", "9.99", "USD"), 9.99, true},
+		{"other currency only", "", price("02", "8.50", "EUR") + price("02", "7.99", "GBP"), 0, false},
+		{"currency of the header", "<DefaultCurrencyCode>EUR</DefaultCurrencyCode>", price("02", "8.50", "") + price("02", "9.99", "USD"), 9.99, true},
+		{"no currency anywhere", "", price("02", "9.99", ""), 9.99, true},
+		{"tax included first", "", price("01", "9.00", "USD") + price("02", "9.99", "USD") + price("04", "10.50", "USD"), 9.99, true},
+		{"fixed price over rrp excluding tax", "", price("01", "9.00", "USD") + price("04", "10.50", "USD"), 10.50, true},
+		{"trade price only", "", price("05", "5.00", "USD"), 0, false},
+		{"type of the header", "<DefaultPriceType>05</DefaultPriceType>", price("", "5.00", "USD"), 0, false},
+		{"no type anywhere", "", price("", "9.00", "USD"), 9, true},
+		{"bad amount", "", price("02", "free", "USD") + price("01", "9.00", "USD"), 9, true},
+		{"no price", "", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := parse(t, tt.header, `<Product><ProductSupply><SupplyDetail>`+tt.prices+`</SupplyDetail></ProductSupply></Product>`)
+			rec := feed.Records[0]
+			if rec.Book.Price != tt.want || rec.Priced != tt.priced {
+				t.Errorf("price = %v, %v, want %v, %v", rec.Book.Price, rec.Priced, tt.want, tt.priced)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package opds
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"test.iamgak.net/models"
+)
+
+const (
+	atomNS       = "http://www.w3.org/2005/Atom"
+	opdsNS       = "http://opds-spec.org/2010/catalog"
+	openSearchNS = "http://a9.com/-/spec/opensearch/1.1/"
+	dcNS         = "http://purl.org/dc/terms/"
+)
+
+// name shown as the author of every feed
+var StoreName = "Bookstore"
+
+type atomFeed struct {
+	XMLName      xml.Name    `xml:"feed"`
+	Xmlns        string      `xml:"xmlns,attr"`
+	XmlnsOPDS    string      `xml:"xmlns:opds,attr"`
+	XmlnsOS      string      `xml:"xmlns:opensearch,attr"`
+	XmlnsDC      string      `xml:"xmlns:dc,attr"`
+	ID           string      `xml:"id"`
+	Title        string      `xml:"title"`
+	Updated      string      `xml:"updated"`
+	Author       atomAuthor  `xml:"author"`
+	Links        []atomLink  `xml:"link"`
+	TotalResults int         `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int         `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   int         `xml:"opensearch:startIndex,omitempty"`
+	Entries      []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel   string     `xml:"rel,attr,omitempty"`
+	Href  string     `xml:"href,attr"`
+	Type  string     `xml:"type,attr,omitempty"`
+	Price *atomPrice `xml:"opds:price,omitempty"`
+}
+
+type atomPrice struct {
+	Currency string `xml:"currencycode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title   string       `xml:"title"`
+	ID      string       `xml:"id"`
+	Updated string       `xml:"updated"`
+	Authors []atomAuthor `xml:"author"`
+	// editors, translators and illustrators
+	Contributors []atomAuthor   `xml:"contributor"`
+	Identifier   string         `xml:"dc:identifier,omitempty"`
+	Categories   []atomCategory `xml:"category"`
+	Content      *atomContent   `xml:"content"`
+	Links        []atomLink     `xml:"link"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func atomLinkType(kind string) string {
+	if kind == "" {
+		return ""
+	}
+
+	return AtomType + ";kind=" + kind
+}
+
+// WriteAtom renders the feed as OPDS 1.2
+func WriteAtom(w io.Writer, f *Feed) error {
+	updated := f.Updated.UTC().Format(time.RFC3339)
+	out := atomFeed{
+		Xmlns:     atomNS,
+		XmlnsOPDS: opdsNS,
+		XmlnsOS:   openSearchNS,
+		XmlnsDC:   dcNS,
+		ID:        f.ID,
+		Title:     f.Title,
+		Updated:   updated,
+		Author:    atomAuthor{Name: StoreName},
+		Links: []atomLink{
+			{Rel: "self", Href: f.Self, Type: atomLinkType(f.Kind)},
+			{Rel: "search", Href: "/opds/opensearch.xml", Type: OpenSearchType},
+		},
+	}
+
+	for _, l := range f.Links {
+		typ := l.Type
+		if typ == "" {
+			typ = atomLinkType(l.Kind)
+		}
+		out.Links = append(out.Links, atomLink{Rel: l.Rel, Href: l.Href, Type: typ})
+	}
+
+	if f.PerPage > 0 {
+		out.TotalResults = f.Total
+		out.ItemsPerPage = f.PerPage
+		out.StartIndex = (f.Page-1)*f.PerPage + 1
+	}
+
+	for _, n := range f.Navigation {
+		out.Entries = append(out.Entries, atomEntry{
+			Title:   n.Title,
+			ID:      f.ID + ":" + n.Href,
+			Updated: updated,
+			Content: &atomContent{Type: "text", Value: strconv.Itoa(n.Count) + " books"},
+			Links:   []atomLink{{Rel: "subsection", Href: n.Href, Type: atomLinkType(KindAcquisition)}},
+		})
+	}
+
+	for _, p := range f.Publications {
+		b := p.Book
+		entry := atomEntry{
+			Title:      b.Title,
+			ID:         "urn:isbn:" + b.ISBN,
+			Updated:    updated,
+			Identifier: "urn:isbn:" + b.ISBN,
+			Content:    &atomContent{Type: "text", Value: b.Descriptions},
+			Links: []atomLink{{
+				Rel:   RelBuy,
+				Href:  p.Href,
+				Type:  "application/json",
+				Price: &atomPrice{Currency: f.Currency, Value: strconv.FormatFloat(float64(b.Price), 'f', 2, 32)},
+			}},
+		}
+		for _, c := range b.Credits() {
+			if c.Role == models.RoleAuthor {
+				entry.Authors = append(entry.Authors, atomAuthor{Name: c.Name})
+			} else {
+				entry.Contributors = append(entry.Contributors, atomAuthor{Name: c.Name})
+			}
+		}
+		if b.Genre != "" {
+			entry.Categories = []atomCategory{{Term: b.Genre, Label: b.Genre}}
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(out)
+}
+
+type openSearchDescription struct {
+	XMLName        xml.Name        `xml:"OpenSearchDescription"`
+	Xmlns          string          `xml:"xmlns,attr"`
+	ShortName      string          `xml:"ShortName"`
+	Description    string          `xml:"Description"`
+	InputEncoding  string          `xml:"InputEncoding"`
+	OutputEncoding string          `xml:"OutputEncoding"`
+	URLs           []openSearchURL `xml:"Url"`
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// WriteOpenSearch describes the search feeds, templates are absolute urls
+// built from base like https://localhost:8000
+func WriteOpenSearch(w io.Writer, base string) error {
+	out := openSearchDescription{
+		Xmlns:          openSearchNS,
+		ShortName:      StoreName,
+		Description:    "Search the " + StoreName + " catalog by title, author or isbn",
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		URLs: []openSearchURL{
+			{Type: atomLinkType(KindAcquisition), Template: base + "/opds/search?q={searchTerms}&page={startPage?}"},
+			{Type: JSONType, Template: base + "/opds2/search?q={searchTerms}&page={startPage?}"},
+		},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,138 @@
+package opds
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"test.iamgak.net/models"
+)
+
+type jsonFeed struct {
+	Metadata     jsonFeedMetadata  `json:"metadata"`
+	Links        []jsonLink        `json:"links"`
+	Navigation   []jsonLink        `json:"navigation,omitempty"`
+	Publications []jsonPublication `json:"publications,omitempty"`
+}
+
+type jsonFeedMetadata struct {
+	Title         string `json:"title"`
+	Modified      string `json:"modified"`
+	NumberOfItems int    `json:"numberOfItems,omitempty"`
+	ItemsPerPage  int    `json:"itemsPerPage,omitempty"`
+	CurrentPage   int    `json:"currentPage,omitempty"`
+}
+
+type jsonLink struct {
+	Rel        string          `json:"rel,omitempty"`
+	Href       string          `json:"href"`
+	Type       string          `json:"type,omitempty"`
+	Title      string          `json:"title,omitempty"`
+	Templated  bool            `json:"templated,omitempty"`
+	Properties *jsonProperties `json:"properties,omitempty"`
+}
+
+type jsonProperties struct {
+	NumberOfItems int        `json:"numberOfItems,omitempty"`
+	Price         *jsonPrice `json:"price,omitempty"`
+}
+
+type jsonPrice struct {
+	Value    float32 `json:"value"`
+	Currency string  `json:"currency"`
+}
+
+type jsonPublication struct {
+	Metadata jsonPublicationMetadata `json:"metadata"`
+	Links    []jsonLink              `json:"links"`
+}
+
+type jsonPublicationMetadata struct {
+	Type        string        `json:"@type"`
+	Title       string        `json:"title"`
+	Identifier  string        `json:"identifier"`
+	Author      []jsonSubject `json:"author,omitempty"`
+	Editor      []jsonSubject `json:"editor,omitempty"`
+	Translator  []jsonSubject `json:"translator,omitempty"`
+	Illustrator []jsonSubject `json:"illustrator,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Subject     []jsonSubject `json:"subject,omitempty"`
+}
+
+type jsonSubject struct {
+	Name string `json:"name"`
+}
+
+func jsonSubjects(names []string) []jsonSubject {
+	var subjects []jsonSubject
+	for _, name := range names {
+		subjects = append(subjects, jsonSubject{Name: name})
+	}
+
+	return subjects
+}
+
+// WriteJSON renders the feed as OPDS 2.0
+func WriteJSON(w io.Writer, f *Feed) error {
+	out := jsonFeed{
+		Metadata: jsonFeedMetadata{
+			Title:    f.Title,
+			Modified: f.Updated.UTC().Format(time.RFC3339),
+		},
+		Links: []jsonLink{
+			{Rel: "self", Href: f.Self, Type: JSONType},
+			{Rel: "search", Href: "/opds2/search{?q}", Type: JSONType, Templated: true},
+		},
+	}
+
+	if f.PerPage > 0 {
+		out.Metadata.NumberOfItems = f.Total
+		out.Metadata.ItemsPerPage = f.PerPage
+		out.Metadata.CurrentPage = f.Page
+	}
+
+	for _, l := range f.Links {
+		typ := l.Type
+		if typ == "" && l.Kind != "" {
+			typ = JSONType
+		}
+		out.Links = append(out.Links, jsonLink{Rel: l.Rel, Href: l.Href, Type: typ})
+	}
+
+	for _, n := range f.Navigation {
+		out.Navigation = append(out.Navigation, jsonLink{
+			Href:       n.Href,
+			Title:      n.Title,
+			Type:       JSONType,
+			Properties: &jsonProperties{NumberOfItems: n.Count},
+		})
+	}
+
+	for _, p := range f.Publications {
+		b := p.Book
+		pub := jsonPublication{
+			Metadata: jsonPublicationMetadata{
+				Type:        "http://schema.org/Book",
+				Title:       b.Title,
+				Identifier:  "urn:isbn:" + b.ISBN,
+				Description: b.Descriptions,
+			},
+			Links: []jsonLink{{
+				Rel:        RelBuy,
+				Href:       p.Href,
+				Type:       "application/json",
+				Properties: &jsonProperties{Price: &jsonPrice{Value: b.Price, Currency: f.Currency}},
+			}},
+		}
+		pub.Metadata.Author = jsonSubjects(b.CreditedAs(models.RoleAuthor))
+		pub.Metadata.Editor = jsonSubjects(b.CreditedAs(models.RoleEditor))
+		pub.Metadata.Translator = jsonSubjects(b.CreditedAs(models.RoleTranslator))
+		pub.Metadata.Illustrator = jsonSubjects(b.CreditedAs(models.RoleIllustrator))
+		if b.Genre != "" {
+			pub.Metadata.Subject = []jsonSubject{{Name: b.Genre}}
+		}
+		out.Publications = append(out.Publications, pub)
+	}
+
+	return json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,91 @@
+// Package opds renders catalog feeds for e-reader apps, as OPDS 1.2 (Atom)
+// or OPDS 2.0 (JSON). Handlers build one Feed and pick the output with
+// WriteAtom or WriteJSON, so both versions always list the same entries.
+package opds
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"test.iamgak.net/models"
+)
+
+const (
+	KindNavigation  = "navigation"
+	KindAcquisition = "acquisition"
+
+	RelBuy = "http://opds-spec.org/acquisition/buy"
+
+	AtomType       = "application/atom+xml;profile=opds-catalog"
+	JSONType       = "application/opds+json"
+	OpenSearchType = "application/opensearchdescription+xml"
+)
+
+type Feed struct {
+	ID      string
+	Title   string
+	Updated time.Time
+	Kind    string
+	// path of the feed itself, other links are relative to the site root too
+	Self string
+	// price currency of the acquisition links, books only carry an amount
+	Currency string
+	Links    []Link
+
+	Navigation   []Navigation
+	Publications []Publication
+
+	// zero for navigation feeds
+	Total   int
+	PerPage int
+	Page    int
+}
+
+type Link struct {
+	Rel  string
+	Href string
+	// Kind of the linked feed, empty for non feed links like search
+	Kind string
+	Type string
+}
+
+type Navigation struct {
+	Title string
+	Href  string
+	Count int
+}
+
+type Publication struct {
+	Book *models.Book
+	// where the book can be bought, the book page of the store
+	Href string
+}
+
+// first, previous, next and last links of a paged feed
+func (f *Feed) Paginate(href string) {
+	if f.PerPage <= 0 {
+		return
+	}
+
+	sep := "?"
+	if strings.Contains(href, "?") {
+		sep = "&"
+	}
+
+	last := (f.Total + f.PerPage - 1) / f.PerPage
+	page := func(n int) string {
+		return href + sep + "page=" + strconv.Itoa(n)
+	}
+
+	f.Links = append(f.Links, Link{Rel: "first", Href: page(1), Kind: f.Kind})
+	if f.Page > 1 {
+		f.Links = append(f.Links, Link{Rel: "previous", Href: page(f.Page - 1), Kind: f.Kind})
+	}
+	if f.Page < last {
+		f.Links = append(f.Links, Link{Rel: "next", Href: page(f.Page + 1), Kind: f.Kind})
+	}
+	if last > 0 {
+		f.Links = append(f.Links, Link{Rel: "last", Href: page(last), Kind: f.Kind})
+	}
+}
@@ -0,0 +1,211 @@
+package opds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"test.iamgak.net/models"
+)
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name  string
+		href  string
+		total int
+		page  int
+		want  []string
+	}{
+		{"empty", "/opds/books", 0, 1, []string{"first /opds/books?page=1"}},
+		{"one page", "/opds/books", 20, 1, []string{"first /opds/books?page=1", "last /opds/books?page=1"}},
+		{"first of three", "/opds/books", 41, 1, []string{"first /opds/books?page=1", "next /opds/books?page=2", "last /opds/books?page=3"}},
+		{"middle", "/opds/books", 41, 2, []string{"first /opds/books?page=1", "previous /opds/books?page=1", "next /opds/books?page=3", "last /opds/books?page=3"}},
+		{"last", "/opds/books", 41, 3, []string{"first /opds/books?page=1", "previous /opds/books?page=2", "last /opds/books?page=3"}},
+		{"query", "/opds/search?q=dune", 21, 1, []string{"first /opds/search?q=dune&page=1", "next /opds/search?q=dune&page=2", "last /opds/search?q=dune&page=2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &Feed{Kind: KindAcquisition, Total: tt.total, PerPage: 20, Page: tt.page}
+			f.Paginate(tt.href)
+
+			got := []string{}
+			for _, l := range f.Links {
+				if l.Kind != KindAcquisition {
+					t.Errorf("%s link is of kind %q", l.Rel, l.Kind)
+				}
+				got = append(got, l.Rel+" "+l.Href)
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("links = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// navigation feeds are not paged
+	f := &Feed{Kind: KindNavigation}
+	f.Paginate("/opds")
+	if len(f.Links) != 0 {
+		t.Errorf("links of a navigation feed = %v, want none", f.Links)
+	}
+}
+
+func testFeeds() (navigation, acquisition *Feed) {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	navigation = &Feed{
+		ID:         "urn:bookstore:catalog",
+		Title:      "Catalog",
+		Updated:    updated,
+		Kind:       KindNavigation,
+		Self:       "/opds",
+		Links:      []Link{{Rel: "start", Href: "/opds", Kind: KindNavigation}},
+		Navigation: []Navigation{{Title: "By Genre", Href: "/opds/genres", Count: 3}},
+	}
+
+	acquisition = &Feed{
+		ID:       "urn:bookstore:books",
+		Title:    "All Books",
+		Updated:  updated,
+		Kind:     KindAcquisition,
+		Self:     "/opds/books",
+		Currency: "EUR",
+		Total:    41,
+		PerPage:  20,
+		Page:     2,
+		Publications: []Publication{{
+			Href: "/book/search/978-0-451-52493-5/",
+			Book: &models.Book{
+				ISBN:         "978-0-451-52493-5",
+				Title:        "Animal Farm & Co",
+				Price:        9.5,
+				Descriptions: "All animals are equal",
+				Genre:        "Satire",
+				Contributors: []*models.Contributor{
+					{Name: "George Orwell", Role: models.RoleAuthor},
+					{Name: "Ana Ruiz", Role: models.RoleTranslator},
+				},
+			},
+		}},
+	}
+
+	return navigation, acquisition
+}
+
+func TestWriteAtom(t *testing.T) {
+	navigation, acquisition := testFeeds()
+	tests := []struct {
+		name string
+		feed *Feed
+		want []string
+	}{
+		{"navigation", navigation, []string{
+			`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:opds="http://opds-spec.org/2010/catalog"`,
+			`<link rel="self" href="/opds" type="application/atom+xml;profile=opds-catalog;kind=navigation">`,
+			`<link rel="search" href="/opds/opensearch.xml" type="application/opensearchdescription+xml">`,
+			`<updated>2024-05-01T12:00:00Z</updated>`,
+			`<content type="text">3 books</content>`,
+			`<link rel="subsection" href="/opds/genres" type="application/atom+xml;profile=opds-catalog;kind=acquisition">`,
+		}},
+		{"acquisition", acquisition, []string{
+			`<opensearch:totalResults>41</opensearch:totalResults>`,
+			`<opensearch:startIndex>21</opensearch:startIndex>`,
+			`<title>Animal Farm &amp; Co</title>`,
+			`<dc:identifier>urn:isbn:978-0-451-52493-5</dc:identifier>`,
+			"<author>\n      <name>George Orwell</name>",
+			"<contributor>\n      <name>Ana Ruiz</name>",
+			`<category term="Satire" label="Satire">`,
+			`<link rel="http://opds-spec.org/acquisition/buy" href="/book/search/978-0-451-52493-5/" type="application/json">`,
+			`<opds:price currencycode="EUR">9.50</opds:price>`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteAtom(&buf, tt.feed); err != nil {
+				t.Fatal(err)
+			}
+			if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+				t.Fatalf("WriteAtom wrote malformed xml: %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("WriteAtom = %s\nwant %s", buf.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	navigation, acquisition := testFeeds()
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, navigation); err != nil {
+		t.Fatal(err)
+	}
+	var nav jsonFeed
+	if err := json.Unmarshal(buf.Bytes(), &nav); err != nil {
+		t.Fatal(err)
+	}
+	if len(nav.Navigation) != 1 || nav.Navigation[0].Properties.NumberOfItems != 3 || nav.Navigation[0].Type != JSONType {
+		t.Errorf("navigation = %+v, want the genres with 3 books", nav.Navigation)
+	}
+	if len(nav.Links) != 3 || nav.Links[2].Rel != "start" || nav.Links[2].Type != JSONType || !nav.Links[1].Templated {
+		t.Errorf("links = %+v, want self, a templated search and start", nav.Links)
+	}
+	if nav.Metadata.NumberOfItems != 0 || nav.Metadata.Modified != "2024-05-01T12:00:00Z" {
+		t.Errorf("metadata = %+v, want no paging", nav.Metadata)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, acquisition); err != nil {
+		t.Fatal(err)
+	}
+	var acq jsonFeed
+	if err := json.Unmarshal(buf.Bytes(), &acq); err != nil {
+		t.Fatal(err)
+	}
+	if acq.Metadata.NumberOfItems != 41 || acq.Metadata.ItemsPerPage != 20 || acq.Metadata.CurrentPage != 2 {
+		t.Errorf("metadata = %+v, want page 2 of 41 books", acq.Metadata)
+	}
+	if len(acq.Publications) != 1 {
+		t.Fatalf("publications = %+v, want Animal Farm", acq.Publications)
+	}
+
+	pub := acq.Publications[0]
+	if pub.Metadata.Identifier != "urn:isbn:978-0-451-52493-5" || pub.Metadata.Title != "Animal Farm & Co" {
+		t.Errorf("metadata = %+v", pub.Metadata)
+	}
+	if len(pub.Metadata.Author) != 1 || pub.Metadata.Author[0].Name != "George Orwell" || len(pub.Metadata.Translator) != 1 || pub.Metadata.Translator[0].Name != "Ana Ruiz" {
+		t.Errorf("credits = %+v %+v, want Orwell translated by Ruiz", pub.Metadata.Author, pub.Metadata.Translator)
+	}
+	if len(pub.Metadata.Subject) != 1 || pub.Metadata.Subject[0].Name != "Satire" {
+		t.Errorf("subject = %+v, want Satire", pub.Metadata.Subject)
+	}
+	if len(pub.Links) != 1 || pub.Links[0].Rel != RelBuy || pub.Links[0].Properties.Price.Value != 9.5 || pub.Links[0].Properties.Price.Currency != "EUR" {
+		t.Errorf("links = %+v, want a buy link for 9.50 EUR", pub.Links)
+	}
+}
+
+func TestWriteOpenSearch(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteOpenSearch(&buf, "https://books.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var out openSearchDescription
+	if err := xml.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ShortName != StoreName || len(out.URLs) != 2 {
+		t.Fatalf("description = %+v", out)
+	}
+	if out.URLs[0].Template != "https://books.example.com/opds/search?q={searchTerms}&page={startPage?}" || out.URLs[1].Type != JSONType {
+		t.Errorf("urls = %+v, want the absolute OPDS 1.2 and 2.0 search templates", out.URLs)
+	}
+}
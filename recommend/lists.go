@@ -0,0 +1,60 @@
+package recommend
+
+import (
+	"math"
+	"time"
+)
+
+// something that happened to a book: a view, a review, a sale of Weight copies
+type Event struct {
+	ISBN   string
+	At     time.Time
+	Weight float64
+}
+
+// Trending sums the events of every book, an event counts half as much for
+// every halfLife of its age, so a book read a lot today beats one read a lot
+// last week.
+func Trending(events []Event, now time.Time, halfLife time.Duration, n int) []Scored {
+	score := map[string]float64{}
+	for _, e := range events {
+		age := now.Sub(e.At)
+		if age < 0 {
+			age = 0
+		}
+		score[e.ISBN] += e.Weight * math.Exp2(-float64(age)/float64(halfLife))
+	}
+
+	return ranked(score, n)
+}
+
+// Total sums the weights of the events of every book.
+func Total(events []Event, n int) []Scored {
+	score := map[string]float64{}
+	for _, e := range events {
+		score[e.ISBN] += e.Weight
+	}
+
+	return ranked(score, n)
+}
+
+// Filter keeps the events of the books keep returns true for.
+func Filter(events []Event, keep func(isbn string) bool) []Event {
+	kept := []Event{}
+	for _, e := range events {
+		if keep(e.ISBN) {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept
+}
+
+func ranked(score map[string]float64, n int) []Scored {
+	list := make([]Scored, 0, len(score))
+	for isbn, s := range score {
+		list = append(list, Scored{isbn, s})
+	}
+
+	return best(list, n)
+}
@@ -0,0 +1,222 @@
+// Package recommend ranks books for readers. Item based collaborative
+// filtering compares books by how the same readers rated them, content
+// similarity compares them by shared genres and authors for books and readers
+// with too few ratings, and Popular ranks books for readers without any. The
+// lists of the home page rank books by events like views, reviews and sales.
+package recommend
+
+import (
+	"math"
+	"sort"
+)
+
+// a reader's rating of a book, 1 to 5 stars
+type Rating struct {
+	UID   int64
+	ISBN  string
+	Score float64
+}
+
+type Scored struct {
+	ISBN  string  `json:"isbn"`
+	Score float64 `json:"score"`
+}
+
+// isbn -> the most similar books, best first
+type Neighbours map[string][]Scored
+
+// shrinks the predicted rating of books backed by little similarity, a book
+// similar to one rated book by 0.1 does not outrank one similar to five by 0.9
+const shrink = 1.0
+
+// ItemSimilarity compares every pair of books rated by at least minCommon of
+// the same readers with the adjusted cosine (ratings centred on the mean of
+// each reader) and keeps the k most similar positive neighbours of each book.
+func ItemSimilarity(ratings []Rating, minCommon, k int) Neighbours {
+	byUser := map[int64][]Rating{}
+	for _, r := range ratings {
+		byUser[r.UID] = append(byUser[r.UID], r)
+	}
+
+	type pair struct{ a, b string }
+	type sums struct {
+		dot, a, b float64
+		common    int
+	}
+	pairs := map[pair]*sums{}
+	for _, rs := range byUser {
+		var mean float64
+		for _, r := range rs {
+			mean += r.Score
+		}
+		mean /= float64(len(rs))
+
+		for i := range rs {
+			for j := range rs {
+				if rs[i].ISBN >= rs[j].ISBN {
+					continue
+				}
+
+				p := pair{rs[i].ISBN, rs[j].ISBN}
+				s, ok := pairs[p]
+				if !ok {
+					s = &sums{}
+					pairs[p] = s
+				}
+
+				x, y := rs[i].Score-mean, rs[j].Score-mean
+				s.dot += x * y
+				s.a += x * x
+				s.b += y * y
+				s.common++
+			}
+		}
+	}
+
+	neighbours := Neighbours{}
+	for p, s := range pairs {
+		if s.common < minCommon || s.a == 0 || s.b == 0 {
+			continue
+		}
+
+		sim := s.dot / (math.Sqrt(s.a) * math.Sqrt(s.b))
+		if sim <= 0 {
+			continue
+		}
+
+		neighbours[p.a] = append(neighbours[p.a], Scored{p.b, sim})
+		neighbours[p.b] = append(neighbours[p.b], Scored{p.a, sim})
+	}
+
+	return neighbours.top(k)
+}
+
+// ContentSimilarity compares books by the Jaccard index of their features
+// (genre and author ids) and keeps the k most similar of each book.
+func ContentSimilarity(features map[string][]string, k int) Neighbours {
+	byFeature := map[string][]string{}
+	for isbn, fs := range features {
+		for _, f := range fs {
+			byFeature[f] = append(byFeature[f], isbn)
+		}
+	}
+
+	neighbours := Neighbours{}
+	for isbn, fs := range features {
+		shared := map[string]int{}
+		for _, f := range fs {
+			for _, other := range byFeature[f] {
+				if other != isbn {
+					shared[other]++
+				}
+			}
+		}
+
+		for other, n := range shared {
+			union := len(fs) + len(features[other]) - n
+			neighbours[isbn] = append(neighbours[isbn], Scored{other, float64(n) / float64(union)})
+		}
+	}
+
+	return neighbours.top(k)
+}
+
+// Recommend scores every book near the rated ones with the similarity
+// weighted mean of the reader's ratings, shrunk for books backed by little
+// similarity, and returns the n best. Books the reader rated are left out.
+func Recommend(rated map[string]float64, sims Neighbours, n int) []Scored {
+	weighted := map[string]float64{}
+	weights := map[string]float64{}
+	for isbn, score := range rated {
+		for _, nb := range sims[isbn] {
+			if _, ok := rated[nb.ISBN]; ok {
+				continue
+			}
+
+			weighted[nb.ISBN] += nb.Score * score
+			weights[nb.ISBN] += nb.Score
+		}
+	}
+
+	list := []Scored{}
+	for isbn, w := range weights {
+		list = append(list, Scored{isbn, weighted[isbn] / (w + shrink)})
+	}
+
+	return best(list, n)
+}
+
+// Popular ranks books by their mean rating pulled towards the mean of every
+// rating (a Bayesian average), so two five star ratings do not beat fifty
+// ratings of four and a half.
+func Popular(ratings []Rating, n int) []Scored {
+	if len(ratings) == 0 {
+		return []Scored{}
+	}
+
+	var all float64
+	sum := map[string]float64{}
+	count := map[string]float64{}
+	for _, r := range ratings {
+		all += r.Score
+		sum[r.ISBN] += r.Score
+		count[r.ISBN]++
+	}
+
+	// as if every book had this many ratings of the overall mean
+	prior := float64(len(ratings)) / float64(len(count))
+	mean := all / float64(len(ratings))
+	list := []Scored{}
+	for isbn, s := range sum {
+		list = append(list, Scored{isbn, (s + prior*mean) / (count[isbn] + prior)})
+	}
+
+	return best(list, n)
+}
+
+// Merge keeps the order of the lists, the first list first, without the
+// books of skip or repeated books, up to n books.
+func Merge(n int, skip map[string]float64, lists ...[]Scored) []Scored {
+	seen := map[string]bool{}
+	merged := []Scored{}
+	for _, list := range lists {
+		for _, s := range list {
+			if len(merged) == n {
+				return merged
+			}
+
+			if _, ok := skip[s.ISBN]; ok || seen[s.ISBN] {
+				continue
+			}
+
+			seen[s.ISBN] = true
+			merged = append(merged, s)
+		}
+	}
+
+	return merged
+}
+
+func (nb Neighbours) top(k int) Neighbours {
+	for isbn, list := range nb {
+		nb[isbn] = best(list, k)
+	}
+
+	return nb
+}
+
+// highest score first, ties by isbn so every run gives the same lists
+func best(list []Scored, n int) []Scored {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score > list[j].Score
+		}
+		return list[i].ISBN < list[j].ISBN
+	})
+
+	if len(list) > n {
+		list = list[:n]
+	}
+
+	return list
+}
@@ -0,0 +1,246 @@
+package recommend
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// isbn:score pairs rounded to three decimals, easy to compare
+func format(list []Scored) string {
+	out := ""
+	for i, s := range list {
+		if i > 0 {
+			out += " "
+		}
+		out += fmt.Sprintf("%s:%.3f", s.ISBN, s.Score)
+	}
+
+	return out
+}
+
+func ratings(scores map[int64]map[string]float64) []Rating {
+	list := []Rating{}
+	for uid, books := range scores {
+		for isbn, score := range books {
+			list = append(list, Rating{UID: uid, ISBN: isbn, Score: score})
+		}
+	}
+
+	return list
+}
+
+func TestItemSimilarity(t *testing.T) {
+	tests := []struct {
+		name      string
+		scores    map[int64]map[string]float64
+		minCommon int
+		k         int
+		want      map[string]string
+	}{
+		{
+			// a and b are liked and disliked together, c the other way round
+			name: "same taste",
+			scores: map[int64]map[string]float64{
+				1: {"a": 5, "b": 5, "c": 1},
+				2: {"a": 4, "b": 4, "c": 2},
+				3: {"a": 1, "b": 1, "c": 5},
+			},
+			minCommon: 3,
+			k:         5,
+			want:      map[string]string{"a": "b:1.000", "b": "a:1.000"},
+		},
+		{
+			name: "too few common readers",
+			scores: map[int64]map[string]float64{
+				1: {"a": 5, "b": 5, "c": 1},
+				2: {"a": 4, "b": 4, "c": 2},
+			},
+			minCommon: 3,
+			k:         5,
+			want:      map[string]string{},
+		},
+		{
+			// centred on the reader means: a +1, b 0, c -1 and a +1, b +1, c -2
+			name: "adjusted cosine",
+			scores: map[int64]map[string]float64{
+				1: {"a": 5, "b": 4, "c": 3},
+				2: {"a": 5, "b": 5, "c": 2},
+			},
+			minCommon: 2,
+			k:         5,
+			want:      map[string]string{"a": "b:0.707", "b": "a:0.707"},
+		},
+		{
+			// a reader who rates everything the same says nothing
+			name: "flat ratings",
+			scores: map[int64]map[string]float64{
+				1: {"a": 4, "b": 4},
+				2: {"a": 4, "b": 4},
+			},
+			minCommon: 1,
+			k:         5,
+			want:      map[string]string{},
+		},
+		{
+			// c is less like a and b than they are like each other, d unlike all
+			name: "k most similar",
+			scores: map[int64]map[string]float64{
+				1: {"a": 5, "b": 5, "c": 5, "d": 1},
+				2: {"a": 1, "b": 1, "c": 2, "d": 5},
+			},
+			minCommon: 2,
+			k:         1,
+			want:      map[string]string{"a": "b:1.000", "b": "a:1.000", "c": "a:0.795"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ItemSimilarity(ratings(tt.scores), tt.minCommon, tt.k)
+			if len(got) != len(tt.want) {
+				t.Errorf("ItemSimilarity = %v, want %v", got, tt.want)
+			}
+			for isbn, want := range tt.want {
+				if format(got[isbn]) != want {
+					t.Errorf("neighbours of %s = %s, want %s", isbn, format(got[isbn]), want)
+				}
+			}
+		})
+	}
+}
+
+func TestContentSimilarity(t *testing.T) {
+	got := ContentSimilarity(map[string][]string{
+		"a": {"genre:1", "author:1"},
+		"b": {"genre:1", "author:2"},
+		"c": {"genre:1", "author:1", "author:2"},
+		"d": {"genre:2"},
+	}, 5)
+
+	want := map[string]string{
+		"a": "c:0.667 b:0.333",
+		"b": "c:0.667 a:0.333",
+		"c": "a:0.667 b:0.667",
+	}
+	if len(got) != len(want) {
+		t.Errorf("ContentSimilarity = %v, want nothing for d", got)
+	}
+	for isbn, w := range want {
+		if format(got[isbn]) != w {
+			t.Errorf("neighbours of %s = %s, want %s", isbn, format(got[isbn]), w)
+		}
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	sims := Neighbours{
+		"a": {{"b", 1}, {"c", 0.5}},
+		"b": {{"a", 1}, {"c", 1}},
+		"d": {{"e", 0.1}},
+	}
+
+	tests := []struct {
+		name  string
+		rated map[string]float64
+		n     int
+		want  string
+	}{
+		// 5*1/(1+1) and 5*0.5/(0.5+1)
+		{"shrunk mean", map[string]float64{"a": 5}, 10, "b:2.500 c:1.667"},
+		// the rated b is left out, c is (5*0.5 + 4*1) / (1.5+1)
+		{"rated left out", map[string]float64{"a": 5, "b": 4}, 10, "c:2.600"},
+		{"n best", map[string]float64{"a": 5}, 1, "b:2.500"},
+		// a weak neighbour does not get the full rating
+		{"little similarity", map[string]float64{"d": 5}, 10, "e:0.455"},
+		{"nothing rated", map[string]float64{}, 10, ""},
+	}
+
+	for _, tt := range tests {
+		if got := format(Recommend(tt.rated, sims, tt.n)); got != tt.want {
+			t.Errorf("%s: Recommend = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPopular(t *testing.T) {
+	list := []Rating{}
+	add := func(isbn string, score float64, n int) {
+		for i := 0; i < n; i++ {
+			list = append(list, Rating{UID: int64(len(list)), ISBN: isbn, Score: score})
+		}
+	}
+	add("few", 5, 2)
+	add("many", 4.5, 10)
+	add("poor", 3, 10)
+
+	// the prior is 22/3 ratings of the mean 85/22
+	if got := format(Popular(list, 10)); got != "many:4.231 few:4.107 poor:3.365" {
+		t.Errorf("Popular = %s", got)
+	}
+	if got := format(Popular(list, 1)); got != "many:4.231" {
+		t.Errorf("Popular of one = %s", got)
+	}
+	if got := Popular(nil, 10); got == nil || len(got) != 0 {
+		t.Errorf("Popular without ratings = %v, want an empty list", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := []Scored{{"a", 3}, {"b", 2}}
+	b := []Scored{{"b", 9}, {"c", 8}, {"d", 7}, {"e", 6}}
+
+	tests := []struct {
+		name string
+		n    int
+		skip map[string]float64
+		want string
+	}{
+		{"first list first", 10, nil, "a:3.000 b:2.000 c:8.000 d:7.000 e:6.000"},
+		{"skipped", 10, map[string]float64{"c": 5}, "a:3.000 b:2.000 d:7.000 e:6.000"},
+		{"up to n", 3, nil, "a:3.000 b:2.000 c:8.000"},
+		{"none", 0, nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := format(Merge(tt.n, tt.skip, a, b)); got != tt.want {
+			t.Errorf("%s: Merge = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrending(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	events := []Event{
+		{ISBN: "today", At: now, Weight: 1},
+		{ISBN: "yesterday", At: now.Add(-day), Weight: 1.5},
+		{ISBN: "yesterday", At: now.Add(-2 * day), Weight: 1},
+		// a clock ahead of ours counts as now
+		{ISBN: "future", At: now.Add(time.Hour), Weight: 0.5},
+	}
+
+	// half as much for every day: 1.5/2 + 1/4
+	if got := format(Trending(events, now, day, 10)); got != "today:1.000 yesterday:1.000 future:0.500" {
+		t.Errorf("Trending = %s", got)
+	}
+	if got := format(Trending(events, now, day, 1)); got != "today:1.000" {
+		t.Errorf("Trending of one = %s, want ties by isbn", got)
+	}
+	if got := format(Total(events, 10)); got != "yesterday:2.500 today:1.000 future:0.500" {
+		t.Errorf("Total = %s", got)
+	}
+
+	kept := Filter(events, func(isbn string) bool { return isbn != "yesterday" })
+	if len(kept) != 2 || kept[0].ISBN != "today" || kept[1].ISBN != "future" {
+		t.Errorf("Filter = %v, want today and future", kept)
+	}
+}
+
+func TestBest(t *testing.T) {
+	list := best([]Scored{{"c", 1}, {"a", 2}, {"b", 1}, {"d", math.Inf(-1)}}, 3)
+	if got := format(list); got != "a:2.000 b:1.000 c:1.000" {
+		t.Errorf("best = %s, want the highest first and ties by isbn", got)
+	}
+}
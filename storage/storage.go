@@ -0,0 +1,81 @@
+// Package storage keeps uploaded files, like book covers, on the local disk
+// or in an S3 compatible bucket (AWS, MinIO, ...) behind one interface.
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Storage interface {
+	// Put writes the object, replacing an existing one with the same key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Delete(ctx context.Context, key string) error
+	// URL where clients can download the object
+	URL(key string) string
+}
+
+// Local keeps the objects under Root, they are served from BaseURL
+// (see http.FileServer) like /covers/
+type Local struct {
+	Root    string
+	BaseURL string
+}
+
+func NewLocal(root, baseURL string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Local{Root: root, BaseURL: strings.TrimSuffix(baseURL, "/") + "/"}, nil
+}
+
+// keys can not leave the root
+func (l *Local) path(key string) string {
+	return filepath.Join(l.Root, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// write next to the target and rename, readers never see half a file
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	err := os.Remove(l.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (l *Local) URL(key string) string {
+	return l.BaseURL + strings.TrimPrefix(key, "/")
+}